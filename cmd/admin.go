@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"crawler/internal/cfg"
+	"crawler/internal/parser"
+	"crawler/internal/seed"
+	"crawler/internal/storage"
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"sort"
+	"strings"
+)
+
+func statusCommand(a *app, o *options) error {
+	return withStore(a, o, func(appCfg *cfg.Config, s *store) error {
+		seeds, err := s.seeds.All()
+		if err != nil {
+			return err
+		}
+		failed := 0
+		err = s.links.ForEachPageRecord(func(record *storage.PageRecord) error {
+			if record.Error != "" {
+				failed++
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(a.stdout, "Database:    %s\n", appCfg.DatabaseFile)
+		fmt.Fprintf(a.stdout, "Seeds:       %d\n", len(seeds))
+		fmt.Fprintf(a.stdout, "Fetched:     %d\n", s.links.Size())
+		fmt.Fprintf(a.stdout, "Failed:      %d\n", failed)
+		fmt.Fprintf(a.stdout, "Queued:      %d\n", s.queue.Size())
+		fmt.Fprintf(a.stdout, "Blacklisted: %d\n", s.blacklist.Size())
+		return nil
+	})
+}
+
+func exportCommand(a *app, o *options) error {
+	return withStore(a, o, func(appCfg *cfg.Config, s *store) error {
+		out := a.stdout
+		if o.output != "" {
+			f, err := os.Create(o.output)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			out = f
+		}
+		w := bufio.NewWriter(out)
+		enc := json.NewEncoder(w)
+		err := s.links.ForEachPageRecord(func(record *storage.PageRecord) error {
+			return enc.Encode(record)
+		})
+		if err != nil {
+			return err
+		}
+		return w.Flush()
+	})
+}
+
+func inspectCommand(a *app, o *options) error {
+	url := o.args[0]
+	return withStore(a, o, func(appCfg *cfg.Config, s *store) error {
+		if o.body {
+			body, err := s.links.GetByKey(url)
+			if err != nil {
+				return err
+			}
+			if body == nil {
+				return fmt.Errorf("no stored body for %s", url)
+			}
+			_, err = a.stdout.Write(body)
+			return err
+		}
+
+		record, err := s.links.GetPageRecord(url)
+		if err != nil {
+			return err
+		}
+		item, queued, err := s.queue.Get(url)
+		if err != nil {
+			return err
+		}
+		reason, blacklisted := s.blacklist.Reason(url)
+
+		fmt.Fprintf(a.stdout, "URL:         %s\n", url)
+		fmt.Fprintf(a.stdout, "Seen:        %t\n", s.seen.Contains(url))
+		if queued {
+			fmt.Fprintf(a.stdout, "Queued:      depth %d, source %s\n", item.Depth, item.Source)
+		}
+		if blacklisted {
+			fmt.Fprintf(a.stdout, "Blacklisted: %s\n", reason)
+		}
+		if record == nil {
+			fmt.Fprintln(a.stdout, "Fetched:     no")
+			return nil
+		}
+		data, err := json.MarshalIndent(record, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(a.stdout, "Record:\n%s\n", data)
+		return nil
+	})
+}
+
+func queueListCommand(a *app, o *options) error {
+	return withStore(a, o, func(appCfg *cfg.Config, s *store) error {
+		items, err := s.queue.List(o.limit)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			fmt.Fprintf(a.stdout, "%s\t%d\t%s\n", item.URL, item.Depth, item.Source)
+		}
+		return nil
+	})
+}
+
+// queueAddCommand queues the urls even when they were seen before, the urls out of the scope of the seeds
+// are skipped by the crawl
+func queueAddCommand(a *app, o *options) error {
+	if _, err := seed.FromURLs(o.args); err != nil {
+		return usageError{err}
+	}
+	return withStore(a, o, func(appCfg *cfg.Config, s *store) error {
+		for _, url := range o.args {
+			if _, err := s.seen.Add(url); err != nil {
+				return err
+			}
+			if err := s.queue.Push(storage.QueueItem{URL: url, Source: parser.SourceSeed}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func queueRemoveCommand(a *app, o *options) error {
+	return withStore(a, o, func(appCfg *cfg.Config, s *store) error {
+		var missing []string
+		for _, url := range o.args {
+			removed, err := s.queue.Remove(url)
+			if err != nil {
+				return err
+			}
+			if !removed {
+				missing = append(missing, url)
+			}
+		}
+		return notFound("not queued", missing)
+	})
+}
+
+func blacklistListCommand(a *app, o *options) error {
+	return withStore(a, o, func(appCfg *cfg.Config, s *store) error {
+		entries := s.blacklist.Entries()
+		urls := make([]string, 0, len(entries))
+		for url, reason := range entries {
+			if strings.HasPrefix(reason, o.reason) {
+				urls = append(urls, url)
+			}
+		}
+		sort.Strings(urls)
+		for _, url := range urls {
+			fmt.Fprintf(a.stdout, "%s\t%s\n", url, entries[url])
+		}
+		return nil
+	})
+}
+
+// blacklistRemoveCommand also forgets the urls were seen, so the crawl queues them again when a page links to them
+func blacklistRemoveCommand(a *app, o *options) error {
+	return withStore(a, o, func(appCfg *cfg.Config, s *store) error {
+		var missing []string
+		for _, url := range o.args {
+			if !s.blacklist.DoesExist(url) {
+				missing = append(missing, url)
+				continue
+			}
+			if err := s.blacklist.RemoveFromList(url); err != nil {
+				return err
+			}
+			if err := s.seen.Remove(url); err != nil {
+				return err
+			}
+		}
+		return notFound("not blacklisted", missing)
+	})
+}
+
+func notFound(what string, urls []string) error {
+	if len(urls) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s: %s", what, strings.Join(urls, ", "))
+}
+
+func configCheckCommand(a *app, o *options) error {
+	if _, err := loadConfig(a, o); err != nil {
+		return err
+	}
+	fmt.Fprintf(a.stdout, "%s is valid\n", o.configPath)
+	return nil
+}
+
+// configPrintCommand hides the credentials, the output is meant to be shared when asking for help
+func configPrintCommand(a *app, o *options) error {
+	var appCfg *cfg.Config
+	var err error
+	if o.effective {
+		appCfg, err = loadConfig(a, o)
+	} else {
+		appCfg, err = cfg.LoadFile(o.configPath)
+	}
+	if err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(appCfg.Redacted())
+	if err != nil {
+		return err
+	}
+	_, err = a.stdout.Write(data)
+	return err
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"crawler/internal/cfg"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Exit codes, a crawl which fetched everything it found exits with exitOK
+const (
+	exitOK          = 0
+	exitError       = 1
+	exitUsage       = 2
+	exitPageErrors  = 3
+	exitInterrupted = 130
+)
+
+const defaultConfigPath = "./configs/config.yaml"
+
+type app struct {
+	stdin   io.Reader
+	stdout  io.Writer
+	stderr  io.Writer
+	environ []string
+}
+
+// options collects the flags of all commands, each command defines only the ones it uses
+type options struct {
+	configPath string
+	overrides  []override
+	seedsFile  string
+	output     string
+	limit      int
+	reason     string
+	body       bool
+	effective  bool
+	args       []string
+}
+
+type override struct {
+	key, value string
+}
+
+type command struct {
+	name    string
+	args    string
+	summary string
+	// minArgs and maxArgs limit the positional arguments, maxArgs < 0 means no limit
+	minArgs, maxArgs int
+	flags            func(fs *flag.FlagSet, o *options)
+	run              func(a *app, o *options) error
+}
+
+var commands = []*command{
+	{
+		name: "crawl", args: "<url>...", summary: "start or extend the crawl with the seeds",
+		maxArgs: -1,
+		flags: func(fs *flag.FlagSet, o *options) {
+			fs.StringVar(&o.seedsFile, "seeds", "", "file with a seed url or a JSON seed per line, - reads the standard input")
+		},
+		run: crawlCommand,
+	},
+	{name: "resume", summary: "continue the crawl of the saved seeds", run: resumeCommand},
+	{name: "status", summary: "print the counters of the crawl", run: statusCommand},
+	{
+		name: "export", summary: "write the page records as JSON lines",
+		flags: func(fs *flag.FlagSet, o *options) {
+			fs.StringVar(&o.output, "output", "", "output file instead of the standard output")
+		},
+		run: exportCommand,
+	},
+	{
+		name: "inspect", args: "<url>", summary: "print what is known about the url",
+		minArgs: 1, maxArgs: 1,
+		flags: func(fs *flag.FlagSet, o *options) {
+			fs.BoolVar(&o.body, "body", false, "print the stored body instead")
+		},
+		run: inspectCommand,
+	},
+	{
+		name: "queue ls", summary: "list the queued urls, the next one first",
+		flags: func(fs *flag.FlagSet, o *options) {
+			fs.IntVar(&o.limit, "limit", 100, "number of urls, 0 lists all")
+		},
+		run: queueListCommand,
+	},
+	{name: "queue add", args: "<url>...", summary: "queue the urls with the seed priority", minArgs: 1, maxArgs: -1, run: queueAddCommand},
+	{name: "queue rm", args: "<url>...", summary: "drop the urls from the queue", minArgs: 1, maxArgs: -1, run: queueRemoveCommand},
+	{
+		name: "blacklist ls", summary: "list the blacklisted urls with the reasons",
+		flags: func(fs *flag.FlagSet, o *options) {
+			fs.StringVar(&o.reason, "reason", "", "only the reasons starting with the prefix, e.g. trap")
+		},
+		run: blacklistListCommand,
+	},
+	{name: "blacklist rm", args: "<url>...", summary: "unblacklist the urls, they are crawled again when found", minArgs: 1, maxArgs: -1, run: blacklistRemoveCommand},
+	{name: "config check", summary: "validate the configuration", run: configCheckCommand},
+	{
+		name: "config print", summary: "print the configuration file filled up with the defaults",
+		flags: func(fs *flag.FlagSet, o *options) {
+			fs.BoolVar(&o.effective, "effective", false, "include the environment and the flag overrides")
+		},
+		run: configPrintCommand,
+	},
+}
+
+// usageError is reported with the usage of the command and exitUsage
+type usageError struct {
+	err error
+}
+
+func (e usageError) Error() string { return e.err.Error() }
+
+// exitCodeError ends the command with the code, the message is printed when set
+type exitCodeError struct {
+	code int
+	msg  string
+}
+
+func (e exitCodeError) Error() string { return e.msg }
+
+// run executes the command line and returns the exit code
+func run(a *app, args []string) int {
+	cmd, o, err := parseArgs(args, a.stderr)
+	if o != nil {
+		o.configPath = configPath(o, a.environ)
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	if err != nil {
+		fmt.Fprintln(a.stderr, "Error:", err)
+		if cmd != nil {
+			fmt.Fprintf(a.stderr, "Run 'crawler %s --help' for usage.\n", cmd.name)
+		} else {
+			fmt.Fprintln(a.stderr, "Run 'crawler --help' for usage.")
+		}
+		return exitUsage
+	}
+
+	err = cmd.run(a, o)
+	var usageErr usageError
+	var exitErr exitCodeError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &usageErr):
+		fmt.Fprintln(a.stderr, "Error:", err)
+		fmt.Fprintf(a.stderr, "Run 'crawler %s --help' for usage.\n", cmd.name)
+		return exitUsage
+	case errors.As(err, &exitErr):
+		if exitErr.msg != "" {
+			fmt.Fprintln(a.stderr, exitErr.msg)
+		}
+		return exitErr.code
+	default:
+		fmt.Fprintln(a.stderr, "Error:", err)
+		return exitError
+	}
+}
+
+// parseArgs finds the command and parses its flags, flag.ErrHelp is returned once the help is printed
+func parseArgs(args []string, output io.Writer) (*command, *options, error) {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "-help" || args[0] == "--help" {
+		printUsage(output)
+		if len(args) == 0 {
+			return nil, nil, errors.New("no command given")
+		}
+		return nil, nil, flag.ErrHelp
+	}
+
+	cmd, rest := findCommand(args)
+	if cmd == nil {
+		return nil, nil, fmt.Errorf("unknown command %q", strings.Join(args[:min(2, len(args))], " "))
+	}
+
+	o := &options{}
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.StringVar(&o.configPath, "config", "", fmt.Sprintf("configuration file, %s or %s by default", cfg.EnvConfigPath, defaultConfigPath))
+	if cmd.flags != nil {
+		cmd.flags(fs, o)
+	}
+	for _, s := range cfg.Settings() {
+		fs.Var(&settingFlag{setting: s, o: o}, settingFlagName(s.Key), fmt.Sprintf("override %s (%s)", s.Key, s.Type))
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(output, "Usage: crawler %s [flags] %s\n\n%s.\n\nFlags:\n", cmd.name, cmd.args, capitalize(cmd.summary))
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(interleaved(fs, rest)); err != nil {
+		return cmd, nil, err
+	}
+	o.args = fs.Args()
+	if len(o.args) < cmd.minArgs || (cmd.maxArgs >= 0 && len(o.args) > cmd.maxArgs) {
+		return cmd, nil, fmt.Errorf("wrong number of arguments, expected %s", argsDescription(cmd))
+	}
+	return cmd, o, nil
+}
+
+// findCommand matches the one or two word command name
+func findCommand(args []string) (*command, []string) {
+	for _, cmd := range commands {
+		words := strings.Fields(cmd.name)
+		if len(args) >= len(words) && strings.Join(args[:len(words)], " ") == cmd.name {
+			return cmd, args[len(words):]
+		}
+	}
+	return nil, nil
+}
+
+// interleaved moves the flags given after the positional arguments in front of them,
+// flag.FlagSet stops at the first positional argument otherwise
+func interleaved(fs *flag.FlagSet, args []string) []string {
+	var flags, positional []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			positional = append(positional, args[i+1:]...)
+			break
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			positional = append(positional, arg)
+			continue
+		}
+		flags = append(flags, arg)
+		name := strings.TrimLeft(arg, "-")
+		if strings.Contains(name, "=") {
+			continue
+		}
+		// the value of a non-boolean flag is the next argument
+		if f := fs.Lookup(name); f != nil && !isBoolFlag(f) && i+1 < len(args) {
+			i++
+			flags = append(flags, args[i])
+		}
+	}
+	return append(append(flags, "--"), positional...)
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+func argsDescription(cmd *command) string {
+	if cmd.args == "" {
+		return "none"
+	}
+	return cmd.args
+}
+
+func printUsage(output io.Writer) {
+	fmt.Fprintln(output, "Usage: crawler <command> [flags] [arguments]")
+	fmt.Fprintln(output, "\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(output, "  %-14s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(output, "\nEvery command takes --config <file> and a flag per setting, e.g. --parallelism 4 or --warc-dir ./warc.")
+	fmt.Fprintf(output, "The settings are also read from the environment, e.g. %s=4.\n", cfg.EnvName("parallelism"))
+	fmt.Fprintln(output, "Run 'crawler <command> --help' for the flags of the command.")
+}
+
+// settingFlag collects the override of a config setting, the overrides are applied once the file is read
+type settingFlag struct {
+	setting cfg.Setting
+	o       *options
+	value   string
+}
+
+func (f *settingFlag) String() string { return f.value }
+
+func (f *settingFlag) Set(value string) error {
+	// the type is checked right away, so a typo is a usage error
+	var probe cfg.Config
+	if err := probe.Set(f.setting.Key, value); err != nil {
+		return err
+	}
+	f.value = value
+	f.o.overrides = append(f.o.overrides, override{key: f.setting.Key, value: value})
+	return nil
+}
+
+func (f *settingFlag) IsBoolFlag() bool { return f.setting.Type == "bool" }
+
+// settingFlagName turns "warc.max_size_mb" to "warc-max-size-mb"
+func settingFlagName(key string) string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(key)
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// configPath is the --config flag, the CRAWLER_CONFIG variable or the default path
+func configPath(o *options, environ []string) string {
+	if o.configPath != "" {
+		return o.configPath
+	}
+	for _, kv := range environ {
+		if name, value, _ := strings.Cut(kv, "="); name == cfg.EnvConfigPath && value != "" {
+			return value
+		}
+	}
+	return defaultConfigPath
+}
+
+// loadConfig applies the file over the defaults, then the environment, then the flags, and validates the result
+func loadConfig(a *app, o *options) (*cfg.Config, error) {
+	appCfg, err := cfg.Load(o.configPath, a.environ)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config: %w", err)
+	}
+	for _, ov := range o.overrides {
+		if err := appCfg.Set(ov.key, ov.value); err != nil {
+			return nil, err
+		}
+	}
+	if err := appCfg.Validate(); err != nil {
+		return nil, err
+	}
+	return appCfg, nil
+}
+
+// withStore runs fn with the config and the opened database
+func withStore(a *app, o *options, fn func(appCfg *cfg.Config, s *store) error) error {
+	appCfg, err := loadConfig(a, o)
+	if err != nil {
+		return err
+	}
+	s, err := openStore(appCfg)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	return fn(appCfg, s)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseArgs(t *testing.T) {
+	var parseTest = []struct {
+		name     string
+		args     []string
+		wantCmd  string
+		wantOpts options
+		wantErr  bool
+		wantHelp bool
+	}{
+		{
+			name:     "crawl with seeds",
+			args:     []string{"crawl", "http://a.com/", "http://b.com/"},
+			wantCmd:  "crawl",
+			wantOpts: options{args: []string{"http://a.com/", "http://b.com/"}},
+		},
+		{
+			name:    "flags after the arguments",
+			args:    []string{"crawl", "http://a.com/", "--config", "my.yaml", "--seeds=-", "--parallelism", "4", "--mirror"},
+			wantCmd: "crawl",
+			wantOpts: options{
+				configPath: "my.yaml",
+				seedsFile:  "-",
+				overrides:  []override{{key: "parallelism", value: "4"}, {key: "mirror", value: "true"}},
+				args:       []string{"http://a.com/"},
+			},
+		},
+		{
+			name:     "nested setting",
+			args:     []string{"resume", "--warc-max-size-mb=10"},
+			wantCmd:  "resume",
+			wantOpts: options{overrides: []override{{key: "warc.max_size_mb", value: "10"}}, args: []string{}},
+		},
+		{
+			name:     "two word command",
+			args:     []string{"queue", "ls", "--limit", "5"},
+			wantCmd:  "queue ls",
+			wantOpts: options{limit: 5, args: []string{}},
+		},
+		{
+			name:     "inspect",
+			args:     []string{"inspect", "-body", "http://a.com/"},
+			wantCmd:  "inspect",
+			wantOpts: options{body: true, args: []string{"http://a.com/"}},
+		},
+		{name: "no command", args: nil, wantErr: true},
+		{name: "unknown command", args: []string{"fly"}, wantErr: true},
+		{name: "unknown subcommand", args: []string{"queue", "flush"}, wantErr: true},
+		{name: "unknown flag", args: []string{"status", "--verbose"}, wantCmd: "status", wantErr: true},
+		{name: "setting of a wrong type", args: []string{"status", "--parallelism", "many"}, wantCmd: "status", wantErr: true},
+		{name: "missing argument", args: []string{"inspect"}, wantCmd: "inspect", wantErr: true},
+		{name: "extra argument", args: []string{"status", "now"}, wantCmd: "status", wantErr: true},
+		{name: "help", args: []string{"--help"}, wantHelp: true},
+		{name: "command help", args: []string{"queue", "rm", "-h"}, wantCmd: "queue rm", wantHelp: true},
+	}
+
+	for _, tt := range parseTest {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd, o, err := parseArgs(tt.args, io.Discard)
+			if tt.wantHelp {
+				if !errors.Is(err, flag.ErrHelp) {
+					t.Fatalf("got %v, want flag.ErrHelp", err)
+				}
+				return
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantCmd == "" {
+				if cmd != nil {
+					t.Errorf("got command %s", cmd.name)
+				}
+			} else if cmd == nil || cmd.name != tt.wantCmd {
+				t.Fatalf("got command %v, want %s", cmd, tt.wantCmd)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(*o, tt.wantOpts) {
+				t.Errorf("got %+v, want %+v", *o, tt.wantOpts)
+			}
+		})
+	}
+}
+
+func TestConfigPath(t *testing.T) {
+	env := []string{"HOME=/root", "CRAWLER_CONFIG=/etc/crawler.yaml"}
+	if got := configPath(&options{configPath: "my.yaml"}, env); got != "my.yaml" {
+		t.Errorf("the flag is ignored, got %s", got)
+	}
+	if got := configPath(&options{}, env); got != "/etc/crawler.yaml" {
+		t.Errorf("the environment is ignored, got %s", got)
+	}
+	if got := configPath(&options{}, nil); got != defaultConfigPath {
+		t.Errorf("got %s, want the default", got)
+	}
+}
+
+func TestRunExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	config := "database_file: " + filepath.Join(dir, "crawler.db") + "\napi_addr: localhost:0\n"
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var runTest = []struct {
+		args       []string
+		wantCode   int
+		wantStdout string
+	}{
+		{args: []string{"fly"}, wantCode: exitUsage},
+		{args: []string{"help"}, wantCode: exitOK},
+		{args: []string{"crawl", "--config", configPath}, wantCode: exitUsage},
+		{args: []string{"crawl", "--config", configPath, "/relative"}, wantCode: exitUsage},
+		{args: []string{"status", "--config", filepath.Join(dir, "missing.yaml")}, wantCode: exitError},
+		{args: []string{"resume", "--config", configPath}, wantCode: exitError},
+		{args: []string{"queue", "add", "--config", configPath, "http://a.com/x", "http://a.com/y"}, wantCode: exitOK},
+		{args: []string{"queue", "rm", "--config", configPath, "http://a.com/y"}, wantCode: exitOK},
+		{args: []string{"queue", "rm", "--config", configPath, "http://a.com/y"}, wantCode: exitError},
+		{args: []string{"queue", "ls", "--config", configPath}, wantCode: exitOK, wantStdout: "http://a.com/x\t0\tseed\n"},
+		{args: []string{"status", "--config", configPath}, wantCode: exitOK, wantStdout: "Queued:      1\n"},
+		{args: []string{"blacklist", "rm", "--config", configPath, "http://a.com/x"}, wantCode: exitError},
+	}
+
+	for _, tt := range runTest {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			var stdout bytes.Buffer
+			code := run(&app{stdin: strings.NewReader(""), stdout: &stdout, stderr: io.Discard}, tt.args)
+			if code != tt.wantCode {
+				t.Errorf("got exit code %d, want %d", code, tt.wantCode)
+			}
+			if !strings.Contains(stdout.String(), tt.wantStdout) {
+				t.Errorf("got output %q, want it to contain %q", stdout.String(), tt.wantStdout)
+			}
+		})
+	}
+}
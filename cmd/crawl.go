@@ -0,0 +1,361 @@
+package main
+
+import (
+	"crawler/internal/apistats"
+	"crawler/internal/cfg"
+	"crawler/internal/control"
+	"crawler/internal/events"
+	"crawler/internal/fetcher"
+	"crawler/internal/layout"
+	"crawler/internal/metrics"
+	"crawler/internal/parser"
+	"crawler/internal/profile"
+	"crawler/internal/seed"
+	"crawler/internal/simhash"
+	"crawler/internal/storage"
+	"crawler/internal/trap"
+	"crawler/internal/warc"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+)
+
+// recentErrorsKept is the number of the failures the dashboard shows when it is opened
+const recentErrorsKept = 100
+
+func crawlCommand(a *app, o *options) error {
+	seeds, err := seed.FromURLs(o.args)
+	if err != nil {
+		return usageError{err}
+	}
+	if o.seedsFile != "" {
+		var fileSeeds []seed.Seed
+		if o.seedsFile == "-" {
+			fileSeeds, err = seed.Read(a.stdin)
+		} else {
+			fileSeeds, err = seed.ReadFile(o.seedsFile)
+		}
+		if err != nil {
+			return fmt.Errorf("error reading seeds: %w", err)
+		}
+		seeds = append(seeds, fileSeeds...)
+	}
+	if len(seeds) == 0 {
+		return usageError{errors.New("no seeds given")}
+	}
+
+	return withStore(a, o, func(appCfg *cfg.Config, s *store) error {
+		if err := s.seeds.Save(seeds); err != nil {
+			return fmt.Errorf("unable to save the seeds: %w", err)
+		}
+		return crawl(a, o, appCfg, s)
+	})
+}
+
+func resumeCommand(a *app, o *options) error {
+	return withStore(a, o, func(appCfg *cfg.Config, s *store) error {
+		return crawl(a, o, appCfg, s)
+	})
+}
+
+// crawl runs the crawl of all the saved seeds until the queue is drained or a signal stops it
+func crawl(a *app, o *options, appCfg *cfg.Config, s *store) error {
+	seeds, err := s.seeds.All()
+	if err != nil {
+		return fmt.Errorf("unable to load the seeds: %w", err)
+	}
+	if len(seeds) == 0 {
+		return errors.New("nothing to resume, start with the crawl command")
+	}
+
+	logger := log.New(a.stdout, "crawler: ", log.LstdFlags|log.Lshortfile)
+
+	// an empty downloads dir disables saving the files, e.g. when only the WARC output is needed
+	if _, err := os.Stat(appCfg.DownloadsDir); appCfg.DownloadsDir != "" && os.IsNotExist(err) {
+		err := os.Mkdir(appCfg.DownloadsDir, os.ModePerm)
+		if err != nil {
+			return fmt.Errorf("could not create download location: %w", err)
+		}
+	}
+
+	p := parser.NewParser()
+	f := fetcher.NewWebFetcher(appCfg.AcceptableMimeTypes)
+	f.SetCookieJar(s.cookies)
+	transport, err := fetcher.NewTransport(fetcher.TransportConfig{
+		ProxyURL:           appCfg.Proxy.URL,
+		NoProxy:            appCfg.Proxy.Bypass,
+		CAFile:             appCfg.TLS.CAFile,
+		CertFile:           appCfg.TLS.CertFile,
+		KeyFile:            appCfg.TLS.KeyFile,
+		MinTLSVersion:      appCfg.TLS.MinVersion,
+		InsecureSkipVerify: appCfg.TLS.InsecureSkipVerify,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to configure the transport: %w", err)
+	}
+	f.SetTransport(transport)
+	for _, auth := range appCfg.Auth {
+		f.SetHostAuth(auth.Host, fetcher.HostAuth{
+			Username:    auth.Username,
+			Password:    auth.Password,
+			BearerToken: auth.BearerToken,
+			Cookies:     auth.Cookies,
+		})
+	}
+
+	profiles, err := newProfiles(appCfg)
+	if err != nil {
+		return err
+	}
+	f.SetProfiles(profiles)
+
+	var pageFetcher fetcher.Fetcher = f
+	if len(appCfg.ReplayWarcs) > 0 {
+		var warcFiles []string
+		for _, pattern := range appCfg.ReplayWarcs {
+			matches, err := filepath.Glob(pattern)
+			if err != nil {
+				return fmt.Errorf("invalid replay pattern: %w", err)
+			}
+			warcFiles = append(warcFiles, matches...)
+		}
+		replay, err := fetcher.NewReplayFetcher(appCfg.AcceptableMimeTypes, warcFiles...)
+		if err != nil {
+			return fmt.Errorf("unable to load the replay archives: %w", err)
+		}
+		replay.SetProfiles(profiles)
+		pageFetcher = replay
+		logger.Printf("replaying %d WARC files", len(warcFiles))
+	} else {
+		for _, auth := range appCfg.Auth {
+			if auth.FormLogin == nil {
+				continue
+			}
+			if err := f.Login(auth.FormLogin.URL, auth.FormLogin.Fields); err != nil {
+				return fmt.Errorf("unable to log in to %s: %w", auth.Host, err)
+			}
+		}
+	}
+
+	stopChan := make(chan bool, 1)
+	crawler := fetcher.NewCrawler(logger, appCfg.Parallelism, p, pageFetcher, s.links, s.queue, s.blacklist, appCfg.DownloadsDir)
+
+	strategy, err := layout.NewStrategy(appCfg.DownloadsLayout)
+	if err != nil {
+		return err
+	}
+	crawler.SetLayout(strategy)
+	if appCfg.Mirror {
+		crawler.EnableMirror()
+	}
+
+	crawler.SetProfiles(profiles)
+	collector := metrics.NewCollector()
+	crawler.SetMetrics(collector)
+	registry := metrics.NewRegistry()
+	f.SetMetrics(metrics.NewFetchMetrics(registry))
+	metrics.RegisterCollector(registry, collector)
+	registry.NewGaugeFunc("crawler_queue_depth", "URLs waiting in the queue.", func() float64 {
+		return float64(s.queue.Size())
+	})
+	registry.NewGaugeFunc("crawler_queue_hosts", "Hosts with URLs waiting in the queue.", func() float64 {
+		return float64(len(s.queue.SizeByHost()))
+	})
+	registry.NewGaugeFunc("crawler_blacklist_size", "Blacklisted URLs.", func() float64 {
+		return float64(s.blacklist.Size())
+	})
+	registry.NewGaugeFunc("crawler_stored_pages", "Pages stored in the database.", func() float64 {
+		return float64(s.links.Size())
+	})
+	crawler.SetSeenSet(s.seen)
+	crawler.SetLinkGraph(s.links)
+	traps := trap.NewDetector(trap.Config{
+		MaxPathDepth:         appCfg.Traps.MaxPathDepth,
+		MaxSegmentRepeats:    appCfg.Traps.MaxSegmentRepeats,
+		MaxQueryCombinations: appCfg.Traps.MaxQueryCombinations,
+		MaxURLsPerPattern:    appCfg.Traps.MaxURLsPerPattern,
+	})
+	// the urls queued before the restart count towards the limits
+	err = s.seen.ForEach(func(url string) error {
+		traps.Check(url)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to restore the trap counters: %w", err)
+	}
+	crawler.SetTrapDetector(traps)
+
+	if appCfg.NearDuplicates.Enabled {
+		index := simhash.NewIndex(appCfg.NearDuplicates.MaxDistance)
+		// the fingerprints of the pages fetched before the restart
+		err = s.links.ForEachPageRecord(func(record *storage.PageRecord) error {
+			if record.SimHash != 0 {
+				index.Insert(record.URL, record.SimHash)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("unable to restore the near-duplicate index: %w", err)
+		}
+		crawler.SetNearDuplicates(index, appCfg.NearDuplicates.SkipLinks)
+	}
+
+	if appCfg.Warc.Dir != "" {
+		warcWriter, err := warc.NewWriter(appCfg.Warc.Dir, appCfg.Warc.Prefix, appCfg.Warc.MaxSizeMB*1024*1024)
+		if err != nil {
+			return fmt.Errorf("unable to create warc writer: %w", err)
+		}
+		defer warcWriter.Close()
+		crawler.SetArchive(warcWriter)
+	}
+
+	apiStats := apistats.NewStatHandler(s.links, s.queue, s.blacklist)
+
+	http.HandleFunc("/", apiStats.Handler)
+	queueByHost := apistats.Labeled(s.queue, s.queue.SizeByHost)
+	http.HandleFunc("/api/v1/stats", apistats.NewStatsHandler(collector, s.links, queueByHost, s.blacklist).Handler)
+	http.Handle("/metrics", registry)
+	http.HandleFunc("/api/v1/events", events.NewSSEHandler(crawler).Handler)
+	recentErrors := events.NewRecorder(recentErrorsKept)
+	go recentErrors.Record(crawler.Subscribe(events.Filter{Types: []string{events.TypeFailed}}, recentErrorsKept))
+	http.HandleFunc("/api/v1/errors", recentErrors.Handler)
+	http.HandleFunc("/api/v1/queue", apistats.NewQueueHandler(s.queue).Handler)
+	http.HandleFunc("/api/v1/hosts", apistats.NewHostsHandler(collector, queueByHost).Handler)
+	http.HandleFunc("/api/v1/blacklist", apistats.NewBlacklistJSONHandler(s.blacklist).Handler)
+	// the stored pages may come from logged in sessions, they need the token unless made public
+	pages := func(h http.HandlerFunc) http.HandlerFunc {
+		if appCfg.PublicPages {
+			return h
+		}
+		return control.RequireToken(appCfg.ApiToken, h)
+	}
+	http.HandleFunc("/api/v1/page", pageInspector(appCfg, apistats.NewPageHandler(s.links, p, s.queue, s.blacklist)))
+	http.HandleFunc("/api/v1/pages", pages(apistats.NewPageListHandler(s.links).Handler))
+	http.HandleFunc("/api/v1/pages/body", pages(apistats.NewPageBodyHandler(s.links).Handler))
+	http.HandleFunc("/api/v1/pages/links", pages(apistats.NewPageLinksHandler(s.links).Handler))
+	http.HandleFunc("/api/v1/pages/archive", pages(apistats.NewArchiveHandler(s.links).Handler))
+	http.HandleFunc("/dashboard", apistats.NewDashboardHandler().Handler)
+	control.NewHandler(crawlController{Crawler: crawler, seeds: s.seeds}, appCfg.ApiToken).Register(http.DefaultServeMux)
+	http.HandleFunc("/duplicates", apistats.NewDuplicatesHandler(s.links).Handler)
+	http.HandleFunc("/blacklist", apistats.NewBlacklistHandler(s.blacklist).Handler)
+	http.HandleFunc("/near-duplicates", apistats.NewNearDuplicatesHandler(s.links).Handler)
+	go func() {
+		err := http.ListenAndServe(appCfg.ApiAddr, nil)
+		if err != nil {
+			fmt.Fprintln(a.stderr, "Error starting the server:", err)
+		}
+	}()
+
+	reloads := newReloader(a, o, appCfg, profiles, logger)
+	stopReloads := make(chan struct{})
+	defer close(stopReloads)
+	go reloads.watch(reloadInterval, stopReloads)
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(sigCh)
+	go func() {
+		sig := <-sigCh
+		logger.Printf("Received signal: %s", sig)
+		stopChan <- true
+		// the requests are finished first, a second signal does not wait for them
+		sig = <-sigCh
+		logger.Printf("Received signal: %s again, exiting without saving the state", sig)
+		os.Exit(exitInterrupted)
+	}()
+
+	summary := crawler.Crawl(seeds, stopChan)
+	fmt.Fprintf(a.stderr, "fetched %d pages, %d failed\n", summary.Fetched, summary.Failed)
+	switch {
+	case summary.Interrupted:
+		return exitCodeError{code: exitInterrupted, msg: "the crawl was interrupted, continue it with the resume command"}
+	case summary.Failed > 0:
+		return exitCodeError{code: exitPageErrors}
+	}
+	return nil
+}
+
+// pageInspector serves the whole page to the token holders and, so the dashboard works without a token,
+// the page without its body and links to the others. A wrong token is refused like by the control API.
+func pageInspector(appCfg *cfg.Config, h *apistats.PageHandler) http.HandlerFunc {
+	if appCfg.PublicPages {
+		return h.Handler
+	}
+	full := control.RequireToken(appCfg.ApiToken, h.Handler)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			full(w, r)
+			return
+		}
+		h.LimitedHandler(w, r)
+	}
+}
+
+// crawlController saves the seeds added through the API, so the resumed crawl keeps their scope
+type crawlController struct {
+	*fetcher.Crawler
+	seeds *storage.SeedRepository
+}
+
+// AddSeeds saves the seeds only once the crawl took them, the seeds refused by a finished crawl or not
+// reached after a failed push are not resumed
+func (c crawlController) AddSeeds(seeds []seed.Seed) (int, error) {
+	queued := 0
+	var err error
+	accepted := make([]seed.Seed, 0, len(seeds))
+	for _, s := range seeds {
+		var n int
+		// the seeds are added one by one, so the ones taken before an error are known
+		if n, err = c.Crawler.AddSeeds([]seed.Seed{s}); err != nil {
+			break
+		}
+		queued += n
+		accepted = append(accepted, s)
+	}
+	if len(accepted) == 0 {
+		return queued, err
+	}
+	return queued, errors.Join(err, c.seeds.Save(accepted))
+}
+
+// newProfiles resolves the settings of every host from the global ones and the hosts section of the config
+func newProfiles(appCfg *cfg.Config) (*profile.Resolver, error) {
+	return profile.NewResolver(profileRules(appCfg))
+}
+
+func profileRules(appCfg *cfg.Config) (profile.Profile, []profile.Rule) {
+	defaults := profile.Profile{
+		Delay:               appCfg.Delay,
+		Headers:             appCfg.Headers,
+		AcceptableMimeTypes: appCfg.AcceptableMimeTypes,
+		MaxBodySize:         appCfg.MaxBodySize,
+		Timeout:             appCfg.Timeout,
+	}
+	rules := make([]profile.Rule, 0, len(appCfg.Hosts))
+	for _, host := range appCfg.Hosts {
+		rule := profile.Rule{Match: host.Match, Profile: profile.Profile{
+			Parallelism:         host.Parallelism,
+			Delay:               host.Delay,
+			Headers:             host.Headers,
+			AcceptableMimeTypes: host.AcceptableMimeTypes,
+			MaxBodySize:         host.MaxBodySize,
+			Timeout:             host.Timeout,
+			InScope:             host.InScope,
+		}}
+		if host.Auth != nil {
+			rule.Profile.Auth = &profile.Auth{
+				Username:    host.Auth.Username,
+				Password:    host.Auth.Password,
+				BearerToken: host.Auth.BearerToken,
+				Cookies:     host.Auth.Cookies,
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return defaults, rules
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"crawler/internal/apistats"
+	"crawler/internal/cfg"
+	"crawler/internal/parser"
+	"crawler/internal/storage"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestPageInspector(t *testing.T) {
+	appCfg := cfg.Default()
+	appCfg.DatabaseFile = filepath.Join(t.TempDir(), "test.db")
+	s, err := openStore(appCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	body := `<a href="/next">next</a>`
+	if err := s.links.SaveByKey("http://a.com/", []byte(body)); err != nil {
+		t.Fatal(err)
+	}
+	record := &storage.PageRecord{URL: "http://a.com/", StatusCode: 200, Size: int64(len(body)), Header: http.Header{"Content-Type": {"text/html"}}}
+	if err := s.links.SavePageRecord(record); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(appCfg *cfg.Config, authorization string) (int, apistats.Page) {
+		handler := pageInspector(appCfg, apistats.NewPageHandler(s.links, parser.NewParser(), s.queue, s.blacklist))
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/page?url=http://a.com/", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		var page apistats.Page
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rec.Code, page
+	}
+
+	// the dashboard sends no token with the default config, it gets the limited view
+	status, page := get(appCfg, "")
+	if status != http.StatusOK || !page.Limited || page.Body != "" || len(page.Links) != 0 || page.BodySize != len(body) {
+		t.Errorf("default config: got %d %+v, want the limited page", status, page)
+	}
+	if status, _ := get(appCfg, "Bearer guess"); status != http.StatusForbidden {
+		t.Errorf("default config with a token: got %d, want %d", status, http.StatusForbidden)
+	}
+
+	withToken := *appCfg
+	withToken.ApiToken = "secret"
+	if status, _ := get(&withToken, "Bearer wrong"); status != http.StatusUnauthorized {
+		t.Errorf("wrong token: got %d, want %d", status, http.StatusUnauthorized)
+	}
+	status, page = get(&withToken, "Bearer secret")
+	if status != http.StatusOK || page.Limited || page.Body != body || len(page.Links) != 1 {
+		t.Errorf("right token: got %d %+v, want the whole page", status, page)
+	}
+
+	public := *appCfg
+	public.PublicPages = true
+	if status, page := get(&public, ""); status != http.StatusOK || page.Limited || page.Body != body {
+		t.Errorf("public pages: got %d %+v, want the whole page", status, page)
+	}
+}
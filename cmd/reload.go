@@ -0,0 +1,107 @@
+package main
+
+import (
+	"crawler/internal/cfg"
+	"crawler/internal/profile"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// reloadInterval is how often the config file is checked for changes
+const reloadInterval = 2 * time.Second
+
+// reloader applies the changes of the config file to the running crawl through the host profiles
+type reloader struct {
+	a        *app
+	o        *options
+	profiles *profile.Resolver
+	logger   *log.Logger
+
+	mu sync.Mutex
+	// current is the config in effect, the changes needing a restart are not in it
+	current *cfg.Config
+}
+
+func newReloader(a *app, o *options, current *cfg.Config, profiles *profile.Resolver, logger *log.Logger) *reloader {
+	return &reloader{a: a, o: o, current: current, profiles: profiles, logger: logger}
+}
+
+// watch reloads the config when the file changes or on SIGHUP until stop is closed
+func (r *reloader) watch(interval time.Duration, stop <-chan struct{}) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last, _ := os.Stat(r.o.configPath)
+	for {
+		select {
+		case <-stop:
+			return
+		case <-hup:
+			r.logger.Printf("received SIGHUP, reloading %s", r.o.configPath)
+			r.reload()
+		case <-ticker.C:
+			info, err := os.Stat(r.o.configPath)
+			if err != nil || last != nil && info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size() {
+				continue
+			}
+			last = info
+			r.logger.Printf("%s changed, reloading", r.o.configPath)
+			r.reload()
+		}
+	}
+}
+
+// reload reads the config like at the start and applies the reloadable settings at once.
+// An invalid config is rejected as a whole and the running one is kept.
+func (r *reloader) reload() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	loaded, err := loadConfig(r.a, r.o)
+	if err != nil {
+		r.logger.Printf("config reload rejected, keeping the running config: %s", err)
+		return
+	}
+	// the settings read through the host profiles on every request
+	next := *r.current
+	next.AcceptableMimeTypes = loaded.AcceptableMimeTypes
+	next.Delay = loaded.Delay
+	next.Headers = loaded.Headers
+	next.MaxBodySize = loaded.MaxBodySize
+	next.Timeout = loaded.Timeout
+	next.Hosts = loaded.Hosts
+
+	applied, err := cfg.Diff(r.current, &next)
+	if err != nil {
+		r.logger.Printf("config reload rejected: %s", err)
+		return
+	}
+	if len(applied) > 0 {
+		if err := r.profiles.Reload(profileRules(&next)); err != nil {
+			r.logger.Printf("config reload rejected, keeping the running config: %s", err)
+			return
+		}
+		r.current = &next
+		for _, change := range applied {
+			r.logger.Printf("config reloaded %s", change)
+		}
+	}
+
+	pending, err := cfg.Diff(r.current, loaded)
+	if err != nil {
+		return
+	}
+	for _, change := range pending {
+		r.logger.Printf("config change needs a restart %s", change)
+	}
+	if len(applied) == 0 && len(pending) == 0 {
+		r.logger.Print("config reloaded, nothing changed")
+	}
+}
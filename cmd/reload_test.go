@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("parallelism: 2\n")
+	a := &app{stdin: strings.NewReader(""), stdout: io.Discard, stderr: io.Discard}
+	o := &options{configPath: path}
+	appCfg, err := loadConfig(a, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	profiles, err := newProfiles(appCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var logs bytes.Buffer
+	r := newReloader(a, o, appCfg, profiles, log.New(&logs, "", 0))
+
+	write("parallelism: 4\ndelay: 1s\nhosts:\n  - match: \"*.a.com\"\n    parallelism: 1\n    timeout: 5s\n")
+	r.reload()
+	if p := profiles.For("www.a.com"); p.Delay != time.Second || p.Parallelism != 1 || p.Timeout != 5*time.Second {
+		t.Errorf("the profile is not reloaded: %+v", p)
+	}
+	for _, line := range []string{`config reloaded delay: "0s" -> "1s"`, `config reloaded hosts[0].match: <unset> -> "*.a.com"`,
+		`config change needs a restart parallelism: 2 -> 4`} {
+		if !strings.Contains(logs.String(), line) {
+			t.Errorf("%q is not logged in\n%s", line, logs.String())
+		}
+	}
+
+	logs.Reset()
+	write("delay: -1s\n")
+	r.reload()
+	if p := profiles.For("www.a.com"); p.Delay != time.Second {
+		t.Errorf("the invalid config is applied: %+v", p)
+	}
+	if !strings.Contains(logs.String(), "rejected") {
+		t.Errorf("the rejection is not logged:\n%s", logs.String())
+	}
+}
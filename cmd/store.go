@@ -0,0 +1,85 @@
+package main
+
+import (
+	"crawler/internal/cfg"
+	"crawler/internal/frontier"
+	"crawler/internal/storage"
+	"errors"
+	"fmt"
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+// store holds the repositories of the crawl database, every command works on the same set
+type store struct {
+	db        *bolt.DB
+	links     *storage.LinkRepository
+	queue     *storage.FrontierRepository
+	seen      *storage.SeenSet
+	blacklist *storage.BlacklistRepository
+	seeds     *storage.SeedRepository
+	cookies   *storage.CookieRepository
+}
+
+func openStore(appCfg *cfg.Config) (*store, error) {
+	// bbolt locks the file, a second process would wait forever for a running crawl
+	db, err := bolt.Open(appCfg.DatabaseFile, 0600, &bolt.Options{Timeout: time.Second})
+	if errors.Is(err, bolt.ErrTimeout) {
+		return nil, fmt.Errorf("database %s is locked, is a crawl running?", appCfg.DatabaseFile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to open database: %w", err)
+	}
+
+	s, err := newStore(db, appCfg)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func newStore(db *bolt.DB, appCfg *cfg.Config) (*store, error) {
+	s := &store{db: db}
+	var err error
+	if s.links, err = storage.NewLinkRepository(db); err != nil {
+		return nil, fmt.Errorf("unable to create link repository: %w", err)
+	}
+
+	frontierCfg := frontier.Config{
+		DepthWeight:   frontier.Weight(appCfg.Frontier.DepthWeight),
+		HostPenalty:   frontier.Weight(appCfg.Frontier.HostPenalty),
+		SourceWeights: appCfg.Frontier.SourceWeights,
+		MimeWeights:   appCfg.Frontier.MimeWeights,
+	}
+	for _, b := range appCfg.Frontier.Boosts {
+		frontierCfg.Boosts = append(frontierCfg.Boosts, frontier.Boost{Pattern: b.Pattern, Value: b.Boost})
+	}
+	scorer, err := frontier.NewScorer(frontierCfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid frontier config: %w", err)
+	}
+	if s.queue, err = storage.NewFrontierRepository(db, scorer); err != nil {
+		return nil, fmt.Errorf("unable to create queue repository: %w", err)
+	}
+
+	expectedURLs := appCfg.SeenSet.ExpectedURLs
+	if s.seen, err = storage.NewSeenSet(db, expectedURLs, appCfg.SeenSet.FalsePositiveRate); err != nil {
+		return nil, fmt.Errorf("unable to load the seen set: %w", err)
+	}
+	s.queue.SetSeenSet(s.seen)
+	if s.blacklist, err = storage.NewBlacklistRepository(db, expectedURLs, appCfg.SeenSet.FalsePositiveRate); err != nil {
+		return nil, fmt.Errorf("unable to load the blacklist: %w", err)
+	}
+	if s.seeds, err = storage.NewSeedRepository(db); err != nil {
+		return nil, fmt.Errorf("unable to create seed repository: %w", err)
+	}
+	if s.cookies, err = storage.NewCookieRepository(db); err != nil {
+		return nil, fmt.Errorf("unable to create cookie repository: %w", err)
+	}
+	return s, nil
+}
+
+func (s *store) Close() error {
+	return s.db.Close()
+}
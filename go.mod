@@ -3,12 +3,15 @@ module crawler
 go 1.21
 
 require (
+	github.com/stretchr/testify v1.8.1
+	go.etcd.io/bbolt v1.3.8
 	golang.org/x/net v0.21.0
-	golang.org/x/sync v0.6.0
+	golang.org/x/text v0.14.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
-	go.etcd.io/bbolt v1.3.8 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
 	golang.org/x/sys v0.17.0 // indirect
 )
@@ -0,0 +1,367 @@
+package apistats
+
+import (
+	"crawler/internal/metrics"
+	"crawler/internal/parser"
+	"crawler/internal/storage"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// queryLimit reads a non-negative number from the query, def when it is not given
+func queryLimit(r *http.Request, name string, def int) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative number", name)
+	}
+	return n, nil
+}
+
+type QueueLister interface {
+	Counter
+	List(limit int) ([]storage.QueueItem, error)
+}
+
+// QueueResponse is the response of /api/v1/queue
+type QueueResponse struct {
+	Size  int                 `json:"size"`
+	Items []storage.QueueItem `json:"items"`
+}
+
+// defaultListLimit is the length of the lists unless ?limit= is given, 0 lists all
+const defaultListLimit = 100
+
+// QueueHandler lists the queued urls in the order they are fetched
+type QueueHandler struct {
+	Queue QueueLister
+}
+
+func NewQueueHandler(queue QueueLister) *QueueHandler {
+	return &QueueHandler{Queue: queue}
+}
+
+func (qh *QueueHandler) Handler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	limit, err := queryLimit(r, "limit", defaultListLimit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	items, err := qh.Queue.List(limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if items == nil {
+		items = make([]storage.QueueItem, 0)
+	}
+	writeJSON(w, QueueResponse{Size: qh.Queue.Size(), Items: items})
+}
+
+// BlacklistResponse is the response of /api/v1/blacklist, Size counts the entries matching the filter
+type BlacklistResponse struct {
+	Size    int              `json:"size"`
+	Entries []BlacklistEntry `json:"entries"`
+}
+
+type BlacklistEntry struct {
+	URL    string `json:"url"`
+	Reason string `json:"reason"`
+}
+
+type BlacklistWalker interface {
+	ForEachEntry(fn func(url, reason string) error) error
+}
+
+// BlacklistJSONHandler lists the blacklisted urls with the reasons in url order like BlacklistHandler does,
+// ?reason= filters by the reason prefix and ?limit= caps the list. The list is walked, not copied.
+type BlacklistJSONHandler struct {
+	Blacklist BlacklistWalker
+}
+
+func NewBlacklistJSONHandler(blacklist BlacklistWalker) *BlacklistJSONHandler {
+	return &BlacklistJSONHandler{Blacklist: blacklist}
+}
+
+func (bh *BlacklistJSONHandler) Handler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	limit, err := queryLimit(r, "limit", defaultListLimit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter := r.URL.Query().Get("reason")
+	resp := BlacklistResponse{Entries: make([]BlacklistEntry, 0)}
+	err = bh.Blacklist.ForEachEntry(func(u, reason string) error {
+		if !strings.HasPrefix(reason, filter) {
+			return nil
+		}
+		resp.Size++
+		if limit > 0 && len(resp.Entries) == limit {
+			return nil
+		}
+		if reason == "" {
+			reason = "unknown"
+		}
+		resp.Entries = append(resp.Entries, BlacklistEntry{URL: u, Reason: reason})
+		return nil
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+type HostMetrics interface {
+	Hosts() []metrics.HostStats
+}
+
+// HostsResponse is the response of /api/v1/hosts
+type HostsResponse struct {
+	Hosts int `json:"hosts"`
+	// ByHost lists the hosts with the most requests first, then the ones with the most queued urls
+	ByHost []HostRow `json:"by_host"`
+}
+
+type HostRow struct {
+	metrics.HostStats
+	Queued int `json:"queued"`
+}
+
+// HostsHandler reports the requests and the queued urls of every host seen by the crawl
+type HostsHandler struct {
+	Metrics HostMetrics
+	Queue   LabeledCounter
+}
+
+func NewHostsHandler(m HostMetrics, queue LabeledCounter) *HostsHandler {
+	return &HostsHandler{Metrics: m, Queue: queue}
+}
+
+func (hh *HostsHandler) Handler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	limit, err := queryLimit(r, "limit", defaultHostsLimit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows := make(map[string]*HostRow)
+	for _, h := range hh.Metrics.Hosts() {
+		rows[h.Host] = &HostRow{HostStats: h}
+	}
+	for host, size := range hh.Queue.SizeByLabel() {
+		if rows[host] == nil {
+			rows[host] = &HostRow{HostStats: metrics.HostStats{Host: host}}
+		}
+		rows[host].Queued = size
+	}
+	resp := HostsResponse{Hosts: len(rows), ByHost: make([]HostRow, 0, len(rows))}
+	for _, row := range rows {
+		resp.ByHost = append(resp.ByHost, *row)
+	}
+	sort.Slice(resp.ByHost, func(i, j int) bool {
+		a, b := resp.ByHost[i], resp.ByHost[j]
+		ra, rb := a.Fetched+a.Failed, b.Fetched+b.Failed
+		if ra != rb {
+			return ra > rb
+		}
+		return a.Queued > b.Queued || a.Queued == b.Queued && a.Host < b.Host
+	})
+	if limit > 0 && len(resp.ByHost) > limit {
+		resp.ByHost = resp.ByHost[:limit]
+	}
+	writeJSON(w, resp)
+}
+
+type PageStore interface {
+	GetPageRecord(url string) (*storage.PageRecord, error)
+	GetByKey(url string) ([]byte, error)
+}
+
+type LinkParser interface {
+	ParseLinks(body []byte, contentType string) ([]parser.Link, error)
+}
+
+type QueueLookup interface {
+	Get(url string) (storage.QueueItem, bool, error)
+}
+
+type BlacklistLookup interface {
+	Reason(url string) (string, bool)
+}
+
+// maxBodyPreview is the part of the stored body a page response includes
+const maxBodyPreview = 256 << 10
+
+// Page is the response of /api/v1/page, it tells everything known about the url
+type Page struct {
+	URL string `json:"url"`
+	// Record is null when the url was not fetched
+	Record      *storage.PageRecord `json:"record"`
+	Queued      bool                `json:"queued"`
+	Blacklisted string              `json:"blacklisted,omitempty"`
+	// Links are the out-links of the stored body, resolved against the final url
+	Links []OutLink `json:"links"`
+	// Body is the stored text body in UTF-8, binary bodies are left out and only their size is given
+	Body          string `json:"body,omitempty"`
+	BodySize      int    `json:"body_size"`
+	BodyTruncated bool   `json:"body_truncated,omitempty"`
+	// Limited is set when the body and the out-links are left out, see PageHandler.LimitedHandler
+	Limited bool `json:"limited,omitempty"`
+}
+
+type OutLink struct {
+	URL    string `json:"url"`
+	Source string `json:"source"`
+}
+
+// PageHandler is the page inspector: the record, the out-links and the body of ?url=
+type PageHandler struct {
+	Pages     PageStore
+	Parser    LinkParser
+	Queue     QueueLookup
+	Blacklist BlacklistLookup
+}
+
+func NewPageHandler(pages PageStore, p LinkParser, queue QueueLookup, blacklist BlacklistLookup) *PageHandler {
+	return &PageHandler{Pages: pages, Parser: p, Queue: queue, Blacklist: blacklist}
+}
+
+func (ph *PageHandler) Handler(w http.ResponseWriter, r *http.Request) {
+	ph.serve(w, r, false)
+}
+
+// LimitedHandler serves the page without the body and the out-links read from it, e.g. to the clients
+// without a token: the body may come from a logged in session. The record and the size are kept.
+func (ph *PageHandler) LimitedHandler(w http.ResponseWriter, r *http.Request) {
+	ph.serve(w, r, true)
+}
+
+func (ph *PageHandler) serve(w http.ResponseWriter, r *http.Request, limited bool) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	pageURL := r.URL.Query().Get("url")
+	if pageURL == "" {
+		http.Error(w, "no url given", http.StatusBadRequest)
+		return
+	}
+	page, err := ph.Page(pageURL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if page.Record == nil && page.BodySize == 0 && !page.Queued && page.Blacklisted == "" {
+		http.Error(w, fmt.Sprintf("nothing is known about %s", pageURL), http.StatusNotFound)
+		return
+	}
+	if limited {
+		page.Body, page.BodyTruncated, page.Links, page.Limited = "", false, make([]OutLink, 0), true
+	}
+	writeJSON(w, page)
+}
+
+func (ph *PageHandler) Page(pageURL string) (*Page, error) {
+	page := &Page{URL: pageURL, Links: make([]OutLink, 0)}
+	var err error
+	if page.Record, err = ph.Pages.GetPageRecord(pageURL); err != nil {
+		return nil, err
+	}
+	if _, page.Queued, err = ph.Queue.Get(pageURL); err != nil {
+		return nil, err
+	}
+	if reason, ok := ph.Blacklist.Reason(pageURL); ok {
+		page.Blacklisted = reason
+		if reason == "" {
+			page.Blacklisted = "unknown"
+		}
+	}
+
+	body, err := ph.Pages.GetByKey(pageURL)
+	if err != nil || body == nil {
+		return page, err
+	}
+	page.BodySize = len(body)
+	contentType, base := "", pageURL
+	if page.Record != nil {
+		contentType = page.Record.Header.Get("Content-Type")
+		if page.Record.FinalURL != "" {
+			base = page.Record.FinalURL
+		}
+	}
+	if !isText(contentType) {
+		return page, nil
+	}
+
+	if links, err := ph.Parser.ParseLinks(body, contentType); err == nil {
+		page.Links = resolveLinks(base, links)
+	}
+	text, err := parser.ToUTF8(body, contentType)
+	if err != nil {
+		text = body
+	}
+	if len(text) > maxBodyPreview {
+		text, page.BodyTruncated = text[:maxBodyPreview], true
+	}
+	page.Body = string(text)
+	return page, nil
+}
+
+// isText tells whether the body can be shown as text, bodies stored without a record are assumed to be HTML
+func isText(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mimeType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(mimeType, "text/"), strings.HasSuffix(mimeType, "+xml"), strings.HasSuffix(mimeType, "+json"):
+		return true
+	}
+	return mimeType == "application/xml" || mimeType == "application/json" || mimeType == "application/javascript"
+}
+
+// resolveLinks makes the links absolute and drops the duplicates and the ones which are not urls
+func resolveLinks(base string, links []parser.Link) []OutLink {
+	resolved := make([]OutLink, 0, len(links))
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return resolved
+	}
+	seen := make(map[string]bool)
+	for _, l := range links {
+		u, err := baseURL.Parse(strings.TrimSpace(l.URL))
+		if err != nil {
+			continue
+		}
+		u.Fragment = ""
+		if s := u.String(); !seen[s] {
+			seen[s] = true
+			resolved = append(resolved, OutLink{URL: s, Source: l.Source})
+		}
+	}
+	return resolved
+}
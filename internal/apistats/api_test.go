@@ -0,0 +1,225 @@
+package apistats
+
+import (
+	"crawler/internal/metrics"
+	"crawler/internal/parser"
+	"crawler/internal/storage"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+)
+
+type pageStore struct {
+	records map[string]*storage.PageRecord
+	bodies  map[string][]byte
+}
+
+func (ps pageStore) GetPageRecord(url string) (*storage.PageRecord, error) {
+	return ps.records[url], nil
+}
+
+func (ps pageStore) GetByKey(url string) ([]byte, error) {
+	return ps.bodies[url], nil
+}
+
+type queueLookup map[string]bool
+
+func (q queueLookup) Get(url string) (storage.QueueItem, bool, error) {
+	return storage.QueueItem{URL: url}, q[url], nil
+}
+
+type blacklist map[string]string
+
+func (b blacklist) Reason(url string) (string, bool) {
+	reason, ok := b[url]
+	return reason, ok
+}
+
+func (b blacklist) ForEachEntry(fn func(url, reason string) error) error {
+	urls := make([]string, 0, len(b))
+	for u := range b {
+		urls = append(urls, u)
+	}
+	sort.Strings(urls)
+	for _, u := range urls {
+		if err := fn(u, b[u]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestPageHandler(t *testing.T) {
+	html := http.Header{"Content-Type": {"text/html; charset=utf-8"}}
+	pages := pageStore{
+		records: map[string]*storage.PageRecord{
+			"http://a.com/":    {URL: "http://a.com/", FinalURL: "http://a.com/home/", StatusCode: 200, Header: html},
+			"http://a.com/img": {URL: "http://a.com/img", StatusCode: 200, Header: http.Header{"Content-Type": {"image/png"}}},
+		},
+		bodies: map[string][]byte{
+			"http://a.com/":    []byte(`<nav><a href="/about">About</a></nav><a href="next#top">Next</a><a href="next">Again</a>`),
+			"http://a.com/img": []byte("\x89PNG"),
+		},
+	}
+	handler := NewPageHandler(pages, parser.NewParser(), queueLookup{"http://a.com/q": true}, blacklist{"http://a.com/b": ""}).Handler
+
+	tests := []struct {
+		name   string
+		url    string
+		status int
+		check  func(t *testing.T, page Page)
+	}{
+		{
+			name: "html page", url: "http://a.com/", status: http.StatusOK,
+			check: func(t *testing.T, page Page) {
+				want := []OutLink{{URL: "http://a.com/about", Source: parser.SourceNav}, {URL: "http://a.com/home/next", Source: parser.SourceContent}}
+				if len(page.Links) != len(want) || page.Links[0] != want[0] || page.Links[1] != want[1] {
+					t.Errorf("got links %+v, want %+v", page.Links, want)
+				}
+				if !strings.Contains(page.Body, "About") || page.Record.StatusCode != 200 {
+					t.Errorf("got %+v", page)
+				}
+			},
+		},
+		{
+			name: "binary body", url: "http://a.com/img", status: http.StatusOK,
+			check: func(t *testing.T, page Page) {
+				if page.Body != "" || page.BodySize != 4 || len(page.Links) != 0 {
+					t.Errorf("got %+v", page)
+				}
+			},
+		},
+		{
+			name: "queued", url: "http://a.com/q", status: http.StatusOK,
+			check: func(t *testing.T, page Page) {
+				if !page.Queued || page.Record != nil {
+					t.Errorf("got %+v", page)
+				}
+			},
+		},
+		{
+			name: "blacklisted", url: "http://a.com/b", status: http.StatusOK,
+			check: func(t *testing.T, page Page) {
+				if page.Blacklisted != "unknown" {
+					t.Errorf("got blacklisted %q", page.Blacklisted)
+				}
+			},
+		},
+		{name: "unknown", url: "http://a.com/x", status: http.StatusNotFound},
+		{name: "no url", url: "", status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler(rec, httptest.NewRequest(http.MethodGet, "/api/v1/page?url="+tt.url, nil))
+			if rec.Code != tt.status {
+				t.Fatalf("got %d %s, want %d", rec.Code, rec.Body, tt.status)
+			}
+			if tt.check == nil {
+				return
+			}
+			var page Page
+			if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, page)
+		})
+	}
+}
+
+type hostMetrics []metrics.HostStats
+
+func (h hostMetrics) Hosts() []metrics.HostStats { return h }
+
+func TestHostsHandler(t *testing.T) {
+	m := hostMetrics{{Host: "a.com", Fetched: 3, Failed: 1}, {Host: "b.com", Fetched: 9}}
+	queue := Labeled(size(7), func() map[string]int { return map[string]int{"a.com": 2, "c.com": 5} })
+	handler := NewHostsHandler(m, queue).Handler
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/api/v1/hosts?limit=3", nil))
+	var resp struct {
+		Hosts  int `json:"hosts"`
+		ByHost []struct {
+			Host   string `json:"host"`
+			Queued int    `json:"queued"`
+		} `json:"by_host"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, h := range resp.ByHost {
+		got = append(got, h.Host)
+	}
+	if resp.Hosts != 3 || strings.Join(got, " ") != "b.com a.com c.com" || resp.ByHost[1].Queued != 2 || resp.ByHost[2].Queued != 5 {
+		t.Errorf("got %+v", resp)
+	}
+}
+
+func TestBlacklistJSONHandler(t *testing.T) {
+	handler := NewBlacklistJSONHandler(blacklist{
+		"http://a.com/3": "trap: depth",
+		"http://a.com/1": "trap: query",
+		"http://b.com/":  "out of scope",
+		"http://a.com/2": "trap: depth",
+		"http://a.com/x": "",
+	}).Handler
+
+	tests := []struct {
+		query    string
+		wantSize int
+		wantURLs string
+	}{
+		{query: "?reason=trap&limit=2", wantSize: 3, wantURLs: "http://a.com/1 http://a.com/2"},
+		{query: "?limit=0", wantSize: 5, wantURLs: "http://a.com/1 http://a.com/2 http://a.com/3 http://a.com/x http://b.com/"},
+		{query: "?reason=fetch", wantSize: 0, wantURLs: ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, "/api/v1/blacklist"+tt.query, nil))
+		var resp BlacklistResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, e := range resp.Entries {
+			got = append(got, e.URL)
+		}
+		if resp.Size != tt.wantSize || strings.Join(got, " ") != tt.wantURLs {
+			t.Errorf("%s: got %d %v, want %d %s", tt.query, resp.Size, got, tt.wantSize, tt.wantURLs)
+		}
+	}
+}
+
+func TestBlacklistHandler(t *testing.T) {
+	handler := NewBlacklistHandler(blacklist{
+		"http://a.com/2": "trap: depth",
+		"http://a.com/1": "trap: query",
+		"http://b.com/":  "out of scope",
+		"http://a.com/x": "",
+	}).Handler
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/blacklist?reason=trap", nil))
+	want := "Blacklisted: 2\n\thttp://a.com/1\ttrap: query\n\thttp://a.com/2\ttrap: depth\n"
+	if rec.Body.String() != want {
+		t.Errorf("got %q, want %q", rec.Body, want)
+	}
+}
+
+func TestDashboardHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewDashboardHandler().Handler(rec, httptest.NewRequest(http.MethodGet, "/dashboard", nil))
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("got %d %s", rec.Code, rec.Header())
+	}
+	// the page only reads endpoints which exist
+	for _, path := range []string{"/api/v1/stats", "/api/v1/queue", "/api/v1/hosts", "/api/v1/blacklist", "/api/v1/errors", "/api/v1/page", "/api/v1/pages/links", "/api/v1/pages/body", "/api/v1/events"} {
+		if !strings.Contains(rec.Body.String(), path) {
+			t.Errorf("the dashboard does not use %s", path)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package apistats
+
+import (
+	"archive/zip"
+	"bufio"
+	"crawler/internal/layout"
+	"crawler/internal/storage"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxPageListLimit caps the pages of a single listing, the following ones are reached with ?after=
+const maxPageListLimit = 1000
+
+// defaultArchiveLimit is the number of the archived pages unless ?limit= is given, maxArchiveLimit caps it
+// and maxArchiveSize caps the bodies of an archive, the following pages are reached with ?after=
+const (
+	defaultArchiveLimit = 1000
+	maxArchiveLimit     = 5000
+	maxArchiveSize      = 256 << 20
+)
+
+var errArchiveTooLarge = errors.New("the archive is over the limit")
+
+// pageQuery reads ?prefix=, ?match= (a regular expression), ?after= and ?limit=
+func pageQuery(r *http.Request, defaultLimit int) (storage.PageQuery, error) {
+	q := storage.PageQuery{Prefix: r.URL.Query().Get("prefix"), After: r.URL.Query().Get("after")}
+	if match := r.URL.Query().Get("match"); match != "" {
+		re, err := regexp.Compile(match)
+		if err != nil {
+			return q, fmt.Errorf("invalid match: %w", err)
+		}
+		q.Match = re
+	}
+	var err error
+	q.Limit, err = queryLimit(r, "limit", defaultLimit)
+	return q, err
+}
+
+type PageLister interface {
+	ListPages(q storage.PageQuery) (storage.PageList, error)
+}
+
+// PageListHandler lists the urls with a stored body in url order, ?prefix= and ?match= filter them.
+// The next page of results is requested with ?after= set to the "next" of the response.
+type PageListHandler struct {
+	Pages PageLister
+}
+
+func NewPageListHandler(pages PageLister) *PageListHandler {
+	return &PageListHandler{Pages: pages}
+}
+
+func (ph *PageListHandler) Handler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q, err := pageQuery(r, defaultListLimit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if q.Limit == 0 || q.Limit > maxPageListLimit {
+		q.Limit = maxPageListLimit
+	}
+	list, err := ph.Pages.ListPages(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, list)
+}
+
+type StoredPageReader interface {
+	GetStoredPage(url string) (*storage.PageRecord, []byte, error)
+}
+
+// PageBodyHandler serves the stored body of ?url= as it was fetched, with the stored Content-Type
+type PageBodyHandler struct {
+	Pages StoredPageReader
+}
+
+func NewPageBodyHandler(pages StoredPageReader) *PageBodyHandler {
+	return &PageBodyHandler{Pages: pages}
+}
+
+func (ph *PageBodyHandler) Handler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	pageURL := r.URL.Query().Get("url")
+	if pageURL == "" {
+		http.Error(w, "no url given", http.StatusBadRequest)
+		return
+	}
+	record, body, err := ph.Pages.GetStoredPage(pageURL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if body == nil {
+		http.Error(w, fmt.Sprintf("no stored body for %s", pageURL), http.StatusNotFound)
+		return
+	}
+	contentType := "application/octet-stream"
+	if record != nil && record.Header.Get("Content-Type") != "" {
+		contentType = record.Header.Get("Content-Type")
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	// the body comes from the crawled site, it must not run scripts on the origin of the API
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if r.Method == http.MethodGet {
+		w.Write(body)
+	}
+}
+
+type LinkGraph interface {
+	Links(url string, limit int) (storage.PageLinks, error)
+}
+
+// PageLinksResponse is the response of /api/v1/pages/links
+type PageLinksResponse struct {
+	URL string `json:"url"`
+	storage.PageLinks
+}
+
+// PageLinksHandler lists the in-scope links of ?url= and the stored pages linking to it,
+// ?limit= caps the incoming links
+type PageLinksHandler struct {
+	Links LinkGraph
+}
+
+func NewPageLinksHandler(links LinkGraph) *PageLinksHandler {
+	return &PageLinksHandler{Links: links}
+}
+
+func (lh *PageLinksHandler) Handler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	pageURL := r.URL.Query().Get("url")
+	if pageURL == "" {
+		http.Error(w, "no url given", http.StatusBadRequest)
+		return
+	}
+	limit, err := queryLimit(r, "limit", defaultListLimit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	links, err := lh.Links.Links(pageURL, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, PageLinksResponse{URL: pageURL, PageLinks: links})
+}
+
+type StoredPageWalker interface {
+	ForEachStoredPage(q storage.PageQuery, fn func(url string, record *storage.PageRecord, body []byte) error) error
+}
+
+// ArchiveEntry is a line of the index.jsonl of the archive
+type ArchiveEntry struct {
+	// File is the path of the body in the archive
+	File string `json:"file"`
+	URL  string `json:"url"`
+	// Record is null for the bodies stored without one
+	Record *storage.PageRecord `json:"record"`
+}
+
+// ArchiveHandler downloads the pages selected like by PageListHandler as a zip: the bodies in the
+// mirrored layout of the downloads and index.jsonl with the url and the record of every file.
+// The pages are read in one transaction, so the archive is a consistent snapshot. The transaction only
+// copies the bodies to a temporary file, they are compressed after it ends, so the crawl is not held up
+// for long. The zip is sent once it is complete, a failed read is reported with an error status instead
+// of a truncated archive.
+type ArchiveHandler struct {
+	Pages StoredPageWalker
+}
+
+func NewArchiveHandler(pages StoredPageWalker) *ArchiveHandler {
+	return &ArchiveHandler{Pages: pages}
+}
+
+func (ah *ArchiveHandler) Handler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q, err := pageQuery(r, defaultArchiveLimit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if q.Limit == 0 || q.Limit > maxArchiveLimit {
+		q.Limit = maxArchiveLimit
+	}
+
+	spool, err := ah.spool(q)
+	if errors.Is(err, errArchiveTooLarge) {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer removeTemp(spool)
+	size, err := spool.Seek(0, io.SeekEnd)
+	if err == nil {
+		_, err = spool.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="pages.zip"`)
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	io.Copy(w, spool)
+}
+
+// spooledPage is a body copied to the spool, in the order of the pages
+type spooledPage struct {
+	name     string
+	size     int64
+	modified time.Time
+}
+
+// spool writes the zip of the pages to a temporary file. The read transaction only copies the bodies
+// and the index lines to temporary files as they are, the zip is compressed once it is closed.
+func (ah *ArchiveHandler) spool(q storage.PageQuery) (*os.File, error) {
+	bodies, err := os.CreateTemp("", "pages-bodies-*")
+	if err != nil {
+		return nil, err
+	}
+	defer removeTemp(bodies)
+	index, err := os.CreateTemp("", "pages-index-*.jsonl")
+	if err != nil {
+		return nil, err
+	}
+	defer removeTemp(index)
+
+	bodiesBuf := bufio.NewWriter(bodies)
+	indexBuf := bufio.NewWriter(index)
+	enc := json.NewEncoder(indexBuf)
+	used := make(map[string]bool)
+	var pages []spooledPage
+	var size int64
+	err = ah.Pages.ForEachStoredPage(q, func(pageURL string, record *storage.PageRecord, body []byte) error {
+		if size += int64(len(body)); size > maxArchiveSize {
+			return fmt.Errorf("%w of %d bytes, narrow it with prefix, match or limit", errArchiveTooLarge, maxArchiveSize)
+		}
+		page := spooledPage{name: archiveName(pageURL, used), size: int64(len(body)), modified: time.Now()}
+		if record != nil && !record.FetchedAt.IsZero() {
+			page.modified = record.FetchedAt
+		}
+		if _, err := bodiesBuf.Write(body); err != nil {
+			return err
+		}
+		pages = append(pages, page)
+		return enc.Encode(ArchiveEntry{File: page.name, URL: pageURL, Record: record})
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range []struct {
+		buf  *bufio.Writer
+		file *os.File
+	}{{bodiesBuf, bodies}, {indexBuf, index}} {
+		if err := f.buf.Flush(); err != nil {
+			return nil, err
+		}
+		if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+
+	spool, err := os.CreateTemp("", "pages-*.zip")
+	if err != nil {
+		return nil, err
+	}
+	archive := zip.NewWriter(spool)
+	in := bufio.NewReader(bodies)
+	for _, page := range pages {
+		if err = addFile(archive, page.name, io.LimitReader(in, page.size), page.modified); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = addFile(archive, "index.jsonl", index, time.Now())
+	}
+	if err == nil {
+		err = archive.Close()
+	}
+	if err != nil {
+		removeTemp(spool)
+		return nil, err
+	}
+	return spool, nil
+}
+
+func removeTemp(f *os.File) {
+	f.Close()
+	os.Remove(f.Name())
+}
+
+func addFile(archive *zip.Writer, name string, data io.Reader, modified time.Time) error {
+	f, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, data)
+	return err
+}
+
+// archiveName is the mirrored path of the url, made unique among the used names
+func archiveName(pageURL string, used map[string]bool) string {
+	name := "unparsable/" + url.PathEscape(pageURL)
+	if u, err := url.Parse(pageURL); err == nil {
+		name = layout.MirroredPath(u)
+	}
+	ext := path.Ext(name)
+	unique := name
+	for i := 2; used[unique] || unique == "index.jsonl"; i++ {
+		unique = fmt.Sprintf("%s~%d%s", strings.TrimSuffix(name, ext), i, ext)
+	}
+	used[unique] = true
+	return unique
+}
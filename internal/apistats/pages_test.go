@@ -0,0 +1,186 @@
+package apistats
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"crawler/internal/storage"
+	"encoding/json"
+	"errors"
+	bolt "go.etcd.io/bbolt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newPagesRepository(t *testing.T) *storage.LinkRepository {
+	t.Helper()
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "test.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	lr, err := storage.NewLinkRepository(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages := map[string]string{
+		"http://a.com/":       "<a href=/blog/1>1</a>",
+		"http://a.com/blog/1": "<p>one</p>",
+		"http://a.com/blog/2": "<p>two</p>",
+		"http://a.com/logo":   "\x89PNG",
+	}
+	for u, body := range pages {
+		if err := lr.SaveByKey(u, []byte(body)); err != nil {
+			t.Fatal(err)
+		}
+		contentType := "text/html"
+		if strings.HasSuffix(u, "logo") {
+			contentType = "image/png"
+		}
+		record := &storage.PageRecord{URL: u, StatusCode: 200, Size: int64(len(body)), Header: http.Header{"Content-Type": {contentType}}}
+		if err := lr.SavePageRecord(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := lr.SaveLinks("http://a.com/", []string{"http://a.com/blog/1", "http://a.com/logo"}); err != nil {
+		t.Fatal(err)
+	}
+	return lr
+}
+
+func get(handler http.HandlerFunc, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	return rec
+}
+
+func TestPageListHandler(t *testing.T) {
+	handler := NewPageListHandler(newPagesRepository(t)).Handler
+
+	var urls []string
+	target := "/api/v1/pages?prefix=http://a.com/&match=" + url.QueryEscape(`^http://a\.com/(blog/.*)?$`) + "&limit=2"
+	for i := 0; target != "" && i < 5; i++ {
+		rec := get(handler, target)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("got %d %s", rec.Code, rec.Body)
+		}
+		var list storage.PageList
+		if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range list.Pages {
+			urls = append(urls, p.URL)
+		}
+		target = ""
+		if list.Next != "" {
+			target = "/api/v1/pages?match=" + url.QueryEscape(`^http://a\.com/(blog/.*)?$`) + "&limit=2&after=" + url.QueryEscape(list.Next)
+		}
+	}
+	if got := strings.Join(urls, " "); got != "http://a.com/ http://a.com/blog/1 http://a.com/blog/2" {
+		t.Errorf("got %s", got)
+	}
+
+	if rec := get(handler, "/api/v1/pages?match=("); rec.Code != http.StatusBadRequest {
+		t.Errorf("got %d for a broken regular expression", rec.Code)
+	}
+}
+
+func TestPageBodyHandler(t *testing.T) {
+	handler := NewPageBodyHandler(newPagesRepository(t)).Handler
+
+	rec := get(handler, "/api/v1/pages/body?url=http://a.com/logo")
+	if rec.Code != http.StatusOK || rec.Body.String() != "\x89PNG" || rec.Header().Get("Content-Type") != "image/png" {
+		t.Errorf("got %d %q %s", rec.Code, rec.Body, rec.Header())
+	}
+	if rec.Header().Get("Content-Security-Policy") != "sandbox" {
+		t.Errorf("the stored body is served without a sandbox")
+	}
+	if rec := get(handler, "/api/v1/pages/body?url=http://a.com/missing"); rec.Code != http.StatusNotFound {
+		t.Errorf("got %d for a missing page", rec.Code)
+	}
+}
+
+func TestPageLinksHandler(t *testing.T) {
+	handler := NewPageLinksHandler(newPagesRepository(t)).Handler
+
+	var resp PageLinksResponse
+	rec := get(handler, "/api/v1/pages/links?url=http://a.com/blog/1")
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Outgoing) != 0 || len(resp.Incoming) != 1 || resp.Incoming[0] != "http://a.com/" || resp.IncomingTotal != 1 {
+		t.Errorf("got %+v", resp)
+	}
+}
+
+func TestArchiveHandler(t *testing.T) {
+	handler := NewArchiveHandler(newPagesRepository(t)).Handler
+
+	rec := get(handler, "/api/v1/pages/archive?prefix=http://a.com/blog/")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/zip" {
+		t.Fatalf("got %d %s", rec.Code, rec.Header())
+	}
+	archive, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	for _, f := range archive.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(r)
+		files[f.Name] = string(data)
+	}
+	if len(files) != 3 || files["a.com/blog/1@.html"] != "<p>one</p>" || files["a.com/blog/2@.html"] != "<p>two</p>" {
+		t.Errorf("got %v", files)
+	}
+
+	var entries []ArchiveEntry
+	scanner := bufio.NewScanner(strings.NewReader(files["index.jsonl"]))
+	for scanner.Scan() {
+		var e ArchiveEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, e)
+	}
+	if len(entries) != 2 || entries[0].URL != "http://a.com/blog/1" || entries[0].File != "a.com/blog/1@.html" || entries[0].Record.StatusCode != 200 {
+		t.Errorf("got index %+v", entries)
+	}
+}
+
+// failingWalker fails after the first page, like a broken record found during the walk
+type failingWalker struct{}
+
+func (failingWalker) ForEachStoredPage(q storage.PageQuery, fn func(url string, record *storage.PageRecord, body []byte) error) error {
+	if err := fn("http://a.com/", nil, []byte("body")); err != nil {
+		return err
+	}
+	return errors.New("broken page record")
+}
+
+func TestArchiveHandlerError(t *testing.T) {
+	rec := get(NewArchiveHandler(failingWalker{}).Handler, "/api/v1/pages/archive")
+	if rec.Code != http.StatusInternalServerError || rec.Header().Get("Content-Type") == "application/zip" {
+		t.Errorf("got %d %s, want the error instead of a truncated archive", rec.Code, rec.Header())
+	}
+}
+
+func TestArchiveName(t *testing.T) {
+	used := make(map[string]bool)
+	var names []string
+	for _, u := range []string{"http://a.com/", "http://a.com/index.html", "http://a.com/", "http://a.com/x y"} {
+		names = append(names, archiveName(u, used))
+	}
+	want := "a.com/index.html a.com/@index.html a.com/index~2.html a.com/x y@.html"
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
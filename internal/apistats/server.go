@@ -1,10 +1,14 @@
 package apistats
 
 import (
+	"bufio"
+	"crawler/internal/storage"
 	"fmt"
 	"net/http"
+	"strings"
 )
 
+// Counter is a number reported by the API, e.g. the size of a repository
 type Counter interface {
 	Size() int
 }
@@ -27,3 +31,104 @@ func (sh *StatHandler) Handler(w http.ResponseWriter, r *http.Request) {
 	resp := fmt.Sprintf("\tDone: %d\n \tIn the queue: %d\n \tBlacklisted: %d", sh.DoneCounter.Size(), sh.InQueueCounter.Size(), sh.BrokenCounter.Size())
 	w.Write([]byte(resp))
 }
+
+type DuplicatesReporter interface {
+	DuplicateClusters() ([]storage.DuplicateCluster, error)
+}
+
+// DuplicatesHandler lists the groups of urls returning the identical body
+type DuplicatesHandler struct {
+	Reporter DuplicatesReporter
+}
+
+func NewDuplicatesHandler(reporter DuplicatesReporter) *DuplicatesHandler {
+	return &DuplicatesHandler{Reporter: reporter}
+}
+
+func (dh *DuplicatesHandler) Handler(w http.ResponseWriter, r *http.Request) {
+	clusters, err := dh.Reporter.DuplicateClusters()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var resp strings.Builder
+	fmt.Fprintf(&resp, "Duplicate clusters: %d\n", len(clusters))
+	for _, c := range clusters {
+		fmt.Fprintf(&resp, "\n%s (%d bytes, %d urls)\n", c.Digest, c.Size, len(c.URLs))
+		for _, u := range c.URLs {
+			fmt.Fprintf(&resp, "\t%s\n", u)
+		}
+	}
+	w.Write([]byte(resp.String()))
+}
+
+type PageRecords interface {
+	ForEachPageRecord(fn func(record *storage.PageRecord) error) error
+}
+
+// NearDuplicatesHandler lists the pages whose visible text is nearly the same as of an earlier page
+type NearDuplicatesHandler struct {
+	Records PageRecords
+}
+
+func NewNearDuplicatesHandler(records PageRecords) *NearDuplicatesHandler {
+	return &NearDuplicatesHandler{Records: records}
+}
+
+func (nh *NearDuplicatesHandler) Handler(w http.ResponseWriter, r *http.Request) {
+	var resp strings.Builder
+	cnt := 0
+	err := nh.Records.ForEachPageRecord(func(record *storage.PageRecord) error {
+		if record.NearDuplicateOf == "" {
+			return nil
+		}
+		cnt++
+		fmt.Fprintf(&resp, "\t%s\n\t\tnear-duplicate of %s (distance %d)\n", record.URL, record.NearDuplicateOf, record.NearDuplicateDistance)
+		return nil
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write([]byte(fmt.Sprintf("Near-duplicate pages: %d\n", cnt) + resp.String()))
+}
+
+// BlacklistHandler lists the blacklisted urls with the reasons in url order, ?reason= filters by the reason
+// prefix. The list is walked twice, to count and to write it, instead of copied.
+type BlacklistHandler struct {
+	Blacklist BlacklistWalker
+}
+
+func NewBlacklistHandler(blacklist BlacklistWalker) *BlacklistHandler {
+	return &BlacklistHandler{Blacklist: blacklist}
+}
+
+func (bh *BlacklistHandler) Handler(w http.ResponseWriter, r *http.Request) {
+	filter := r.URL.Query().Get("reason")
+	cnt := 0
+	err := bh.Blacklist.ForEachEntry(func(u, reason string) error {
+		if strings.HasPrefix(reason, filter) {
+			cnt++
+		}
+		return nil
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "Blacklisted: %d\n", cnt)
+	bh.Blacklist.ForEachEntry(func(u, reason string) error {
+		if !strings.HasPrefix(reason, filter) {
+			return nil
+		}
+		if reason == "" {
+			reason = "unknown"
+		}
+		_, err := fmt.Fprintf(out, "\t%s\t%s\n", u, reason)
+		return err
+	})
+	out.Flush()
+}
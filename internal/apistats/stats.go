@@ -0,0 +1,122 @@
+package apistats
+
+import (
+	"crawler/internal/metrics"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// LabeledCounter splits the number by a label, e.g. the queued urls by host
+type LabeledCounter interface {
+	Counter
+	SizeByLabel() map[string]int
+}
+
+type labeledCounter struct {
+	Counter
+	by func() map[string]int
+}
+
+func (lc labeledCounter) SizeByLabel() map[string]int { return lc.by() }
+
+// Labeled makes a LabeledCounter of the counter and the function splitting it
+func Labeled(c Counter, by func() map[string]int) LabeledCounter {
+	return labeledCounter{Counter: c, by: by}
+}
+
+type MetricsSource interface {
+	Snapshot() metrics.Snapshot
+}
+
+// Stats is the response of /api/v1/stats
+type Stats struct {
+	metrics.Snapshot
+	StoredPages int        `json:"stored_pages"`
+	Blacklisted int        `json:"blacklisted"`
+	Queue       QueueStats `json:"queue"`
+	// ETA is the queue divided by the rate of the longest window with requests, null before the first
+	// request. The queue grows while new links are found, so it is a lower bound.
+	ETA *metrics.Seconds `json:"eta_seconds"`
+}
+
+type QueueStats struct {
+	Size  int `json:"size"`
+	Hosts int `json:"hosts"`
+	// ByHost lists the hosts with the most urls first, ?hosts=N limits it
+	ByHost []HostSize `json:"by_host"`
+}
+
+type HostSize struct {
+	Host string `json:"host"`
+	Size int    `json:"size"`
+}
+
+// defaultHostsLimit is the length of the queue by host unless ?hosts= is given, 0 lists all
+const defaultHostsLimit = 20
+
+// StatsHandler reports the counters of the running crawl as JSON
+type StatsHandler struct {
+	Metrics     MetricsSource
+	Stored      Counter
+	Queue       LabeledCounter
+	Blacklisted Counter
+}
+
+func NewStatsHandler(m MetricsSource, stored Counter, queue LabeledCounter, blacklisted Counter) *StatsHandler {
+	return &StatsHandler{Metrics: m, Stored: stored, Queue: queue, Blacklisted: blacklisted}
+}
+
+func (sh *StatsHandler) Handler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	limit, err := queryLimit(r, "hosts", defaultHostsLimit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, sh.Stats(limit))
+}
+
+// Stats collects the numbers, limit caps the queue by host, 0 lists all hosts
+func (sh *StatsHandler) Stats(limit int) Stats {
+	stats := Stats{
+		Snapshot:    sh.Metrics.Snapshot(),
+		StoredPages: sh.Stored.Size(),
+		Blacklisted: sh.Blacklisted.Size(),
+		Queue:       QueueStats{Size: sh.Queue.Size(), ByHost: make([]HostSize, 0)},
+	}
+	for host, size := range sh.Queue.SizeByLabel() {
+		stats.Queue.ByHost = append(stats.Queue.ByHost, HostSize{Host: host, Size: size})
+	}
+	sort.Slice(stats.Queue.ByHost, func(i, j int) bool {
+		a, b := stats.Queue.ByHost[i], stats.Queue.ByHost[j]
+		return a.Size > b.Size || a.Size == b.Size && a.Host < b.Host
+	})
+	stats.Queue.Hosts = len(stats.Queue.ByHost)
+	if limit > 0 && len(stats.Queue.ByHost) > limit {
+		stats.Queue.ByHost = stats.Queue.ByHost[:limit]
+	}
+
+	for i := len(stats.Throughput) - 1; i >= 0; i-- {
+		if rate := stats.Throughput[i].PagesPerSecond; rate > 0 {
+			eta := metrics.Seconds(time.Duration(float64(stats.Queue.Size) / rate * float64(time.Second)))
+			stats.ETA = &eta
+			break
+		}
+	}
+	return stats
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
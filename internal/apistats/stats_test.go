@@ -0,0 +1,57 @@
+package apistats
+
+import (
+	"crawler/internal/metrics"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type size int
+
+func (s size) Size() int { return int(s) }
+
+type snapshot metrics.Snapshot
+
+func (s snapshot) Snapshot() metrics.Snapshot { return metrics.Snapshot(s) }
+
+func TestStatsHandler(t *testing.T) {
+	queue := Labeled(size(60), func() map[string]int { return map[string]int{"a.com": 10, "b.com": 40, "c.com": 10} })
+	m := snapshot{Fetched: 7, Throughput: []metrics.Throughput{{Window: "1m", PagesPerSecond: 2}, {Window: "5m", PagesPerSecond: 0.5}}}
+	handler := NewStatsHandler(m, size(7), queue, size(3)).Handler
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stats?hosts=2", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("got %d %s", rec.Code, rec.Body)
+	}
+	var stats struct {
+		Fetched     int        `json:"fetched"`
+		StoredPages int        `json:"stored_pages"`
+		Blacklisted int        `json:"blacklisted"`
+		Queue       QueueStats `json:"queue"`
+		ETA         float64    `json:"eta_seconds"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Fetched != 7 || stats.StoredPages != 7 || stats.Blacklisted != 3 {
+		t.Errorf("got %+v", stats)
+	}
+	want := []HostSize{{Host: "b.com", Size: 40}, {Host: "a.com", Size: 10}}
+	if stats.Queue.Size != 60 || stats.Queue.Hosts != 3 || len(stats.Queue.ByHost) != 2 || stats.Queue.ByHost[0] != want[0] || stats.Queue.ByHost[1] != want[1] {
+		t.Errorf("got queue %+v", stats.Queue)
+	}
+	// the 5 minute rate is the steadier one
+	if stats.ETA != (2 * time.Minute).Seconds() {
+		t.Errorf("got ETA %v", stats.ETA)
+	}
+
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stats?hosts=x", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("got %d for a broken limit", rec.Code)
+	}
+}
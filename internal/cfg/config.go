@@ -1,27 +1,305 @@
 package cfg
 
 import (
+	"bytes"
+	"crawler/internal/frontier"
+	"crawler/internal/simhash"
+	"errors"
+	"fmt"
 	"gopkg.in/yaml.v3"
+	"io"
+	"net/url"
 	"os"
+	"strings"
+	"time"
 )
 
 type Config struct {
-	Parallelism         int      `yaml:"parallelism"`
-	AcceptableMimeTypes []string `yaml:"acceptable_mime_types"`
-	DatabaseFile        string   `yaml:"database_file"`
-	ApiAddr             string   `yaml:"api_addr"`
-	DownloadsDir        string   `yaml:"downloads_dir"`
+	Parallelism         int                  `yaml:"parallelism"`
+	AcceptableMimeTypes []string             `yaml:"acceptable_mime_types"`
+	DatabaseFile        string               `yaml:"database_file"`
+	ApiAddr             string               `yaml:"api_addr"`
+	ApiToken            string               `yaml:"api_token"`
+	PublicPages         bool                 `yaml:"public_pages"`
+	DownloadsDir        string               `yaml:"downloads_dir"`
+	DownloadsLayout     string               `yaml:"downloads_layout"`
+	Mirror              bool                 `yaml:"mirror"`
+	Delay               time.Duration        `yaml:"delay"`
+	Headers             map[string]string    `yaml:"headers"`
+	MaxBodySize         int64                `yaml:"max_body_size"`
+	Timeout             time.Duration        `yaml:"timeout"`
+	Hosts               []HostConfig         `yaml:"hosts"`
+	Auth                []AuthConfig         `yaml:"auth"`
+	Proxy               ProxyConfig          `yaml:"proxy"`
+	TLS                 TLSConfig            `yaml:"tls"`
+	Warc                WarcConfig           `yaml:"warc"`
+	NearDuplicates      NearDuplicatesConfig `yaml:"near_duplicates"`
+	Traps               TrapsConfig          `yaml:"traps"`
+	Frontier            FrontierConfig       `yaml:"frontier"`
+	SeenSet             SeenSetConfig        `yaml:"seen_set"`
+	// ReplayWarcs are glob patterns of WARC files served instead of the live web
+	ReplayWarcs []string `yaml:"replay_warcs"`
 }
 
+type NearDuplicatesConfig struct {
+	Enabled     bool `yaml:"enabled"`
+	MaxDistance int  `yaml:"max_distance"`
+	SkipLinks   bool `yaml:"skip_links"`
+}
+
+// TrapsConfig limits the generated url spaces, zero disables the check
+type TrapsConfig struct {
+	MaxPathDepth         int `yaml:"max_path_depth"`
+	MaxSegmentRepeats    int `yaml:"max_segment_repeats"`
+	MaxQueryCombinations int `yaml:"max_query_combinations"`
+	MaxURLsPerPattern    int `yaml:"max_urls_per_pattern"`
+}
+
+// FrontierConfig sets the weights of the queue priority, a zero weight turns its part off
+type FrontierConfig struct {
+	DepthWeight   float64            `yaml:"depth_weight"`
+	HostPenalty   float64            `yaml:"host_penalty"`
+	SourceWeights map[string]float64 `yaml:"source_weights"`
+	MimeWeights   map[string]float64 `yaml:"mime_weights"`
+	Boosts        []BoostConfig      `yaml:"boosts"`
+}
+
+// BoostConfig adds the boost to the score of the urls matching the regular expression
+type BoostConfig struct {
+	Pattern string  `yaml:"pattern"`
+	Boost   float64 `yaml:"boost"`
+}
+
+// SeenSetConfig sizes the Bloom filter in front of the seen urls stored in the database
+type SeenSetConfig struct {
+	ExpectedURLs      uint64  `yaml:"expected_urls"`
+	FalsePositiveRate float64 `yaml:"false_positive_rate"`
+}
+
+// WarcConfig enables the WARC output when Dir is set
+type WarcConfig struct {
+	Dir       string `yaml:"dir"`
+	Prefix    string `yaml:"prefix"`
+	MaxSizeMB int64  `yaml:"max_size_mb"`
+}
+
+type ProxyConfig struct {
+	URL    string   `yaml:"url"`
+	Bypass []string `yaml:"bypass"`
+}
+
+type TLSConfig struct {
+	CAFile             string `yaml:"ca_file"`
+	CertFile           string `yaml:"cert_file"`
+	KeyFile            string `yaml:"key_file"`
+	MinVersion         string `yaml:"min_version"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// AuthConfig describes the credentials for a single host
+type AuthConfig struct {
+	Host        string `yaml:"host"`
+	Credentials `yaml:",inline"`
+	FormLogin   *FormLogin `yaml:"form_login"`
+}
+
+type Credentials struct {
+	Username    string            `yaml:"username"`
+	Password    string            `yaml:"password"`
+	BearerToken string            `yaml:"bearer_token"`
+	Cookies     map[string]string `yaml:"cookies"`
+}
+
+// HostConfig overrides the global settings for the hosts matching Match, a host name or a glob like
+// "*.example.com". The matching blocks are applied in the file order, the unset values are inherited.
+type HostConfig struct {
+	Match               string            `yaml:"match"`
+	Parallelism         int               `yaml:"parallelism"`
+	Delay               time.Duration     `yaml:"delay"`
+	Headers             map[string]string `yaml:"headers"`
+	Auth                *Credentials      `yaml:"auth"`
+	AcceptableMimeTypes []string          `yaml:"acceptable_mime_types"`
+	MaxBodySize         int64             `yaml:"max_body_size"`
+	Timeout             time.Duration     `yaml:"timeout"`
+	// InScope includes the hosts into the crawl or excludes them, regardless of the seeds
+	InScope *bool `yaml:"in_scope"`
+}
+
+// FormLogin is posted once before the crawl starts, the received session cookies are kept in the cookie jar
+type FormLogin struct {
+	URL    string            `yaml:"url"`
+	Fields map[string]string `yaml:"fields"`
+}
+
+// Default returns the configuration used for every key missing in the file
+func Default() *Config {
+	frontierDefaults := frontier.DefaultConfig()
+	return &Config{
+		Parallelism:         10,
+		AcceptableMimeTypes: []string{"text/html"},
+		DatabaseFile:        "./crawler.db",
+		ApiAddr:             "localhost:8080",
+		DownloadsDir:        "./downloads",
+		DownloadsLayout:     "mirrored",
+		Timeout:             30 * time.Second,
+		Warc: WarcConfig{
+			Prefix:    "crawl",
+			MaxSizeMB: 1024,
+		},
+		NearDuplicates: NearDuplicatesConfig{
+			MaxDistance: simhash.MaxDistance,
+			SkipLinks:   true,
+		},
+		Traps: TrapsConfig{
+			MaxPathDepth:         20,
+			MaxSegmentRepeats:    2,
+			MaxQueryCombinations: 100,
+		},
+		Frontier: FrontierConfig{
+			DepthWeight:   *frontierDefaults.DepthWeight,
+			HostPenalty:   *frontierDefaults.HostPenalty,
+			SourceWeights: frontierDefaults.SourceWeights,
+			MimeWeights:   frontierDefaults.MimeWeights,
+		},
+		SeenSet: SeenSetConfig{
+			ExpectedURLs:      1000000,
+			FalsePositiveRate: 0.01,
+		},
+	}
+}
+
+// NewConfig loads the file and the environment overrides and validates the result
 func NewConfig(configPath string) (*Config, error) {
-	buf, err := os.ReadFile(configPath)
+	config, err := Load(configPath, os.Environ())
 	if err != nil {
 		return nil, err
 	}
-	var config Config
-	err = yaml.Unmarshal(buf, &config)
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Load reads the file over the defaults and applies the CRAWLER_* variables of the environment.
+// Unknown keys are errors. The result is not validated, so more overrides can be applied first.
+func Load(configPath string, environ []string) (*Config, error) {
+	config, err := LoadFile(configPath)
 	if err != nil {
 		return nil, err
 	}
-	return &config, nil
+	if err := config.ApplyEnv(environ); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// LoadFile reads the file over the defaults
+func LoadFile(configPath string) (*Config, error) {
+	buf, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+	config := Default()
+	if err := decodeStrict(buf, config); err != nil {
+		return nil, fmt.Errorf("%s: %w", configPath, err)
+	}
+	return config, nil
+}
+
+func decodeStrict(buf []byte, config *Config) error {
+	dec := yaml.NewDecoder(bytes.NewReader(buf))
+	dec.KnownFields(true)
+	err := dec.Decode(config)
+	if errors.Is(err, io.EOF) {
+		// an empty file keeps the defaults
+		return nil
+	}
+	return err
+}
+
+// Redacted returns a copy with the passwords, tokens and cookie values masked, including the proxy
+// password and the credentials sent in the headers
+func (c *Config) Redacted() *Config {
+	r := *c
+	if c.ApiToken != "" {
+		r.ApiToken = redacted
+	}
+	r.Headers = redactedHeaders(c.Headers)
+	if c.Proxy.URL != "" {
+		r.Proxy.URL = redactedURL(c.Proxy.URL)
+	}
+	if c.Auth != nil {
+		r.Auth = make([]AuthConfig, len(c.Auth))
+		for i, auth := range c.Auth {
+			auth.Credentials = auth.Credentials.redacted()
+			if auth.FormLogin != nil {
+				login := *auth.FormLogin
+				login.Fields = make(map[string]string, len(auth.FormLogin.Fields))
+				for name := range auth.FormLogin.Fields {
+					login.Fields[name] = redacted
+				}
+				auth.FormLogin = &login
+			}
+			r.Auth[i] = auth
+		}
+	}
+	if c.Hosts != nil {
+		r.Hosts = make([]HostConfig, len(c.Hosts))
+		for i, host := range c.Hosts {
+			if host.Auth != nil {
+				auth := host.Auth.redacted()
+				host.Auth = &auth
+			}
+			host.Headers = redactedHeaders(host.Headers)
+			r.Hosts[i] = host
+		}
+	}
+	return &r
+}
+
+func (c Credentials) redacted() Credentials {
+	if c.Password != "" {
+		c.Password = redacted
+	}
+	if c.BearerToken != "" {
+		c.BearerToken = redacted
+	}
+	if c.Cookies != nil {
+		cookies := make(map[string]string, len(c.Cookies))
+		for name := range c.Cookies {
+			cookies[name] = redacted
+		}
+		c.Cookies = cookies
+	}
+	return c
+}
+
+const redacted = "<redacted>"
+
+// credentialHeaders carry the credentials, their values are masked in the printed config
+var credentialHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+// redactedURL masks the password of the url, an unparsable url is masked whole
+func redactedURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return redacted
+	}
+	return u.Redacted()
+}
+
+func redactedHeaders(headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+	r := make(map[string]string, len(headers))
+	for name, value := range headers {
+		for _, credential := range credentialHeaders {
+			if strings.EqualFold(name, credential) {
+				value = redacted
+			}
+		}
+		r[name] = value
+	}
+	return r
 }
@@ -0,0 +1,110 @@
+package cfg
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"sort"
+)
+
+// Change is a setting differing between two configs. The key is the yaml path like in Settings, with the
+// list elements and the map entries addressed as hosts[0].delay or headers.User-Agent.
+type Change struct {
+	Key string
+	// Old and New are printable, the secrets are masked and a missing value is "<unset>"
+	Old, New string
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %s -> %s", c.Key, c.Old, c.New)
+}
+
+const unset = "<unset>"
+
+// Diff lists the changes from old to new ordered by the key
+func Diff(old, new *Config) ([]Change, error) {
+	oldValues, err := flatten(old)
+	if err != nil {
+		return nil, err
+	}
+	newValues, err := flatten(new)
+	if err != nil {
+		return nil, err
+	}
+	// the secrets are compared as they are but printed masked
+	oldPrinted, err := flatten(old.Redacted())
+	if err != nil {
+		return nil, err
+	}
+	newPrinted, err := flatten(new.Redacted())
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	for key, value := range oldValues {
+		if newValue, ok := newValues[key]; !ok || newValue != value {
+			changes = append(changes, Change{Key: key, Old: oldPrinted[key], New: printed(newPrinted, key)})
+		}
+	}
+	for key := range newValues {
+		if _, ok := oldValues[key]; !ok {
+			changes = append(changes, Change{Key: key, Old: unset, New: newPrinted[key]})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes, nil
+}
+
+func printed(values map[string]string, key string) string {
+	if value, ok := values[key]; ok {
+		return value
+	}
+	return unset
+}
+
+// flatten maps the yaml paths of the config to the JSON encoded values, the yaml round trip
+// gives the same names and value formats, e.g. of the durations, as the config file
+func flatten(c *Config) (map[string]string, error) {
+	buf, err := yaml.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	var tree map[string]interface{}
+	if err := yaml.Unmarshal(buf, &tree); err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	flattenValue(values, "", tree)
+	return values, nil
+}
+
+func flattenValue(values map[string]string, key string, v interface{}) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if len(v) > 0 {
+			for name, item := range v {
+				if key != "" {
+					name = key + "." + name
+				}
+				flattenValue(values, name, item)
+			}
+			return
+		}
+	case []interface{}:
+		// the lists of structs are compared per field, the lists of values as a whole
+		if len(v) > 0 {
+			if _, ok := v[0].(map[string]interface{}); ok {
+				for i, item := range v {
+					flattenValue(values, fmt.Sprintf("%s[%d]", key, i), item)
+				}
+				return
+			}
+		}
+	}
+	buf, err := json.Marshal(v)
+	if err != nil {
+		buf = []byte(fmt.Sprint(v))
+	}
+	values[key] = string(buf)
+}
@@ -0,0 +1,48 @@
+package cfg
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// EnvPrefix starts the names of the variables overriding the settings, e.g. CRAWLER_WARC_MAX_SIZE_MB
+const EnvPrefix = "CRAWLER_"
+
+// EnvConfigPath names the config file instead of the --config flag
+const EnvConfigPath = EnvPrefix + "CONFIG"
+
+// EnvName is the variable overriding the setting
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+}
+
+// ApplyEnv overrides the settings with the CRAWLER_* variables of the environment, given as "KEY=value".
+// A variable matching no setting is an error, it is most likely a typo.
+func (c *Config) ApplyEnv(environ []string) error {
+	settings := make(map[string]string)
+	for _, s := range Settings() {
+		settings[EnvName(s.Key)] = s.Key
+	}
+
+	var problems []string
+	for _, kv := range environ {
+		name, value, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(name, EnvPrefix) || name == EnvConfigPath {
+			continue
+		}
+		key, ok := settings[name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("unknown environment variable %s", name))
+			continue
+		}
+		if err := c.Set(key, value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", name, err))
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package cfg
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Setting is a scalar config value addressed by its yaml path, e.g. "warc.max_size_mb".
+// Lists of strings are set from comma separated values.
+type Setting struct {
+	Key  string
+	Type string
+}
+
+// Settings lists every setting which can be overridden, the lists of structs and the maps are left to the file
+func Settings() []Setting {
+	var settings []Setting
+	walkSettings(reflect.TypeOf(Config{}), "", func(key string, field reflect.StructField) {
+		settings = append(settings, Setting{Key: key, Type: typeName(field.Type)})
+	})
+	sort.Slice(settings, func(i, j int) bool { return settings[i].Key < settings[j].Key })
+	return settings
+}
+
+// Set overrides the setting with the value parsed for its type
+func (c *Config) Set(key, value string) error {
+	v := reflect.ValueOf(c).Elem()
+	for _, name := range strings.Split(key, ".") {
+		if v.Kind() != reflect.Struct {
+			return fmt.Errorf("unknown setting %q", key)
+		}
+		field, ok := fieldByYAMLName(v.Type(), name)
+		if !ok {
+			return fmt.Errorf("unknown setting %q", key)
+		}
+		v = v.FieldByIndex(field.Index)
+	}
+	if v.Kind() == reflect.Struct || typeName(v.Type()) == "" {
+		return fmt.Errorf("setting %q can only be set in the config file", key)
+	}
+	if err := setValue(v, value); err != nil {
+		return fmt.Errorf("setting %q: %w", key, err)
+	}
+	return nil
+}
+
+func walkSettings(t reflect.Type, prefix string, fn func(key string, field reflect.StructField)) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := yamlName(field)
+		if name == "" {
+			continue
+		}
+		key := prefix + name
+		switch {
+		case field.Type.Kind() == reflect.Struct:
+			walkSettings(field.Type, key+".", fn)
+		case typeName(field.Type) != "":
+			fn(key, field)
+		}
+	}
+}
+
+func fieldByYAMLName(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		if yamlName(t.Field(i)) == name {
+			return t.Field(i), true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func yamlName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+// typeName is empty for the types which can't be set from a single string
+func typeName(t reflect.Type) string {
+	if t == durationType {
+		return "duration"
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool, reflect.Float64:
+		return t.Kind().String()
+	case reflect.Int, reflect.Int64, reflect.Uint64:
+		return "int"
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.String {
+			return "list"
+		}
+	}
+	return ""
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func setValue(v reflect.Value, value string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	}
+	return nil
+}
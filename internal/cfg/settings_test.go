@@ -0,0 +1,54 @@
+package cfg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConfigSet(t *testing.T) {
+	var setTest = []struct {
+		key, value string
+		check      func(c *Config) interface{}
+		want       interface{}
+		wantErr    bool
+	}{
+		{key: "parallelism", value: "4", check: func(c *Config) interface{} { return c.Parallelism }, want: 4},
+		{key: "warc.max_size_mb", value: "10", check: func(c *Config) interface{} { return c.Warc.MaxSizeMB }, want: int64(10)},
+		{key: "near_duplicates.enabled", value: "true", check: func(c *Config) interface{} { return c.NearDuplicates.Enabled }, want: true},
+		{key: "seen_set.expected_urls", value: "5000", check: func(c *Config) interface{} { return c.SeenSet.ExpectedURLs }, want: uint64(5000)},
+		{key: "frontier.host_penalty", value: "0.25", check: func(c *Config) interface{} { return c.Frontier.HostPenalty }, want: 0.25},
+		{key: "acceptable_mime_types", value: "text/html, text/css", check: func(c *Config) interface{} { return c.AcceptableMimeTypes }, want: []string{"text/html", "text/css"}},
+		{key: "parallelism", value: "many", wantErr: true},
+		{key: "warc.unknown", value: "1", wantErr: true},
+		{key: "parallelism.sub", value: "1", wantErr: true},
+		{key: "auth", value: "x", wantErr: true},
+	}
+
+	for _, tt := range setTest {
+		t.Run(tt.key+"="+tt.value, func(t *testing.T) {
+			var c Config
+			err := c.Set(tt.key, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(tt.check(&c), tt.want) {
+				t.Errorf("got %v, want %v", tt.check(&c), tt.want)
+			}
+		})
+	}
+}
+
+func TestSettings(t *testing.T) {
+	keys := map[string]string{}
+	for _, s := range Settings() {
+		keys[s.Key] = s.Type
+	}
+	for key, typ := range map[string]string{"database_file": "string", "warc.dir": "string", "traps.max_path_depth": "int", "proxy.bypass": "list"} {
+		if keys[key] != typ {
+			t.Errorf("%s: got type %q, want %q", key, keys[key], typ)
+		}
+	}
+	if _, ok := keys["auth"]; ok {
+		t.Error("the list of structs is listed")
+	}
+}
@@ -0,0 +1,140 @@
+package cfg
+
+import (
+	"crawler/internal/layout"
+	"crawler/internal/simhash"
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// ValidationError lists every problem of the configuration at once
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid config:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+var tlsVersions = map[string]bool{"": true, "1.0": true, "1.1": true, "1.2": true, "1.3": true}
+
+// Validate checks the values which would break the crawl later
+func (c *Config) Validate() error {
+	var problems []string
+	problemf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if c.Parallelism < 1 {
+		problemf("parallelism must be at least 1, got %d", c.Parallelism)
+	}
+	if len(c.AcceptableMimeTypes) == 0 {
+		problemf("acceptable_mime_types must not be empty")
+	}
+	if c.DatabaseFile == "" {
+		problemf("database_file must be set")
+	}
+	if _, _, err := net.SplitHostPort(c.ApiAddr); err != nil {
+		problemf("api_addr must be host:port, got %q", c.ApiAddr)
+	}
+	strategy, err := layout.NewStrategy(c.DownloadsLayout)
+	if err != nil {
+		problemf("downloads_layout: %s", err)
+	}
+	if c.Mirror {
+		if _, ok := strategy.(layout.Mirrored); !ok && err == nil {
+			problemf("mirror requires the mirrored downloads_layout, got %q", c.DownloadsLayout)
+		}
+		if c.DownloadsDir == "" {
+			problemf("mirror requires downloads_dir")
+		}
+	}
+
+	if c.Delay < 0 {
+		problemf("delay must not be negative, got %s", c.Delay)
+	}
+	if c.MaxBodySize < 0 {
+		problemf("max_body_size must not be negative, got %d", c.MaxBodySize)
+	}
+	if c.Timeout < 0 {
+		problemf("timeout must not be negative, got %s", c.Timeout)
+	}
+	for i, host := range c.Hosts {
+		if _, err := path.Match(host.Match, ""); err != nil || host.Match == "" {
+			problemf("hosts[%d].match must be a host name or a glob, got %q", i, host.Match)
+		}
+		if host.Parallelism < 0 {
+			problemf("hosts[%d].parallelism must not be negative, got %d", i, host.Parallelism)
+		}
+		if host.Delay < 0 {
+			problemf("hosts[%d].delay must not be negative, got %s", i, host.Delay)
+		}
+		if host.MaxBodySize < 0 {
+			problemf("hosts[%d].max_body_size must not be negative, got %d", i, host.MaxBodySize)
+		}
+		if host.Timeout < 0 {
+			problemf("hosts[%d].timeout must not be negative, got %s", i, host.Timeout)
+		}
+	}
+
+	if c.Warc.Dir != "" && c.Warc.MaxSizeMB < 1 {
+		problemf("warc.max_size_mb must be at least 1, got %d", c.Warc.MaxSizeMB)
+	}
+	if c.NearDuplicates.MaxDistance < 0 || c.NearDuplicates.MaxDistance > simhash.MaxDistance {
+		problemf("near_duplicates.max_distance must be between 0 and %d, got %d", simhash.MaxDistance, c.NearDuplicates.MaxDistance)
+	}
+	for _, limit := range []struct {
+		key   string
+		value int
+	}{
+		{"traps.max_path_depth", c.Traps.MaxPathDepth},
+		{"traps.max_segment_repeats", c.Traps.MaxSegmentRepeats},
+		{"traps.max_query_combinations", c.Traps.MaxQueryCombinations},
+		{"traps.max_urls_per_pattern", c.Traps.MaxURLsPerPattern},
+	} {
+		if limit.value < 0 {
+			problemf("%s must not be negative, got %d", limit.key, limit.value)
+		}
+	}
+	for i, b := range c.Frontier.Boosts {
+		if _, err := regexp.Compile(b.Pattern); err != nil {
+			problemf("frontier.boosts[%d].pattern: %s", i, err)
+		}
+	}
+	if c.SeenSet.ExpectedURLs == 0 {
+		problemf("seen_set.expected_urls must be at least 1")
+	}
+	if c.SeenSet.FalsePositiveRate <= 0 || c.SeenSet.FalsePositiveRate >= 1 {
+		problemf("seen_set.false_positive_rate must be between 0 and 1, got %v", c.SeenSet.FalsePositiveRate)
+	}
+
+	if c.Proxy.URL != "" {
+		u, err := url.Parse(c.Proxy.URL)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5") {
+			problemf("proxy.url must be an http://, https:// or socks5:// url, got %q", redactedURL(c.Proxy.URL))
+		}
+	}
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		problemf("tls.cert_file and tls.key_file must be set together")
+	}
+	if !tlsVersions[c.TLS.MinVersion] {
+		problemf("tls.min_version must be one of 1.0, 1.1, 1.2, 1.3, got %q", c.TLS.MinVersion)
+	}
+	for i, auth := range c.Auth {
+		if auth.Host == "" {
+			problemf("auth[%d].host must be set", i)
+		}
+		if auth.FormLogin != nil && auth.FormLogin.URL == "" {
+			problemf("auth[%d].form_login.url must be set", i)
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package control
+
+import (
+	"crawler/internal/seed"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Controller is the running crawl, see fetcher.Crawler
+type Controller interface {
+	Pause()
+	Resume()
+	Paused() bool
+	Stop()
+	AddSeeds(seeds []seed.Seed) (int, error)
+	RemoveFromQueue(url string) (bool, error)
+	Reprioritize(url string, priority float64) (bool, error)
+	Unblacklist(url string) (bool, error)
+}
+
+// Handler serves the control endpoints, every request needs the "Authorization: Bearer <token>" header.
+// With an empty token the endpoints are disabled.
+type Handler struct {
+	controller Controller
+	token      string
+}
+
+func NewHandler(controller Controller, token string) *Handler {
+	return &Handler{controller: controller, token: token}
+}
+
+// Register adds the endpoints to the mux
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/api/v1/crawl", h.authorized(http.MethodGet, h.state))
+	mux.HandleFunc("/api/v1/crawl/pause", h.authorized(http.MethodPost, h.pause))
+	mux.HandleFunc("/api/v1/crawl/resume", h.authorized(http.MethodPost, h.resume))
+	mux.HandleFunc("/api/v1/crawl/stop", h.authorized(http.MethodPost, h.stop))
+	mux.HandleFunc("/api/v1/seeds", h.authorized(http.MethodPost, h.addSeeds))
+	mux.HandleFunc("/api/v1/queue/remove", h.authorized(http.MethodPost, h.removeFromQueue))
+	mux.HandleFunc("/api/v1/queue/priority", h.authorized(http.MethodPost, h.reprioritize))
+	mux.HandleFunc("/api/v1/blacklist/remove", h.authorized(http.MethodPost, h.unblacklist))
+}
+
+func (h *Handler) authorized(method string, next http.HandlerFunc) http.HandlerFunc {
+	return RequireToken(h.token, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("use %s", method))
+			return
+		}
+		next(w, r)
+	})
+}
+
+// RequireToken serves next only to the requests with the "Authorization: Bearer <token>" header,
+// with an empty token the endpoint is disabled
+func RequireToken(token string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token == "" {
+			writeError(w, http.StatusForbidden, errors.New("the endpoint is disabled, set api_token to enable it"))
+			return
+		}
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, errors.New("invalid or missing bearer token"))
+			return
+		}
+		next(w, r)
+	}
+}
+
+// State is the response of the pause, resume and stop endpoints
+type State struct {
+	State string `json:"state"`
+}
+
+func (h *Handler) currentState() State {
+	if h.controller.Paused() {
+		return State{State: "paused"}
+	}
+	return State{State: "running"}
+}
+
+func (h *Handler) state(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.currentState())
+}
+
+func (h *Handler) pause(w http.ResponseWriter, r *http.Request) {
+	h.controller.Pause()
+	writeJSON(w, http.StatusOK, h.currentState())
+}
+
+func (h *Handler) resume(w http.ResponseWriter, r *http.Request) {
+	h.controller.Resume()
+	writeJSON(w, http.StatusOK, h.currentState())
+}
+
+func (h *Handler) stop(w http.ResponseWriter, r *http.Request) {
+	h.controller.Stop()
+	writeJSON(w, http.StatusAccepted, State{State: "stopping"})
+}
+
+// addSeeds takes the seeds in the format of the seed file: a url or a JSON seed per line
+func (h *Handler) addSeeds(w http.ResponseWriter, r *http.Request) {
+	seeds, err := seed.Read(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if len(seeds) == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("no seeds given"))
+		return
+	}
+	queued, err := h.controller.AddSeeds(seeds)
+	if err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"seeds": len(seeds), "queued": queued})
+}
+
+// Result lists the urls of the request which were changed and which were not found
+type Result struct {
+	Done    []string `json:"done"`
+	Missing []string `json:"missing"`
+}
+
+func (h *Handler) removeFromQueue(w http.ResponseWriter, r *http.Request) {
+	h.each(w, r, h.controller.RemoveFromQueue)
+}
+
+func (h *Handler) unblacklist(w http.ResponseWriter, r *http.Request) {
+	h.each(w, r, h.controller.Unblacklist)
+}
+
+// each applies fn to every ?url= of the request
+func (h *Handler) each(w http.ResponseWriter, r *http.Request, fn func(url string) (bool, error)) {
+	urls := r.URL.Query()["url"]
+	if len(urls) == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("no url given"))
+		return
+	}
+	result := Result{Done: make([]string, 0), Missing: make([]string, 0)}
+	for _, url := range urls {
+		done, err := fn(url)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if done {
+			result.Done = append(result.Done, url)
+		} else {
+			result.Missing = append(result.Missing, url)
+		}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// PriorityRequest is the body of /api/v1/queue/priority
+type PriorityRequest struct {
+	URL string `json:"url"`
+	// Priority is added to the score of the url, the best scored url is fetched first
+	Priority float64 `json:"priority"`
+}
+
+func (h *Handler) reprioritize(w http.ResponseWriter, r *http.Request) {
+	var req PriorityRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.URL == "" {
+		writeError(w, http.StatusBadRequest, errors.New(`expected {"url": "...", "priority": 10}`))
+		return
+	}
+	queued, err := h.controller.Reprioritize(req.URL, req.Priority)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !queued {
+		writeError(w, http.StatusNotFound, fmt.Errorf("%s is not queued", req.URL))
+		return
+	}
+	writeJSON(w, http.StatusOK, req)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,151 @@
+package control
+
+import (
+	"crawler/internal/seed"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type fakeController struct {
+	paused   bool
+	stopped  bool
+	seeds    []seed.Seed
+	queued   map[string]float64
+	unlisted []string
+}
+
+func (f *fakeController) Pause()       { f.paused = true }
+func (f *fakeController) Resume()      { f.paused = false }
+func (f *fakeController) Paused() bool { return f.paused }
+func (f *fakeController) Stop()        { f.stopped = true }
+
+func (f *fakeController) AddSeeds(seeds []seed.Seed) (int, error) {
+	f.seeds = append(f.seeds, seeds...)
+	return len(seeds), nil
+}
+
+func (f *fakeController) RemoveFromQueue(url string) (bool, error) {
+	_, ok := f.queued[url]
+	delete(f.queued, url)
+	return ok, nil
+}
+
+func (f *fakeController) Reprioritize(url string, priority float64) (bool, error) {
+	if _, ok := f.queued[url]; !ok {
+		return false, nil
+	}
+	f.queued[url] = priority
+	return true, nil
+}
+
+func (f *fakeController) Unblacklist(url string) (bool, error) {
+	f.unlisted = append(f.unlisted, url)
+	return true, nil
+}
+
+func TestHandler(t *testing.T) {
+	controller := &fakeController{queued: map[string]float64{"http://a.com/1": 0, "http://a.com/2": 0}}
+	mux := http.NewServeMux()
+	NewHandler(controller, "secret").Register(mux)
+
+	do := func(method, target, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	var requestTest = []struct {
+		name                 string
+		method, target, body string
+		token                string
+		status               int
+		response             string
+	}{
+		{name: "no token", method: http.MethodPost, target: "/api/v1/crawl/pause", status: http.StatusUnauthorized},
+		{name: "wrong token", method: http.MethodPost, target: "/api/v1/crawl/pause", token: "guess", status: http.StatusUnauthorized},
+		{name: "wrong method", method: http.MethodGet, target: "/api/v1/crawl/pause", token: "secret", status: http.StatusMethodNotAllowed},
+		{name: "pause", method: http.MethodPost, target: "/api/v1/crawl/pause", token: "secret", status: http.StatusOK, response: `{"state":"paused"}`},
+		{name: "state", method: http.MethodGet, target: "/api/v1/crawl", token: "secret", status: http.StatusOK, response: `{"state":"paused"}`},
+		{name: "resume", method: http.MethodPost, target: "/api/v1/crawl/resume", token: "secret", status: http.StatusOK, response: `{"state":"running"}`},
+		{
+			name: "seeds", method: http.MethodPost, target: "/api/v1/seeds", token: "secret",
+			body:   "http://a.com/\n{\"url\": \"http://b.com/\", \"depth\": 2}\n",
+			status: http.StatusOK, response: `{"queued":2,"seeds":2}`,
+		},
+		{name: "invalid seed", method: http.MethodPost, target: "/api/v1/seeds", token: "secret", body: "ftp://a.com/", status: http.StatusBadRequest},
+		{
+			name: "remove from queue", method: http.MethodPost, target: "/api/v1/queue/remove?url=http://a.com/1&url=http://a.com/3", token: "secret",
+			status: http.StatusOK, response: `{"done":["http://a.com/1"],"missing":["http://a.com/3"]}`,
+		},
+		{
+			name: "reprioritize", method: http.MethodPost, target: "/api/v1/queue/priority", token: "secret",
+			body: `{"url": "http://a.com/2", "priority": 5}`, status: http.StatusOK,
+		},
+		{
+			name: "reprioritize not queued", method: http.MethodPost, target: "/api/v1/queue/priority", token: "secret",
+			body: `{"url": "http://a.com/1", "priority": 5}`, status: http.StatusNotFound,
+		},
+		{name: "unblacklist", method: http.MethodPost, target: "/api/v1/blacklist/remove?url=http://a.com/x", token: "secret", status: http.StatusOK},
+		{name: "stop", method: http.MethodPost, target: "/api/v1/crawl/stop", token: "secret", status: http.StatusAccepted},
+	}
+	for _, tt := range requestTest {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(tt.method, tt.target, tt.token, tt.body)
+			if rec.Code != tt.status {
+				t.Errorf("got %d %s, want %d", rec.Code, rec.Body, tt.status)
+			}
+			if tt.response != "" && strings.TrimSpace(rec.Body.String()) != tt.response {
+				t.Errorf("got %s, want %s", rec.Body, tt.response)
+			}
+		})
+	}
+
+	if len(controller.seeds) != 2 || controller.seeds[1].MaxDepth != 2 {
+		t.Errorf("got seeds %+v", controller.seeds)
+	}
+	if controller.queued["http://a.com/2"] != 5 || !controller.stopped || len(controller.unlisted) != 1 {
+		t.Errorf("got %+v", controller)
+	}
+
+	disabled := http.NewServeMux()
+	NewHandler(controller, "").Register(disabled)
+	rec := httptest.NewRecorder()
+	disabled.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/crawl/stop", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("got %d without a token configured", rec.Code)
+	}
+}
+
+func TestRequireToken(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }
+	tests := []struct {
+		name   string
+		token  string
+		header string
+		status int
+	}{
+		{name: "valid", token: "secret", header: "Bearer secret", status: http.StatusNoContent},
+		{name: "wrong token", token: "secret", header: "Bearer other", status: http.StatusUnauthorized},
+		{name: "no header", token: "secret", status: http.StatusUnauthorized},
+		{name: "disabled", token: "", header: "Bearer ", status: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/pages/body?url=http://a.com/", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			RequireToken(tt.token, ok)(rec, req)
+			if rec.Code != tt.status {
+				t.Errorf("got %d, want %d", rec.Code, tt.status)
+			}
+		})
+	}
+}
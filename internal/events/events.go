@@ -0,0 +1,140 @@
+package events
+
+import (
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Event types, in the order they happen to a url
+const (
+	TypeEnqueued     = "enqueued"
+	TypeFetchStarted = "fetch_started"
+	TypeFetched      = "fetched"
+	TypeFailed       = "failed"
+	TypeBlacklisted  = "blacklisted"
+	TypeSaved        = "saved"
+)
+
+// Types lists all the event types
+var Types = []string{TypeEnqueued, TypeFetchStarted, TypeFetched, TypeFailed, TypeBlacklisted, TypeSaved}
+
+// Event is something that happened to a url during the crawl, the fields not related to the type are empty
+type Event struct {
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	URL  string    `json:"url"`
+	Host string    `json:"host"`
+
+	// Depth and Source are of the enqueued urls
+	Depth  int    `json:"depth,omitempty"`
+	Source string `json:"source,omitempty"`
+	// StatusCode, Size and Duration are of the fetched and failed urls when the server answered
+	StatusCode int           `json:"status_code,omitempty"`
+	Size       int64         `json:"size,omitempty"`
+	Duration   time.Duration `json:"duration_ns,omitempty"`
+	// Error and ErrorType are of the failed urls
+	Error     string `json:"error,omitempty"`
+	ErrorType string `json:"error_type,omitempty"`
+	// Reason is of the blacklisted urls
+	Reason string `json:"reason,omitempty"`
+	// Path is of the saved files
+	Path string `json:"path,omitempty"`
+}
+
+// Filter selects the events of a subscription, the empty lists match everything
+type Filter struct {
+	Types []string
+	// Hosts are host names or globs like "*.example.com"
+	Hosts []string
+}
+
+func (f Filter) matches(e Event) bool {
+	if len(f.Types) > 0 && !contains(f.Types, e.Type) {
+		return false
+	}
+	if len(f.Hosts) == 0 {
+		return true
+	}
+	host := strings.ToLower(e.Host)
+	for _, pattern := range f.Hosts {
+		if matched, _ := path.Match(strings.ToLower(pattern), host); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, item string) bool {
+	for _, v := range list {
+		if v == item {
+			return true
+		}
+	}
+	return false
+}
+
+// Bus hands the published events to the subscribers. Publishing never blocks the crawl, the events
+// a subscriber has no room for are dropped and counted.
+type Bus struct {
+	mu   sync.RWMutex
+	subs map[*Subscription]struct{}
+}
+
+func NewBus() *Bus {
+	return &Bus{subs: make(map[*Subscription]struct{})}
+}
+
+func (b *Bus) Publish(e Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for s := range b.subs {
+		if !s.filter.matches(e) {
+			continue
+		}
+		select {
+		case s.events <- e:
+		default:
+			s.dropped.Add(1)
+		}
+	}
+}
+
+// Subscribe starts receiving the matching events, buffer is the number of events kept
+// for a slow receiver before they are dropped. Close the subscription when done.
+func (b *Bus) Subscribe(filter Filter, buffer int) *Subscription {
+	s := &Subscription{bus: b, filter: filter, events: make(chan Event, buffer)}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs[s] = struct{}{}
+	return s
+}
+
+type Subscription struct {
+	bus     *Bus
+	filter  Filter
+	events  chan Event
+	dropped atomic.Uint64
+	once    sync.Once
+}
+
+// Events is closed by Close
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Dropped is the number of the events lost because the buffer was full
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.bus.mu.Lock()
+		defer s.bus.mu.Unlock()
+		delete(s.bus.subs, s)
+		close(s.events)
+	})
+}
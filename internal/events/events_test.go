@@ -0,0 +1,115 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBus(t *testing.T) {
+	bus := NewBus()
+	all := bus.Subscribe(Filter{}, 10)
+	failures := bus.Subscribe(Filter{Types: []string{TypeFailed}, Hosts: []string{"*.a.com"}}, 1)
+
+	bus.Publish(Event{Type: TypeFetched, Host: "www.a.com"})
+	bus.Publish(Event{Type: TypeFailed, Host: "WWW.A.com"})
+	bus.Publish(Event{Type: TypeFailed, Host: "b.com"})
+	bus.Publish(Event{Type: TypeFailed, Host: "cdn.a.com"})
+
+	if len(all.Events()) != 4 {
+		t.Errorf("got %d events, want all 4", len(all.Events()))
+	}
+	if e := <-failures.Events(); e.Host != "WWW.A.com" {
+		t.Errorf("got %+v", e)
+	}
+	if failures.Dropped() != 1 {
+		t.Errorf("got %d dropped, want the event of cdn.a.com not fitting the buffer", failures.Dropped())
+	}
+
+	failures.Close()
+	failures.Close()
+	if _, ok := <-failures.Events(); ok {
+		t.Error("the closed subscription receives")
+	}
+	bus.Publish(Event{Type: TypeFailed, Host: "www.a.com"})
+	if len(all.Events()) != 5 {
+		t.Errorf("got %d events after the other subscription is closed", len(all.Events()))
+	}
+}
+
+func TestSSEHandler(t *testing.T) {
+	bus := NewBus()
+	srv := httptest.NewServer(http.HandlerFunc(NewSSEHandler(bus).Handler))
+	defer srv.Close()
+
+	if resp, err := http.Get(srv.URL + "?type=exploded"); err != nil || resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("got %v for an unknown type", resp.Status)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"?type=fetched,failed&host=a.com", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Errorf("got Content-Type %s", resp.Header.Get("Content-Type"))
+	}
+
+	// the subscription is made before the headers are sent
+	bus.Publish(Event{Type: TypeEnqueued, Host: "a.com", URL: "http://a.com/1"})
+	bus.Publish(Event{Type: TypeFetched, Host: "b.com", URL: "http://b.com/"})
+	bus.Publish(Event{Type: TypeFailed, Host: "a.com", URL: "http://a.com/2", StatusCode: 500})
+
+	lines := bufio.NewScanner(resp.Body)
+	var eventLine, dataLine string
+	for lines.Scan() && dataLine == "" {
+		if v, ok := strings.CutPrefix(lines.Text(), "event: "); ok {
+			eventLine = v
+		}
+		if v, ok := strings.CutPrefix(lines.Text(), "data: "); ok {
+			dataLine = v
+		}
+	}
+	var e Event
+	if err := json.Unmarshal([]byte(dataLine), &e); err != nil {
+		t.Fatal(err)
+	}
+	if eventLine != TypeFailed || e.URL != "http://a.com/2" || e.StatusCode != 500 {
+		t.Errorf("got %s %+v, want only the failed event of a.com", eventLine, e)
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	bus := NewBus()
+	r := NewRecorder(3)
+	sub := bus.Subscribe(Filter{Types: []string{TypeFailed}}, 10)
+	for _, u := range []string{"http://a.com/1", "http://a.com/2", "http://a.com/3", "http://a.com/4"} {
+		bus.Publish(Event{Type: TypeFailed, URL: u})
+		bus.Publish(Event{Type: TypeFetched, URL: u})
+	}
+	sub.Close()
+	r.Record(sub)
+
+	var urls []string
+	for _, e := range r.Recent(0) {
+		urls = append(urls, e.URL)
+	}
+	if got := strings.Join(urls, " "); got != "http://a.com/4 http://a.com/3 http://a.com/2" {
+		t.Errorf("got %s", got)
+	}
+
+	rec := httptest.NewRecorder()
+	r.Handler(rec, httptest.NewRequest(http.MethodGet, "/api/v1/errors?limit=1", nil))
+	var recent []Event
+	if err := json.Unmarshal(rec.Body.Bytes(), &recent); err != nil || len(recent) != 1 || recent[0].URL != "http://a.com/4" {
+		t.Errorf("got %s, err %v", rec.Body, err)
+	}
+}
@@ -0,0 +1,73 @@
+package events
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// Recorder keeps the last events of a subscription, e.g. the recent failures shown by the dashboard
+type Recorder struct {
+	mu     sync.Mutex
+	events []Event
+	// next is the ring position of the next event, the ring is full once count reaches its length
+	next  int
+	count int
+}
+
+func NewRecorder(size int) *Recorder {
+	return &Recorder{events: make([]Event, size)}
+}
+
+// Record keeps the events of the subscription until it is closed
+func (r *Recorder) Record(sub *Subscription) {
+	for e := range sub.Events() {
+		r.Add(e)
+	}
+}
+
+func (r *Recorder) Add(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.events) == 0 {
+		return
+	}
+	r.events[r.next] = e
+	r.next = (r.next + 1) % len(r.events)
+	r.count = min(r.count+1, len(r.events))
+}
+
+// Recent returns up to limit of the kept events, the newest first, limit 0 returns all
+func (r *Recorder) Recent(limit int) []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := r.count
+	if limit > 0 && limit < n {
+		n = limit
+	}
+	recent := make([]Event, 0, n)
+	for i := 1; i <= n; i++ {
+		recent = append(recent, r.events[(r.next-i+len(r.events))%len(r.events)])
+	}
+	return recent
+}
+
+// Handler lists the recent events as JSON, ?limit= caps the list
+func (r *Recorder) Handler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	limit := 0
+	if v := req.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "limit must be a non-negative number", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(r.Recent(limit))
+}
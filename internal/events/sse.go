@@ -0,0 +1,92 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Source is where the handler subscribes, see fetcher.Crawler.Subscribe
+type Source interface {
+	Subscribe(filter Filter, buffer int) *Subscription
+}
+
+const (
+	sseBuffer = 1024
+	// heartbeat keeps the idle connections open through proxies
+	heartbeat = 15 * time.Second
+)
+
+// SSEHandler streams the events as Server-Sent Events. ?type= and ?host= filter them, both can be
+// repeated or comma separated. A "dropped" event tells the client that it missed events.
+type SSEHandler struct {
+	Source Source
+}
+
+func NewSSEHandler(source Source) *SSEHandler {
+	return &SSEHandler{Source: source}
+}
+
+func (h *SSEHandler) Handler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	filter := Filter{Types: queryList(r, "type"), Hosts: queryList(r, "host")}
+	for _, t := range filter.Types {
+		if !contains(Types, t) {
+			http.Error(w, fmt.Sprintf("unknown event type %q, expected one of %s", t, strings.Join(Types, ", ")), http.StatusBadRequest)
+			return
+		}
+	}
+
+	sub := h.Source.Subscribe(filter, sseBuffer)
+	defer sub.Close()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+	var dropped uint64
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+			if n := sub.Dropped(); n > dropped {
+				fmt.Fprintf(w, "event: dropped\ndata: {\"dropped\":%d}\n\n", n-dropped)
+				dropped = n
+			}
+			flusher.Flush()
+		case <-ticker.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+func queryList(r *http.Request, name string) []string {
+	var values []string
+	for _, v := range r.URL.Query()[name] {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+	}
+	return values
+}
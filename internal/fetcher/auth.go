@@ -0,0 +1,73 @@
+package fetcher
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// HostAuth holds the credentials sent to a single host. They are never sent anywhere else.
+type HostAuth struct {
+	Username    string
+	Password    string
+	BearerToken string
+	Cookies     map[string]string
+}
+
+func (wf *WebFetcher) SetHostAuth(host string, auth HostAuth) {
+	wf.auth[host] = auth
+}
+
+func (wf *WebFetcher) authorize(req *http.Request) {
+	auth, ok := wf.auth[req.URL.Hostname()]
+	if !ok && wf.profiles != nil {
+		if prof := wf.profiles.For(req.URL.Hostname()); prof.Auth != nil {
+			auth, ok = HostAuth(*prof.Auth), true
+		}
+	}
+	if !ok {
+		return
+	}
+
+	if auth.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+auth.BearerToken)
+	} else if auth.Username != "" {
+		req.SetBasicAuth(auth.Username, auth.Password)
+	}
+	for name, value := range auth.Cookies {
+		req.AddCookie(&http.Cookie{Name: name, Value: value})
+	}
+}
+
+// Login posts the form fields to the login page. Session cookies set by the response end up in the
+// cookie jar, so it has to be configured beforehand.
+func (wf *WebFetcher) Login(loginURL string, fields map[string]string) error {
+	if wf.client.Jar == nil {
+		return fmt.Errorf("login requires a cookie jar")
+	}
+
+	form := url.Values{}
+	for k, v := range fields {
+		form.Set(k, v)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, loginURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("unable to build the login request, %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	wf.authorize(req)
+
+	// the login usually answers with a redirect, it is not followed and counts as a success
+	response, err := wf.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("login request failed, %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("login rejected with status %d", response.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package fetcher
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestDownloadHostAuth(t *testing.T) {
+	var authTest = []struct {
+		name       string
+		auth       HostAuth
+		wantHeader string
+		wantCookie string
+	}{
+		{name: "basic", auth: HostAuth{Username: "user", Password: "pass"}, wantHeader: "Basic dXNlcjpwYXNz"},
+		{name: "bearer", auth: HostAuth{BearerToken: "token"}, wantHeader: "Bearer token"},
+		{name: "static cookie", auth: HostAuth{Cookies: map[string]string{"sid": "42"}}, wantCookie: "42"},
+	}
+
+	for _, tt := range authTest {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if got := r.Header.Get("Authorization"); got != tt.wantHeader {
+					t.Errorf("got Authorization %q, want %q", got, tt.wantHeader)
+				}
+				if c, err := r.Cookie("sid"); tt.wantCookie != "" && (err != nil || c.Value != tt.wantCookie) {
+					t.Errorf("got cookie %v, %v", c, err)
+				}
+				w.Header().Set("Content-Type", "text/html")
+			}))
+			defer srv.Close()
+
+			u, _ := url.Parse(srv.URL)
+			wf := NewWebFetcher([]string{"text/html"})
+			wf.SetHostAuth(u.Hostname(), tt.auth)
+			if _, err := wf.Download(srv.URL); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestLogin(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.PostFormValue("user") != "admin" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3cr3t", Path: "/"})
+		http.Redirect(w, r, "/", http.StatusFound)
+	})
+	mux.HandleFunc("/private", func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("session"); err != nil || c.Value != "s3cr3t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	wf := NewWebFetcher([]string{"text/html"})
+	if err := wf.Login(srv.URL+"/login", map[string]string{"user": "admin"}); err == nil {
+		t.Error("login without a cookie jar should fail")
+	}
+
+	jar, _ := cookiejar.New(nil)
+	wf.SetCookieJar(jar)
+	if err := wf.Login(srv.URL+"/login", map[string]string{"user": "nobody"}); err == nil {
+		t.Error("rejected login should fail")
+	}
+	if err := wf.Login(srv.URL+"/login", map[string]string{"user": "admin"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wf.Download(srv.URL + "/private"); err != nil {
+		t.Errorf("session is not used: %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package fetcher
+
+import (
+	"crawler/internal/parser"
+	"crawler/internal/seed"
+	"crawler/internal/storage"
+	"errors"
+)
+
+var (
+	ErrNotRunning       = errors.New("the crawl is not running")
+	ErrQueueNotEditable = errors.New("the queue does not support editing")
+)
+
+// EditableQueue is a queue whose items can be changed while they wait, like storage.FrontierRepository
+type EditableQueue interface {
+	Remove(url string) (bool, error)
+	Reprioritize(url string, priority float64) (bool, error)
+}
+
+// Pause stops handing out new tasks, the requests already started are finished
+func (c *Crawler) Pause() {
+	c.pauseMu.Lock()
+	defer c.pauseMu.Unlock()
+	if c.resumed == nil {
+		c.resumed = make(chan struct{})
+		c.logger.Println("The crawl is paused")
+	}
+}
+
+func (c *Crawler) Resume() {
+	c.pauseMu.Lock()
+	defer c.pauseMu.Unlock()
+	if c.resumed != nil {
+		close(c.resumed)
+		c.resumed = nil
+		c.logger.Println("The crawl is resumed")
+	}
+}
+
+func (c *Crawler) Paused() bool {
+	c.pauseMu.Lock()
+	defer c.pauseMu.Unlock()
+	return c.resumed != nil
+}
+
+// waitResumed blocks while the crawl is paused, it returns false when stop is closed meanwhile
+func (c *Crawler) waitResumed(stop <-chan struct{}) bool {
+	c.pauseMu.Lock()
+	resumed := c.resumed
+	c.pauseMu.Unlock()
+	if resumed == nil {
+		return true
+	}
+	select {
+	case <-resumed:
+		return true
+	case <-stop:
+		return false
+	}
+}
+
+// Stop ends the crawl like a signal does: the tasks not started yet go back to the queue, its state
+// is saved and Crawl returns an interrupted Summary. A Stop while no crawl runs does not end the next one.
+func (c *Crawler) Stop() {
+	select {
+	case c.stopRequests <- struct{}{}:
+	default:
+		// a stop is already requested
+	}
+}
+
+// AddSeeds extends the running crawl with the seeds and their scope, it returns how many were queued.
+// The seeds seen before are not queued again, ErrNotRunning is returned before and after a crawl.
+func (c *Crawler) AddSeeds(seeds []seed.Seed) (int, error) {
+	scope := c.scope.Load()
+	if scope == nil {
+		return 0, ErrNotRunning
+	}
+	queued := 0
+	for _, s := range seeds {
+		scope.Add(s)
+		added, err := c.enqueueNew(storage.QueueItem{URL: s.URL, Source: parser.SourceSeed, MaxDepth: s.MaxDepth, Seed: s.URL})
+		if err != nil {
+			return queued, err
+		}
+		if added {
+			queued++
+		}
+	}
+	return queued, nil
+}
+
+// RemoveFromQueue drops the waiting url, false means it was not queued
+func (c *Crawler) RemoveFromQueue(url string) (bool, error) {
+	queue, ok := c.queue.(EditableQueue)
+	if !ok {
+		return false, ErrQueueNotEditable
+	}
+	return queue.Remove(url)
+}
+
+// Reprioritize queues the waiting url again with the priority added to its score, false means it was not queued
+func (c *Crawler) Reprioritize(url string, priority float64) (bool, error) {
+	queue, ok := c.queue.(EditableQueue)
+	if !ok {
+		return false, ErrQueueNotEditable
+	}
+	return queue.Reprioritize(url, priority)
+}
+
+// Unblacklist removes the url from the blacklist and forgets it was seen, so it is crawled again when found
+// or added as a seed. False means it was not blacklisted.
+func (c *Crawler) Unblacklist(url string) (bool, error) {
+	if !c.blacklist.DoesExist(url) {
+		return false, nil
+	}
+	if err := c.blacklist.RemoveFromList(url); err != nil {
+		return false, err
+	}
+	if seen, ok := c.seen.(interface{ Remove(url string) error }); ok {
+		return true, seen.Remove(url)
+	}
+	return true, nil
+}
@@ -0,0 +1,142 @@
+package fetcher
+
+import (
+	"crawler/internal/events"
+	"crawler/internal/seed"
+	"crawler/internal/storage"
+	"errors"
+	bolt "go.etcd.io/bbolt"
+	"io"
+	"log"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// priorityScorer orders the queue by the priority set by hand only
+type priorityScorer struct{}
+
+func (priorityScorer) Score(item storage.QueueItem, hostCount int) float64 { return item.Priority }
+
+func newControlledCrawler(t *testing.T) (*Crawler, *storage.FrontierRepository, *storage.SeenSet) {
+	t.Helper()
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "test.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	queue, err := storage.NewFrontierRepository(db, priorityScorer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen, err := storage.NewSeenSet(db, 1000, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	queue.SetSeenSet(seen)
+	c := NewCrawler(log.New(io.Discard, "", 0), 2, nil, nil, nil, queue, storage.NewHashList(), "")
+	c.SetSeenSet(seen)
+	return c, queue, seen
+}
+
+func TestCrawlerQueueControl(t *testing.T) {
+	c, queue, _ := newControlledCrawler(t)
+	if _, err := c.AddSeeds([]seed.Seed{{URL: "http://a.com/"}}); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("got %v, want ErrNotRunning before the crawl", err)
+	}
+
+	c.scope.Store(seed.NewScopes())
+	sub := c.Subscribe(events.Filter{Hosts: []string{"b.com"}}, 10)
+	queued, err := c.AddSeeds([]seed.Seed{{URL: "http://a.com/"}, {URL: "http://b.com/", Scope: []string{"*.c.com"}}, {URL: "http://a.com/"}})
+	if err != nil || queued != 2 {
+		t.Fatalf("got %d queued, %v", queued, err)
+	}
+	sub.Close()
+	var got []events.Event
+	for e := range sub.Events() {
+		got = append(got, e)
+	}
+	if len(got) != 1 || got[0].Type != events.TypeEnqueued || got[0].URL != "http://b.com/" || got[0].Source != "seed" {
+		t.Errorf("got events %+v", got)
+	}
+	if !c.hostInScope("a.com", nil, "") || !c.hostInScope("www.c.com", nil, "http://b.com/") || c.hostInScope("www.c.com", nil, "http://a.com/") {
+		t.Error("the scope of the added seeds is missing")
+	}
+
+	if ok, err := c.Reprioritize("http://b.com/", 10); !ok || err != nil {
+		t.Fatalf("got %v, %v", ok, err)
+	}
+	if ok, _ := c.Reprioritize("http://d.com/", 10); ok {
+		t.Error("reprioritized a url which is not queued")
+	}
+	if item, _ := queue.Pull(); item.URL != "http://b.com/" || item.Priority != 10 {
+		t.Errorf("got %+v first, want the reprioritized url", item)
+	}
+
+	if ok, err := c.RemoveFromQueue("http://a.com/"); !ok || err != nil || queue.Size() != 0 {
+		t.Errorf("got %v, %v, %d left", ok, err, queue.Size())
+	}
+}
+
+func TestCrawlerUnblacklist(t *testing.T) {
+	c, _, seen := newControlledCrawler(t)
+	c.scope.Store(seed.NewScopes())
+	if _, err := seen.Add("http://a.com/broken"); err != nil {
+		t.Fatal(err)
+	}
+	c.blacklist.AddWithReason("http://a.com/broken", ReasonFetchError)
+
+	if ok, err := c.Unblacklist("http://a.com/broken"); !ok || err != nil {
+		t.Fatalf("got %v, %v", ok, err)
+	}
+	if c.blacklist.DoesExist("http://a.com/broken") {
+		t.Error("the url is still blacklisted")
+	}
+	if queued, _ := c.AddSeeds([]seed.Seed{{URL: "http://a.com/broken"}}); queued != 1 {
+		t.Error("the unblacklisted url is not crawled again")
+	}
+	if ok, _ := c.Unblacklist("http://a.com/other"); ok {
+		t.Error("unblacklisted a url which is not blacklisted")
+	}
+}
+
+func TestCrawlerPauseAndStop(t *testing.T) {
+	c, queue, _ := newControlledCrawler(t)
+	// the stop before the crawl is not kept for it
+	c.Stop()
+	c.Pause()
+	done := make(chan Summary, 1)
+	go func() {
+		done <- c.Crawl([]seed.Seed{{URL: "http://a.com/"}}, make(chan bool))
+	}()
+
+	// the paused crawl neither fetches nor ends
+	time.Sleep(700 * time.Millisecond)
+	if !c.Paused() || queue.Size() != 1 {
+		t.Fatalf("got paused %v with %d queued", c.Paused(), queue.Size())
+	}
+	select {
+	case <-done:
+		t.Fatal("the paused crawl ended")
+	default:
+	}
+
+	c.Stop()
+	select {
+	case summary := <-done:
+		if !summary.Interrupted || summary.Fetched != 0 {
+			t.Errorf("got %+v", summary)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the crawl did not stop")
+	}
+	if queue.Size() != 1 {
+		t.Errorf("got %d queued, the seed is lost", queue.Size())
+	}
+	if _, err := c.AddSeeds([]seed.Seed{{URL: "http://b.com/"}}); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("got %v, want ErrNotRunning after the crawl", err)
+	}
+	if queue.Size() != 1 {
+		t.Errorf("got %d queued, the seed added after the crawl is queued", queue.Size())
+	}
+}
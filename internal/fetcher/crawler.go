@@ -1,41 +1,100 @@
 package fetcher
 
 import (
+	"crawler/internal/events"
+	"crawler/internal/layout"
+	"crawler/internal/metrics"
+	"crawler/internal/mirror"
+	"crawler/internal/parser"
+	"crawler/internal/seed"
+	"crawler/internal/simhash"
+	"crawler/internal/storage"
+	"crawler/internal/warc"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"net/url"
-	"os"
-	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type Parser interface {
-	ParseLinks(pageData []byte) ([]string, error)
+	ParseLinks(pageData []byte, contentType string) ([]parser.Link, error)
+	VisibleText(pageData []byte, contentType string) (string, error)
 }
 
 type Fetcher interface {
-	Download(urlString string) ([]byte, error)
+	Download(urlString string) (*Response, error)
 }
 
 type StorageRepository interface {
 	SaveByKey(url string, data []byte) error
 	GetByKey(url string) ([]byte, error)
 	IsExists(url string) bool
+	SavePageRecord(record *storage.PageRecord) error
 }
 
 type QueueInterface interface {
-	Push(url string) error
-	Pull() (string, error)
+	Push(item storage.QueueItem) error
+	Pull() (storage.QueueItem, error)
+	// Defer keeps the item in the queue but out of Pull until notBefore, see storage.FrontierRepository.Defer
+	Defer(item storage.QueueItem, notBefore time.Time) error
+	Deferred() int
 	Size() int
 	SaveState()
 }
 
 type Blacklist interface {
-	AddToList(val string)
-	RemoveFromList(val string)
+	AddWithReason(val string, reason string) (bool, error)
+	RemoveFromList(val string) error
 	DoesExist(url string) bool
 }
 
+type Archive interface {
+	WriteExchange(ex *warc.Exchange) error
+}
+
+// Blacklist reasons, the trap detector adds its own ones starting with trap.ReasonPrefix
+const (
+	ReasonInvalidURL = "invalid url"
+	ReasonOutOfScope = "out of scope"
+	ReasonFetchError = "fetch error"
+)
+
+type TrapDetector interface {
+	Check(link string) (string, bool)
+}
+
+// SeenSet remembers the queued and fetched urls, Add tells whether the url is new
+type SeenSet interface {
+	Add(url string) (bool, error)
+	Contains(url string) bool
+}
+
+// SeenQueue marks the url as seen and queues it in a single write, PushNew tells whether the url
+// was new. It has to write to the seen set of SetSeenSet, see storage.FrontierRepository.PushNew.
+type SeenQueue interface {
+	PushNew(item storage.QueueItem) (bool, error)
+}
+
+// Metrics is told about every request, see metrics.Collector
+type Metrics interface {
+	RequestStarted()
+	RequestDone(r metrics.Request)
+}
+
+// LinkGraph keeps the links between the stored pages, see storage.LinkRepository.SaveLinks
+type LinkGraph interface {
+	SaveLinks(url string, links []string) error
+}
+
+type NearDuplicateIndex interface {
+	Add(url string, fingerprint uint64) (string, int, bool)
+}
+
 type Crawler struct {
 	logger      *log.Logger
 	parallelism int
@@ -45,6 +104,29 @@ type Crawler struct {
 	queue       QueueInterface
 	blacklist   Blacklist
 	downloadDir string
+	archive     Archive
+	saver       *layout.Saver
+	mirror      *mirror.Rewriter
+	// scope is set while Crawl runs, the control API adds the hosts of new seeds to it
+	scope atomic.Pointer[seed.Scopes]
+
+	nearDuplicates   NearDuplicateIndex
+	skipNearDupLinks bool
+	traps            TrapDetector
+	seen             SeenSet
+	linkGraph        LinkGraph
+	profiles         HostProfiles
+	slots            *hostSlots
+	metrics          Metrics
+	events           *events.Bus
+
+	inFlight atomic.Int64
+
+	pauseMu sync.Mutex
+	// resumed is closed by Resume, it is nil while the crawl is not paused
+	resumed chan struct{}
+	// stopRequests ends the crawl like the exit channel of Crawl, see Stop
+	stopRequests chan struct{}
 }
 
 func NewCrawler(
@@ -66,88 +148,295 @@ func NewCrawler(
 		queue:       q,
 		blacklist:   b,
 		downloadDir: d,
+		saver:       layout.NewSaver(d, layout.Mirrored{}),
+		slots:       newHostSlots(),
+		events:      events.NewBus(),
+
+		stopRequests: make(chan struct{}, 1),
 	}
 }
 
+// SetLayout changes how the downloaded files are named, the mirrored site structure is the default
+func (c *Crawler) SetLayout(strategy layout.Strategy) {
+	c.saver = layout.NewSaver(c.downloadDir, strategy)
+}
+
+// SetArchive enables writing every exchange to the archive, e.g. WARC files
+func (c *Crawler) SetArchive(a Archive) {
+	c.archive = a
+}
+
+// EnableMirror saves the pages as a browsable mirror: links between the crawled pages are rewritten
+// to relative local paths. It only makes sense with the mirrored layout.
+func (c *Crawler) EnableMirror() {
+	c.mirror = mirror.NewRewriter(func(u *url.URL) bool {
+		return c.inScope(u.String())
+	})
+}
+
+// SetNearDuplicates enables the SimHash check of HTML pages. With skipLinks the links of near-duplicate
+// pages are not followed, which breaks the traps generating endless variations of the same page.
+func (c *Crawler) SetNearDuplicates(index NearDuplicateIndex, skipLinks bool) {
+	c.nearDuplicates = index
+	c.skipNearDupLinks = skipLinks
+}
+
+// SetTrapDetector makes the crawler blacklist the discovered links looking like a crawler trap
+func (c *Crawler) SetTrapDetector(d TrapDetector) {
+	c.traps = d
+}
+
+// SetSeenSet replaces the per-link database lookups of the fetched pages with the seen set,
+// which also skips the urls waiting in the queue
+func (c *Crawler) SetSeenSet(s SeenSet) {
+	c.seen = s
+}
+
+// SetLinkGraph records the in-scope links of every stored page, so the pages linking to a url can be listed
+func (c *Crawler) SetLinkGraph(g LinkGraph) {
+	c.linkGraph = g
+}
+
+// isNew marks the link as seen, without a seen set only the fetched pages count
+func (c *Crawler) isNew(link string) bool {
+	if c.seen == nil {
+		return !c.linkRepo.IsExists(link)
+	}
+	added, err := c.seen.Add(link)
+	if err != nil {
+		c.logger.Println("Cannot update the seen set, err: ", err)
+		return !c.linkRepo.IsExists(link)
+	}
+	return added
+}
+
+// wasSeen tells the link was queued or fetched before without marking it
+func (c *Crawler) wasSeen(link string) bool {
+	if c.seen == nil {
+		return c.linkRepo.IsExists(link)
+	}
+	return c.seen.Contains(link)
+}
+
+// SetProfiles makes the crawler keep the per-host parallelism and delay of the profiles,
+// they also include hosts into the scope or exclude them
+func (c *Crawler) SetProfiles(profiles HostProfiles) {
+	c.profiles = profiles
+}
+
 type FetchTask struct {
-	Link string
+	Link     string
+	Depth    int
+	MaxDepth int
+	Source   string
+	Priority float64
+	Seed     string
 }
 
-func (c *Crawler) JobProducer(linksChan chan *FetchTask) {
-	go func() {
-		for {
-			if c.queue.Size() > 0 {
-				item, err := c.queue.Pull()
-				if err != nil {
-					c.logger.Println("error during the pulling the next item from the queue, err: ", err)
-					return
-				}
-				linksChan <- &FetchTask{Link: item}
-			} else {
-				time.Sleep(500 * time.Millisecond)
+func (t *FetchTask) queueItem() storage.QueueItem {
+	return storage.QueueItem{URL: t.Link, Depth: t.Depth, MaxDepth: t.MaxDepth, Source: t.Source, Priority: t.Priority, Seed: t.Seed}
+}
+
+// JobProducer sends the queued items to the channel until stop is closed. The items in flight are counted
+// from the pull until the task is processed, so an empty queue with no items in flight means the crawl is done.
+// The tasks deferred because their host was busy wait in the queue until the host is due, while too many
+// of them wait the queue is pulled slower. Nothing is pulled while the crawl is paused.
+func (c *Crawler) JobProducer(linksChan chan *FetchTask, stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		if !c.waitResumed(stop) {
+			return
+		}
+		if c.queue.Size() == 0 {
+			time.Sleep(500 * time.Millisecond)
+			continue
+		}
+		if c.queue.Deferred() >= maxDeferredTasks {
+			// most of the pulled tasks would be deferred again, every pull is a write
+			time.Sleep(50 * time.Millisecond)
+		}
+
+		c.inFlight.Add(1)
+		item, err := c.queue.Pull()
+		if err != nil {
+			c.inFlight.Add(-1)
+			if errors.Is(err, storage.ErrEmptyQueue) {
+				// only the deferred tasks wait, none is due yet
+				time.Sleep(50 * time.Millisecond)
+				continue
 			}
+			c.logger.Println("error during the pulling the next item from the queue, err: ", err)
+			return
 		}
-	}()
+		task := &FetchTask{Link: item.URL, Depth: item.Depth, MaxDepth: item.MaxDepth, Source: item.Source, Priority: item.Priority, Seed: item.Seed}
+		if !c.sendTask(linksChan, task, stop) {
+			return
+		}
+	}
 }
 
-func (c *Crawler) ExecuteLink(urlString string) ([]string, []byte, error) {
+// sendTask hands the task in flight to a worker, the task is not lost when the crawl stops meanwhile
+func (c *Crawler) sendTask(linksChan chan *FetchTask, task *FetchTask, stop <-chan struct{}) bool {
+	select {
+	case linksChan <- task:
+		return true
+	case <-stop:
+		c.requeue(task)
+		c.inFlight.Add(-1)
+		return false
+	}
+}
+
+// ExecuteLink downloads and parses the page, the links are kept in the scope of the seed the page was
+// reached from. The response is returned even on errors when the server answered, so its metadata can be recorded.
+func (c *Crawler) ExecuteLink(urlString, seedURL string) ([]parser.Link, *Response, error) {
 	_, err := url.Parse(urlString)
 	if err != nil {
 		c.logger.Printf("Invalid URL, parsing error: %s", err)
-		return nil, nil, fmt.Errorf("invalid URL, parsing error: %w", err)
+		return nil, nil, fmt.Errorf("%w, parsing error: %w", ErrInvalidURL, err)
 	}
 
-	body, err := c.fetcher.Download(urlString)
+	resp, err := c.fetcher.Download(urlString)
 	if err != nil {
-		return nil, nil, fmt.Errorf("download error for url %s, %err", urlString, err)
+		return nil, resp, fmt.Errorf("download error for url %s, %w", urlString, err)
 	}
 
-	links, err := c.parser.ParseLinks(body)
+	links, err := c.parser.ParseLinks(resp.Body, resp.ContentType)
 	if err != nil {
-		return nil, nil, fmt.Errorf("unable to parse links from the page %s")
+		return nil, resp, fmt.Errorf("%w from the page %s", ErrParse, urlString)
 	}
 
-	c.saveFile(urlString, body)
+	// the original bytes are saved, transcoding is only needed for the link extraction
+	if c.downloadDir != "" {
+		c.saveFile(urlString, resp)
+	}
 
-	return c.filterLinks(urlString, links), body, nil
+	return c.filterLinks(urlString, seedURL, links), resp, nil
 }
 
-func (c *Crawler) saveFile(urlString string, body []byte) {
-	u, _ := url.Parse(urlString)
-	targetFileName := filepath.Base(u.Path)
-	if "." == targetFileName {
-		targetFileName = "index.html"
+// archivePage writes successful pages and the pages the server refused, e.g. redirects and 404s
+func (c *Crawler) archivePage(urlString string, resp *Response, links []string, fetchErr error) {
+	if c.archive == nil || resp == nil || (fetchErr != nil && resp.StatusCode == http.StatusOK) {
+		return
+	}
+	truncated := ""
+	if resp.Truncated {
+		truncated = "unspecified"
 	}
-	filename := filepath.Join(".", c.downloadDir, ".", u.Hostname(), u.Path, targetFileName)
 
-	err := os.MkdirAll(filepath.Dir(filename), 0755)
+	err := c.archive.WriteExchange(&warc.Exchange{
+		URL:           urlString,
+		IP:            resp.IP,
+		Date:          resp.FetchedAt,
+		RequestMethod: http.MethodGet,
+		RequestHeader: resp.RequestHeader,
+		Proto:         resp.Proto,
+		Status:        resp.Status,
+		StatusCode:    resp.StatusCode,
+		Header:        resp.Header,
+		Body:          resp.Body,
+		Truncated:     truncated,
+		Outlinks:      links,
+		Duration:      resp.Duration,
+	})
 	if err != nil {
-		fmt.Printf("Error creating directory: %s\n", err)
-		return
+		c.logger.Println("Cannot archive the page, err: ", err)
+	}
+}
+
+// nearDuplicate is the result of the SimHash check of a single page
+type nearDuplicate struct {
+	fingerprint uint64
+	of          string
+	distance    int
+}
+
+func (c *Crawler) checkNearDuplicate(urlString string, resp *Response) *nearDuplicate {
+	if c.nearDuplicates == nil || resp == nil || !strings.Contains(resp.ContentType, "text/html") {
+		return nil
+	}
+	text, err := c.parser.VisibleText(resp.Body, resp.ContentType)
+	if err != nil || strings.TrimSpace(text) == "" {
+		return nil
+	}
+
+	nd := &nearDuplicate{fingerprint: simhash.Fingerprint(text)}
+	if of, distance, found := c.nearDuplicates.Add(urlString, nd.fingerprint); found {
+		nd.of, nd.distance = of, distance
+	}
+	return nd
+}
+
+func (c *Crawler) recordPage(urlString string, resp *Response, fetchErr error, nd *nearDuplicate) {
+	record := &storage.PageRecord{
+		URL:       urlString,
+		FetchedAt: time.Now(),
+	}
+	if nd != nil {
+		record.SimHash = nd.fingerprint
+		record.NearDuplicateOf = nd.of
+		record.NearDuplicateDistance = nd.distance
+	}
+	if resp != nil {
+		record.FinalURL = resp.FinalURL
+		record.StatusCode = resp.StatusCode
+		record.Header = resp.Header
+		record.IP = resp.IP
+		record.Size = int64(len(resp.Body))
+		record.WireSize = resp.WireSize
+		record.Duration = resp.Duration
+		record.FetchedAt = resp.FetchedAt
+	}
+	if fetchErr != nil {
+		record.Error = fetchErr.Error()
+	} else if resp != nil {
+		record.Digest = storage.BodyDigest(resp.Body)
+	}
+
+	if err := c.linkRepo.SavePageRecord(record); err != nil {
+		c.logger.Println("Cannot save page record, err: ", err)
+	}
+}
+
+func (c *Crawler) saveFile(urlString string, resp *Response) {
+	u, _ := url.Parse(urlString)
+	body := resp.Body
+	if c.mirror != nil {
+		switch {
+		case strings.Contains(resp.ContentType, "text/html"):
+			body = c.mirror.HTML(body, u)
+		case strings.Contains(resp.ContentType, "text/css"):
+			body = c.mirror.CSS(body, u)
+		}
 	}
 
-	err = os.WriteFile(filename, body, 0644)
+	path, err := c.saver.Save(u, body)
 	if err != nil {
-		fmt.Printf("Error saving HTML: %s\n", err)
+		c.logger.Printf("Error saving %s: %s\n", urlString, err)
 		return
 	}
+	c.publish(events.Event{Type: events.TypeSaved, URL: urlString, Path: path})
 }
 
-func (c *Crawler) filterLinks(originalLink string, links []string) []string {
-	var filteredLinks []string
+func (c *Crawler) filterLinks(originalLink, seedURL string, links []parser.Link) []parser.Link {
+	var filteredLinks []parser.Link
 	original, _ := url.Parse(originalLink)
 
 	for i := range links {
-		l, err := url.Parse(links[i])
+		l, err := url.Parse(links[i].URL)
 		if err != nil {
-			c.blacklist.AddToList(links[i])
+			c.addToBlacklist(links[i].URL, ReasonInvalidURL)
 			continue
 		}
 		if "" == l.Hostname() {
 			l.Host = original.Host
 		} else {
-			if l.Hostname() != original.Hostname() {
-				c.blacklist.AddToList(l.String())
+			if !c.hostInScope(l.Hostname(), original, seedURL) {
+				c.addToBlacklist(l.String(), ReasonOutOfScope)
 				continue
 			}
 		}
@@ -155,87 +444,334 @@ func (c *Crawler) filterLinks(originalLink string, links []string) []string {
 			l.Scheme = original.Scheme
 		} else {
 			if l.Scheme != original.Scheme {
-				c.blacklist.AddToList(l.String())
+				c.addToBlacklist(l.String(), ReasonOutOfScope)
 				continue
 			}
 		}
 
-		filteredLinks = append(filteredLinks, l.String())
+		filteredLinks = append(filteredLinks, parser.Link{URL: l.String(), Source: links[i].Source})
 	}
 
 	return filteredLinks
 }
 
-func (c *Crawler) Crawl(urlString string, exitChan chan bool) {
-	u, err := url.Parse(urlString)
-	if err != nil {
-		c.logger.Printf("Invalid URL, parsing error: %s\n", err)
-		return
+// Summary tells how the crawl ended
+type Summary struct {
+	Fetched int
+	Failed  int
+	// Interrupted is set when the crawl was stopped before the queue was drained
+	Interrupted bool
+}
+
+// Crawl fetches the seeds and everything reachable from them inside the scope: the hosts of the seeds
+// and their scope overrides. Seeds seen before, e.g. when the crawl is resumed, are not queued again.
+// It returns once the queue is drained, or exitChan receives or Stop is called.
+func (c *Crawler) Crawl(seeds []seed.Seed, exitChan chan bool) Summary {
+	c.scope.Store(seed.ScopesOf(seeds))
+	// without a scope the seeds added later are refused, nobody would fetch them
+	defer c.scope.Store(nil)
+	// a stop requested before the crawl, or after the previous one ended, is not meant for this one
+	select {
+	case <-c.stopRequests:
+	default:
 	}
-	domain := u.Hostname()
+	var summary Summary
 
 	linkBuf := make(chan *FetchTask, c.parallelism)
-	doneChan := make(chan bool)
 
-	// size = 0 means there is no postponed work and probably it is the first run
-	if c.queue.Size() == 0 {
-		err = c.queue.Push(urlString)
+	for _, s := range seeds {
+		_, err := c.enqueueNew(storage.QueueItem{URL: s.URL, Source: parser.SourceSeed, MaxDepth: s.MaxDepth, Seed: s.URL})
 		if err != nil {
 			c.logger.Println("Cannot push link to the queue, err: ", err)
 		}
 	}
 
-	go c.JobProducer(linkBuf)
+	stop := make(chan struct{})
+	var producer, workers sync.WaitGroup
+	producer.Add(1)
 	go func() {
-		for {
-			select {
-			case link := <-linkBuf:
-				if !c.isValidLink(domain, link.Link) {
-					c.blacklist.AddToList(link.Link)
-					break
-				}
-				newLinks, pageData, err := c.ExecuteLink(link.Link)
-				c.logger.Println(fmt.Sprintf("DEBUG: got new links, %d", len(newLinks)))
-				if err != nil {
-					c.blacklist.AddToList(link.Link)
-				} else {
-					err = c.linkRepo.SaveByKey(link.Link, pageData)
-					if err != nil {
-						c.logger.Println("Cannot save link by key, err: ", err)
-					}
-				}
+		defer producer.Done()
+		c.JobProducer(linkBuf, stop)
+	}()
 
-				for i := range newLinks {
-					if c.linkRepo.IsExists(newLinks[i]) || c.blacklist.DoesExist(newLinks[i]) {
-						continue
+	var mu sync.Mutex
+	for i := 0; i < max(c.parallelism, 1); i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				case link := <-linkBuf:
+					// the tasks pulled before the pause wait for the resume too
+					if !c.waitResumed(stop) {
+						c.requeue(link)
+						c.inFlight.Add(-1)
+						return
 					}
-					err = c.queue.Push(newLinks[i])
-					if err != nil {
-						c.logger.Println("Cannot push link to the queue, err: ", err)
+					fetched, err := c.runTask(link)
+					mu.Lock()
+					switch {
+					case err != nil:
+						summary.Failed++
+					case fetched:
+						summary.Fetched++
 					}
+					mu.Unlock()
+					c.inFlight.Add(-1)
 				}
+			}
+		}()
+	}
 
-			case <-exitChan:
-				c.queue.SaveState()
-				c.logger.Println("State saved")
-				doneChan <- true
-				return
-				// there might be any stop&exit procedures but we already have something persistent-like
+	idle := time.NewTicker(500 * time.Millisecond)
+	defer idle.Stop()
+wait:
+	for {
+		select {
+		case <-idle.C:
+			// a paused crawl waits for the resume even with nothing left to do, seeds may be added meanwhile
+			if c.queue.Size() > 0 || c.inFlight.Load() > 0 || c.Paused() {
+				continue
 			}
+			c.logger.Println("The queue is empty, the crawl is done")
+			break wait
+		case <-exitChan:
+			summary.Interrupted = true
+			break wait
+		case <-c.stopRequests:
+			c.logger.Println("Stop requested")
+			summary.Interrupted = true
+			break wait
 		}
-	}()
+	}
+	close(stop)
+	producer.Wait()
+	workers.Wait()
 
-	<-doneChan
+	// the tasks nobody took go back to the queue
+	for len(linkBuf) > 0 {
+		c.requeue(<-linkBuf)
+		c.inFlight.Add(-1)
+	}
+	if summary.Interrupted {
+		c.queue.SaveState()
+		c.logger.Println("State saved")
+	}
+	return summary
 }
 
-func (c *Crawler) isValidLink(domain string, link string) bool {
-	u, err := url.Parse(link)
+// runTask processes the task in a slot of the host, fetched is false when the task was skipped or deferred.
+// A busy host defers the task in the queue instead of holding the worker, so the other hosts are fetched meanwhile.
+func (c *Crawler) runTask(link *FetchTask) (bool, error) {
+	u, err := url.Parse(link.Link)
+	if err != nil || !c.hostInScope(u.Hostname(), nil, link.Seed) {
+		c.addToBlacklist(link.Link, ReasonOutOfScope)
+		return false, nil
+	}
+
+	host := u.Hostname()
+	var limit int
+	var delay time.Duration
+	if c.profiles != nil {
+		prof := c.profiles.For(host)
+		limit, delay = prof.Parallelism, prof.Delay
+	}
+	if wait := c.slots.tryAcquire(host, limit, delay); wait > 0 {
+		if err := c.queue.Defer(link.queueItem(), time.Now().Add(wait)); err != nil {
+			c.logger.Println("Cannot defer link in the queue, err: ", err)
+			c.requeue(link)
+		}
+		return false, nil
+	}
+	defer c.slots.release(host)
+
+	return true, c.processTask(link)
+}
+
+func (c *Crawler) requeue(link *FetchTask) {
+	if err := c.queue.Push(link.queueItem()); err != nil {
+		c.logger.Println("Cannot push link to the queue, err: ", err)
+	}
+}
+
+// SetMetrics reports the outcome of every request to m
+func (c *Crawler) SetMetrics(m Metrics) {
+	c.metrics = m
+}
+
+// processTask fetches the page and queues its new links, the fetch error is returned
+func (c *Crawler) processTask(link *FetchTask) error {
+	if c.metrics != nil {
+		c.metrics.RequestStarted()
+	}
+	c.publish(events.Event{Type: events.TypeFetchStarted, URL: link.Link, Depth: link.Depth, Source: link.Source})
+	start := time.Now()
+	newLinks, resp, err := c.ExecuteLink(link.Link, link.Seed)
+	c.recordRequest(link.Link, resp, err, time.Since(start))
+	c.publishFetch(link.Link, resp, err)
+	var nd *nearDuplicate
+	if err == nil {
+		nd = c.checkNearDuplicate(link.Link, resp)
+	}
+	if nd != nil && nd.of != "" && c.skipNearDupLinks {
+		c.logger.Printf("%s is a near-duplicate of %s, its links are skipped", link.Link, nd.of)
+		newLinks = nil
+	}
+	c.recordPage(link.Link, resp, err, nd)
+	outlinks := make([]string, 0, len(newLinks))
+	for i := range newLinks {
+		outlinks = append(outlinks, newLinks[i].URL)
+	}
+	c.archivePage(link.Link, resp, outlinks, err)
+	if link.MaxDepth > 0 && link.Depth >= link.MaxDepth {
+		newLinks = nil
+	}
+	fetchErr := err
 	if err != nil {
-		return false
+		c.addToBlacklist(link.Link, ReasonFetchError)
+	} else {
+		err = c.linkRepo.SaveByKey(link.Link, resp.Body)
+		if err != nil {
+			c.logger.Println("Cannot save link by key, err: ", err)
+		}
+		if c.linkGraph != nil {
+			if err := c.linkGraph.SaveLinks(link.Link, outlinks); err != nil {
+				c.logger.Println("Cannot save the links of the page, err: ", err)
+			}
+		}
+	}
+
+	for _, newLink := range newLinks {
+		if c.blacklist.DoesExist(newLink.URL) || c.wasSeen(newLink.URL) {
+			continue
+		}
+		if c.traps != nil {
+			if reason, trapped := c.traps.Check(newLink.URL); trapped {
+				c.addToBlacklist(newLink.URL, reason)
+				continue
+			}
+		}
+		_, err = c.enqueueNew(storage.QueueItem{
+			URL:      newLink.URL,
+			Depth:    link.Depth + 1,
+			MaxDepth: link.MaxDepth,
+			Source:   newLink.Source,
+			Seed:     link.Seed,
+		})
+		if err != nil {
+			c.logger.Println("Cannot push link to the queue, err: ", err)
+		}
+	}
+	return fetchErr
+}
+
+// Subscribe streams the events of the crawl, see events.Bus.Subscribe
+func (c *Crawler) Subscribe(filter events.Filter, buffer int) *events.Subscription {
+	return c.events.Subscribe(filter, buffer)
+}
+
+func (c *Crawler) publish(e events.Event) {
+	if c.events == nil {
+		return
+	}
+	e.Time = time.Now()
+	if u, err := url.Parse(e.URL); err == nil {
+		e.Host = u.Hostname()
+	}
+	c.events.Publish(e)
+}
+
+func (c *Crawler) publishFetch(link string, resp *Response, err error) {
+	e := events.Event{Type: events.TypeFetched, URL: link}
+	if resp != nil {
+		e.StatusCode = resp.StatusCode
+		e.Size = resp.WireSize
+		e.Duration = resp.Duration
+	}
+	if err != nil {
+		e.Type = events.TypeFailed
+		e.Error = err.Error()
+		e.ErrorType = ErrorType(err)
+	}
+	c.publish(e)
+}
+
+// addToBlacklist blacklists the url and tells the subscribers about the change, a link listed again for the same reason is no event
+func (c *Crawler) addToBlacklist(link, reason string) {
+	changed, err := c.blacklist.AddWithReason(link, reason)
+	if err != nil {
+		c.logger.Println("Cannot blacklist the link, err: ", err)
+		return
+	}
+	if changed {
+		c.publish(events.Event{Type: events.TypeBlacklisted, URL: link, Reason: reason})
 	}
-	if u.Hostname() != domain {
+}
+
+// enqueue pushes the new url and tells the subscribers, the urls put back to the queue are not new
+func (c *Crawler) enqueue(item storage.QueueItem) error {
+	if err := c.queue.Push(item); err != nil {
+		return err
+	}
+	c.publish(events.Event{Type: events.TypeEnqueued, URL: item.URL, Depth: item.Depth, Source: item.Source})
+	return nil
+}
+
+// enqueueNew marks the url as seen and pushes it unless it was seen before, it tells whether the url
+// was new. With a seen set and a SeenQueue both are a single write.
+func (c *Crawler) enqueueNew(item storage.QueueItem) (bool, error) {
+	q, ok := c.queue.(SeenQueue)
+	if !ok || c.seen == nil {
+		if !c.isNew(item.URL) {
+			return false, nil
+		}
+		return true, c.enqueue(item)
+	}
+	added, err := q.PushNew(item)
+	if err != nil || !added {
+		return false, err
+	}
+	c.publish(events.Event{Type: events.TypeEnqueued, URL: item.URL, Depth: item.Depth, Source: item.Source})
+	return true, nil
+}
+
+func (c *Crawler) recordRequest(link string, resp *Response, err error, elapsed time.Duration) {
+	if c.metrics == nil {
+		return
+	}
+	r := metrics.Request{ErrorType: ErrorType(err), Duration: elapsed}
+	if u, err := url.Parse(link); err == nil {
+		r.Host = u.Hostname()
+	}
+	if resp != nil {
+		r.StatusCode = resp.StatusCode
+		r.Bytes = resp.WireSize
+		r.Duration = resp.Duration
+	}
+	c.metrics.RequestDone(r)
+}
+
+func (c *Crawler) inScope(link string) bool {
+	u, err := url.Parse(link)
+	if err != nil {
 		return false
 	}
+	return c.hostInScope(u.Hostname(), nil, "")
+}
 
-	return true
+// hostInScope asks the host profile first, then the scope of the seed, see seed.Scopes.Contains.
+// It falls back to the host of the page when the crawl has not started yet.
+func (c *Crawler) hostInScope(host string, page *url.URL, seedURL string) bool {
+	if c.profiles != nil {
+		if inScope := c.profiles.For(host).InScope; inScope != nil {
+			return *inScope
+		}
+	}
+	scope := c.scope.Load()
+	if scope == nil {
+		return page != nil && host == page.Hostname()
+	}
+	return scope.Contains(host, seedURL)
 }
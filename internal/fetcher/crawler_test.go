@@ -1,6 +1,8 @@
 package fetcher
 
 import (
+	"crawler/internal/parser"
+	"crawler/internal/storage"
 	"reflect"
 	"testing"
 )
@@ -23,10 +25,17 @@ func TestFilterLinks(t *testing.T) {
 		},
 	}
 
-	c := Crawler{}
+	c := Crawler{blacklist: storage.NewHashList()}
 	for _, tt := range filterTest {
 		t.Run(tt.name, func(t *testing.T) {
-			got := c.filterLinks("https://example.com", tt.links)
+			var links []parser.Link
+			for _, l := range tt.links {
+				links = append(links, parser.Link{URL: l, Source: parser.SourceContent})
+			}
+			var got []string
+			for _, l := range c.filterLinks("https://example.com", "", links) {
+				got = append(got, l.URL)
+			}
 			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("got %v, want %v", got, tt.want)
 			}
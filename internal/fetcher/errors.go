@@ -0,0 +1,55 @@
+package fetcher
+
+import (
+	"errors"
+	"net"
+)
+
+// The failures of a fetch, ErrorType classifies the errors by them
+var (
+	ErrInvalidURL           = errors.New("invalid URL")
+	ErrStatus               = errors.New("status")
+	ErrUnacceptableMimeType = errors.New("unacceptable mime type")
+	ErrBodyTooLarge         = errors.New("body exceeds the limit")
+	ErrDecode               = errors.New("unable to decode")
+	ErrParse                = errors.New("unable to parse links")
+)
+
+// Error types of the crawl statistics
+const (
+	ErrorTypeInvalidURL = "invalid_url"
+	ErrorTypeTimeout    = "timeout"
+	ErrorTypeNetwork    = "network"
+	ErrorTypeStatus     = "http_status"
+	ErrorTypeMimeType   = "mime_type"
+	ErrorTypeBodySize   = "body_size"
+	ErrorTypeDecode     = "decode"
+	ErrorTypeParse      = "parse"
+	ErrorTypeOther      = "other"
+)
+
+// ErrorType classifies the error of ExecuteLink, it is empty for nil
+func ErrorType(err error) string {
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrInvalidURL):
+		return ErrorTypeInvalidURL
+	case errors.Is(err, ErrStatus):
+		return ErrorTypeStatus
+	case errors.Is(err, ErrUnacceptableMimeType):
+		return ErrorTypeMimeType
+	case errors.Is(err, ErrBodyTooLarge):
+		return ErrorTypeBodySize
+	case errors.Is(err, ErrDecode):
+		return ErrorTypeDecode
+	case errors.Is(err, ErrParse):
+		return ErrorTypeParse
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorTypeTimeout
+	case errors.As(err, &netErr):
+		return ErrorTypeNetwork
+	}
+	return ErrorTypeOther
+}
@@ -1,15 +1,64 @@
 package fetcher
 
 import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crawler/internal/profile"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptrace"
 	"strings"
+	"time"
 )
 
+// acceptEncoding is sent explicitly, so the transport leaves decoding to us and the wire size stays observable
+const acceptEncoding = "gzip, deflate;q=0.9, identity;q=0.5"
+
 type WebFetcher struct {
 	acceptableMimeType map[string]bool
+	client             *http.Client
+	auth               map[string]HostAuth
+	profiles           HostProfiles
+	metrics            FetchMetrics
+}
+
+// FetchMetrics observes every request, see metrics.FetchMetrics
+type FetchMetrics interface {
+	ObserveFetch(host string, status int, contentType string, duration time.Duration, bytes int64)
+}
+
+// HostProfiles resolves the per-host settings, see profile.Resolver
+type HostProfiles interface {
+	For(host string) *profile.Profile
+}
+
+// Response is the result of a single download. Body keeps the decoded bytes exactly as the server meant them,
+// before any charset transcoding.
+type Response struct {
+	Body            []byte
+	ContentType     string
+	ContentEncoding string
+	// WireSize is the number of bytes received for the body before the content decoding
+	WireSize int64
+	// Truncated tells the body of a non-200 response could not be read whole, e.g. it is over the limit
+	Truncated bool
+
+	StatusCode    int
+	Status        string
+	Proto         string
+	Header        http.Header
+	RequestHeader http.Header
+	// FinalURL is the redirect target of the 3xx responses, which are not followed, the request url otherwise
+	FinalURL string
+	// IP is the remote address of the connection, including the port
+	IP        string
+	Duration  time.Duration
+	FetchedAt time.Time
 }
 
 func NewWebFetcher(mimetypes []string) *WebFetcher {
@@ -19,9 +68,29 @@ func NewWebFetcher(mimetypes []string) *WebFetcher {
 	}
 	return &WebFetcher{
 		acceptableMimeType: acceptableMime,
+		client: &http.Client{
+			CheckRedirect: noRedirect,
+		},
+		auth: make(map[string]HostAuth),
 	}
 }
 
+// SetCookieJar makes the fetcher keep cookies between requests, e.g. the session received on the login
+func (wf *WebFetcher) SetCookieJar(jar http.CookieJar) {
+	wf.client.Jar = jar
+}
+
+// SetProfiles makes the fetcher apply the headers, credentials, acceptable mime types, body size limit and timeout
+// of the host profiles. The credentials set with SetHostAuth take precedence.
+func (wf *WebFetcher) SetProfiles(profiles HostProfiles) {
+	wf.profiles = profiles
+}
+
+// SetMetrics reports every request to m, the answered ones and the failed to connect
+func (wf *WebFetcher) SetMetrics(m FetchMetrics) {
+	wf.metrics = m
+}
+
 func contains(list map[string]bool, item string) bool {
 	for i := range list {
 		if strings.Contains(item, i) {
@@ -30,24 +99,176 @@ func contains(list map[string]bool, item string) bool {
 	}
 	return false
 }
-func (wf WebFetcher) Download(urlString string) ([]byte, error) {
-	client := &http.Client{
-		CheckRedirect: noRedirect,
+
+// acceptance resolves the acceptable mime types and the body size limit of the host from its profile,
+// the mime types fall back to the global ones and the limit to 0, which means no limit
+func acceptance(profiles HostProfiles, host string, mimetypes map[string]bool) (map[string]bool, int64) {
+	if profiles == nil {
+		return mimetypes, 0
 	}
-	response, err := client.Get(urlString)
-	if err != nil || response.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unable to reach the address, %v", err)
+	prof := profiles.For(host)
+	if len(prof.AcceptableMimeTypes) > 0 {
+		mimetypes = make(map[string]bool, len(prof.AcceptableMimeTypes))
+		for _, mime := range prof.AcceptableMimeTypes {
+			mimetypes[mime] = true
+		}
 	}
+	return mimetypes, prof.MaxBodySize
+}
 
-	if !contains(wf.acceptableMimeType, response.Header.Get("Content-Type")) {
-		return nil, fmt.Errorf("unacceptable mime type: %s", response.Header.Get("Content-Type"))
+// Download fetches the url. When the server answered but the page is not acceptable, the returned
+// Response carries the metadata without the body together with the error.
+func (wf *WebFetcher) Download(urlString string) (*Response, error) {
+	req, err := http.NewRequest(http.MethodGet, urlString, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to build the request, %w", err)
 	}
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	if wf.profiles != nil {
+		prof := wf.profiles.For(req.URL.Hostname())
+		for name, value := range prof.Headers {
+			req.Header.Set(name, value)
+		}
+		if prof.Timeout > 0 {
+			// the body is read before Download returns, so the timeout covers it too
+			ctx, cancel := context.WithTimeout(req.Context(), prof.Timeout)
+			defer cancel()
+			req = req.WithContext(ctx)
+		}
+	}
+	acceptable, maxBodySize := acceptance(wf.profiles, req.URL.Hostname(), wf.acceptableMimeType)
+	wf.authorize(req)
+
+	resp := &Response{FetchedAt: time.Now()}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			resp.IP = info.Conn.RemoteAddr().String()
+		},
+	}))
 
+	response, err := wf.client.Do(req)
+	if err != nil {
+		if wf.metrics != nil {
+			wf.metrics.ObserveFetch(req.URL.Hostname(), 0, "", time.Since(resp.FetchedAt), 0)
+		}
+		return nil, fmt.Errorf("unable to reach the address, %w", err)
+	}
 	defer response.Body.Close()
+	resp.StatusCode = response.StatusCode
+	resp.Status = response.Status
+	resp.Proto = response.Proto
+	resp.Header = response.Header
+	resp.RequestHeader = req.Header
+	resp.FinalURL = finalURL(response)
+	resp.ContentType = response.Header.Get("Content-Type")
+	defer func() {
+		resp.Duration = time.Since(resp.FetchedAt)
+		if wf.metrics != nil {
+			wf.metrics.ObserveFetch(req.URL.Hostname(), resp.StatusCode, resp.ContentType, resp.Duration, resp.WireSize)
+		}
+	}()
+
+	if response.StatusCode != http.StatusOK {
+		// the archive keeps the redirects and the error pages as they were sent
+		if err := readBody(resp, response, maxBodySize); err != nil {
+			resp.Body = nil
+			resp.Truncated = true
+		}
+		return resp, fmt.Errorf("unable to reach the address, %w %d", ErrStatus, response.StatusCode)
+	}
 
-	return io.ReadAll(response.Body)
+	if !contains(acceptable, resp.ContentType) {
+		return resp, fmt.Errorf("%w: %s", ErrUnacceptableMimeType, resp.ContentType)
+	}
+	if maxBodySize > 0 && response.ContentLength > maxBodySize {
+		return resp, fmt.Errorf("%w of %d bytes, %d announced", ErrBodyTooLarge, maxBodySize, response.ContentLength)
+	}
+	if err := readBody(resp, response, maxBodySize); err != nil {
+		return resp, err
+	}
+
+	return resp, nil
+}
+
+// readBody reads and decodes the body within the size limit, 0 means no limit
+func readBody(resp *Response, response *http.Response, maxBodySize int64) error {
+	var body io.Reader = response.Body
+	if maxBodySize > 0 {
+		// the length is not always announced, one byte over the limit tells the body is too big
+		body = io.LimitReader(response.Body, maxBodySize+1)
+	}
+	wire, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	if maxBodySize > 0 && int64(len(wire)) > maxBodySize {
+		return fmt.Errorf("%w of %d bytes", ErrBodyTooLarge, maxBodySize)
+	}
+	resp.WireSize = int64(len(wire))
+
+	resp.ContentEncoding = strings.ToLower(strings.TrimSpace(response.Header.Get("Content-Encoding")))
+	resp.Body, err = decodeBody(resp.ContentEncoding, wire, maxBodySize)
+	if errors.Is(err, ErrBodyTooLarge) {
+		return fmt.Errorf("decoded %w of %d bytes", ErrBodyTooLarge, maxBodySize)
+	}
+	if err != nil {
+		return fmt.Errorf("%w %s body, %w", ErrDecode, resp.ContentEncoding, err)
+	}
+	return nil
+}
+
+// decodeBody decompresses the body, the decoded bytes are capped by maxBodySize like the wire ones,
+// so a small compressed body cannot expand without bounds
+func decodeBody(encoding string, wire []byte, maxBodySize int64) ([]byte, error) {
+	switch encoding {
+	case "", "identity":
+		return wire, nil
+	case "gzip", "x-gzip":
+		r, err := gzip.NewReader(bytes.NewReader(wire))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return readDecoded(r, maxBodySize)
+	case "deflate":
+		// RFC 9110 says deflate is zlib-wrapped, but plenty of servers send the raw stream
+		r, err := zlib.NewReader(bytes.NewReader(wire))
+		if err != nil {
+			return readDecoded(flate.NewReader(bytes.NewReader(wire)), maxBodySize)
+		}
+		defer r.Close()
+		return readDecoded(r, maxBodySize)
+	default:
+		return nil, fmt.Errorf("unsupported content encoding")
+	}
+}
+
+// readDecoded reads the decompressed stream up to one byte over the limit, 0 means no limit
+func readDecoded(r io.Reader, maxBodySize int64) ([]byte, error) {
+	if maxBodySize > 0 {
+		r = io.LimitReader(r, maxBodySize+1)
+	}
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if maxBodySize > 0 && int64(len(body)) > maxBodySize {
+		return nil, ErrBodyTooLarge
+	}
+	return body, nil
+}
+
+// finalURL resolves the Location of a redirect against the request url
+func finalURL(response *http.Response) string {
+	if response.StatusCode >= 300 && response.StatusCode < 400 {
+		if location, err := response.Location(); err == nil {
+			return location.String()
+		}
+	}
+	return response.Request.URL.String()
 }
 
+// noRedirect stops on the first redirect, the response is kept so its metadata can be recorded
 func noRedirect(req *http.Request, via []*http.Request) error {
-	return errors.New("you shall not pass!")
+	return http.ErrUseLastResponse
 }
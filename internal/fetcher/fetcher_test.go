@@ -0,0 +1,215 @@
+package fetcher
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"crawler/internal/profile"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDownloadContentEncoding(t *testing.T) {
+	page := strings.Repeat("<a href=\"/page\">page</a>", 50)
+	compress := func(newWriter func(io.Writer) io.WriteCloser) []byte {
+		var buf bytes.Buffer
+		w := newWriter(&buf)
+		_, _ = w.Write([]byte(page))
+		_ = w.Close()
+		return buf.Bytes()
+	}
+
+	var encodingTest = []struct {
+		name     string
+		encoding string
+		wire     []byte
+	}{
+		{name: "identity", encoding: "", wire: []byte(page)},
+		{name: "gzip", encoding: "gzip", wire: compress(func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) })},
+		{name: "zlib deflate", encoding: "deflate", wire: compress(func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) })},
+		{name: "raw deflate", encoding: "deflate", wire: compress(func(w io.Writer) io.WriteCloser {
+			fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+			return fw
+		})},
+	}
+
+	for _, tt := range encodingTest {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Accept-Encoding") != acceptEncoding {
+					t.Errorf("unexpected Accept-Encoding %q", r.Header.Get("Accept-Encoding"))
+				}
+				w.Header().Set("Content-Type", "text/html")
+				if tt.encoding != "" {
+					w.Header().Set("Content-Encoding", tt.encoding)
+				}
+				_, _ = w.Write(tt.wire)
+			}))
+			defer srv.Close()
+
+			resp, err := NewWebFetcher([]string{"text/html"}).Download(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(resp.Body) != page {
+				t.Errorf("got body %q", resp.Body)
+			}
+			if resp.WireSize != int64(len(tt.wire)) {
+				t.Errorf("got wire size %d, want %d", resp.WireSize, len(tt.wire))
+			}
+		})
+	}
+}
+
+func TestDownloadDecodedSizeLimit(t *testing.T) {
+	// 10 MiB of zeros compress to a few KiB, far below the limit on the wire
+	var wire bytes.Buffer
+	gw := gzip.NewWriter(&wire)
+	_, _ = gw.Write(make([]byte, 10<<20))
+	_ = gw.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("Content-Encoding", "gzip")
+		_, _ = w.Write(wire.Bytes())
+	}))
+	defer srv.Close()
+
+	f := NewWebFetcher([]string{"text/html"})
+	f.SetProfiles(mustResolver(t, profile.Profile{MaxBodySize: 1 << 20}))
+	resp, err := f.Download(srv.URL)
+	if !errors.Is(err, ErrBodyTooLarge) {
+		t.Fatalf("got %v, want ErrBodyTooLarge for %d bytes on the wire", err, wire.Len())
+	}
+	if resp.Body != nil {
+		t.Errorf("got %d bytes of the body", len(resp.Body))
+	}
+}
+
+func TestDownloadProfile(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") != "polite-bot" {
+			t.Errorf("unexpected User-Agent %q", r.Header.Get("User-Agent"))
+		}
+		switch r.URL.Path {
+		case "/feed":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte("{}"))
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			// no Content-Length, the limit applies while reading
+			w.(http.Flusher).Flush()
+			_, _ = w.Write([]byte(strings.Repeat("x", 100)))
+		}
+	}))
+	defer srv.Close()
+
+	headers := map[string]string{"User-Agent": "polite-bot"}
+	f := NewWebFetcher([]string{"text/html"})
+	f.SetProfiles(mustResolver(t, profile.Profile{Headers: headers}, profile.Rule{Match: "127.0.0.1", Profile: profile.Profile{
+		AcceptableMimeTypes: []string{"application/json"},
+	}}))
+
+	if resp, err := f.Download(srv.URL + "/feed"); err != nil || string(resp.Body) != "{}" {
+		t.Errorf("the mime types of the profile do not apply: %v", err)
+	}
+	if _, err := f.Download(srv.URL + "/page"); err == nil || !strings.Contains(err.Error(), "mime type") {
+		t.Errorf("got %v, the mime types of the profile replace the global ones", err)
+	}
+
+	f.SetProfiles(mustResolver(t, profile.Profile{Headers: headers, MaxBodySize: 10}))
+	if _, err := f.Download(srv.URL + "/page"); err == nil || !strings.Contains(err.Error(), "limit") {
+		t.Errorf("got %v, want the body size error", err)
+	}
+}
+
+func TestDownloadRefusedResponses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/old":
+			http.Redirect(w, r, "/new?from=old", http.StatusMovedPermanently)
+		case "/away":
+			http.Redirect(w, r, "https://example.com/", http.StatusFound)
+		case "/gone":
+			http.Error(w, "gone for good", http.StatusGone)
+		default:
+			w.Header().Set("Content-Type", "text/html")
+		}
+	}))
+	defer srv.Close()
+
+	var redirectTest = []struct {
+		path      string
+		wantFinal string
+		wantBody  string
+	}{
+		{path: "/old", wantFinal: srv.URL + "/new?from=old", wantBody: "<a href=\"/new?from=old\">Moved Permanently</a>.\n\n"},
+		{path: "/away", wantFinal: "https://example.com/", wantBody: "<a href=\"https://example.com/\">Found</a>.\n\n"},
+		{path: "/gone", wantFinal: srv.URL + "/gone", wantBody: "gone for good\n"},
+		{path: "/new", wantFinal: srv.URL + "/new"},
+	}
+
+	f := NewWebFetcher([]string{"text/html"})
+	for _, tt := range redirectTest {
+		t.Run(tt.path, func(t *testing.T) {
+			resp, _ := f.Download(srv.URL + tt.path)
+			if resp == nil || resp.FinalURL != tt.wantFinal {
+				t.Errorf("got %+v, want the final url %s", resp, tt.wantFinal)
+			}
+			// the bodies of the refused responses are kept for the archive
+			if resp != nil && string(resp.Body) != tt.wantBody {
+				t.Errorf("got body %q, want %q", resp.Body, tt.wantBody)
+			}
+		})
+	}
+
+	f.SetProfiles(mustResolver(t, profile.Profile{MaxBodySize: 4}))
+	if resp, _ := f.Download(srv.URL + "/gone"); resp == nil || !resp.Truncated || resp.Body != nil {
+		t.Errorf("got %+v, want the body over the limit dropped", resp)
+	}
+}
+
+func TestWebFetcherTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/stalled-body" {
+			w.Write([]byte("<p>"))
+			w.(http.Flusher).Flush()
+		}
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	f := NewWebFetcher([]string{"text/html"})
+	f.SetProfiles(mustResolver(t, profile.Profile{Timeout: time.Hour}, profile.Rule{Match: "127.0.0.1", Profile: profile.Profile{
+		Timeout: 100 * time.Millisecond,
+	}}))
+	for _, path := range []string{"/stalled", "/stalled-body"} {
+		start := time.Now()
+		_, err := f.Download(srv.URL + path)
+		if err == nil || ErrorType(err) != ErrorTypeTimeout {
+			t.Errorf("%s: got %v (%s), want a timeout", path, err, ErrorType(err))
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Errorf("%s: the timeout of the host profile is not applied", path)
+		}
+	}
+}
+
+func mustResolver(t *testing.T, defaults profile.Profile, rules ...profile.Rule) *profile.Resolver {
+	r, err := profile.NewResolver(defaults, rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
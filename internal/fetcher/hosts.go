@@ -0,0 +1,59 @@
+package fetcher
+
+import (
+	"sync"
+	"time"
+)
+
+// hostSlots limits the concurrent requests to every host and keeps the delay between their starts
+type hostSlots struct {
+	mu    sync.Mutex
+	hosts map[string]*hostSlot
+}
+
+type hostSlot struct {
+	active int
+	// next is the earliest start of the next request
+	next time.Time
+}
+
+func newHostSlots() *hostSlots {
+	return &hostSlots{hosts: make(map[string]*hostSlot)}
+}
+
+// tryAcquire takes the slot or tells how long to wait before the next try
+func (hs *hostSlots) tryAcquire(host string, limit int, delay time.Duration) time.Duration {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	slot, ok := hs.hosts[host]
+	if !ok {
+		slot = &hostSlot{}
+		hs.hosts[host] = slot
+	}
+	now := time.Now()
+	if wait := slot.next.Sub(now); wait > 0 {
+		return wait
+	}
+	if limit > 0 && slot.active >= limit {
+		// a slot is freed by release, polling keeps it simple
+		return 50 * time.Millisecond
+	}
+	slot.active++
+	slot.next = now.Add(delay)
+	return 0
+}
+
+func (hs *hostSlots) release(host string) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	if slot, ok := hs.hosts[host]; ok {
+		slot.active--
+		if slot.active == 0 && time.Now().After(slot.next) {
+			delete(hs.hosts, host)
+		}
+	}
+}
+
+// maxDeferredTasks is the number of the tasks deferred in the queue from which on it is pulled slower
+const maxDeferredTasks = 1000
@@ -0,0 +1,48 @@
+package fetcher
+
+import (
+	"crawler/internal/seed"
+	"testing"
+	"time"
+)
+
+func TestHostSlots(t *testing.T) {
+	hs := newHostSlots()
+	if wait := hs.tryAcquire("a.com", 1, 0); wait != 0 {
+		t.Fatalf("the first request waits %s", wait)
+	}
+	if wait := hs.tryAcquire("a.com", 1, 0); wait == 0 {
+		t.Error("the limit of the host is exceeded")
+	}
+	if wait := hs.tryAcquire("b.com", 1, 0); wait != 0 {
+		t.Error("the limit of another host applies")
+	}
+	hs.release("a.com")
+	if wait := hs.tryAcquire("a.com", 1, time.Hour); wait != 0 {
+		t.Error("the released slot is not free")
+	}
+	hs.release("a.com")
+	if wait := hs.tryAcquire("a.com", 0, 0); wait < 59*time.Minute {
+		t.Errorf("the delay is not kept, waits %s", wait)
+	}
+}
+
+func TestBusyHostDefersTask(t *testing.T) {
+	c, queue, _ := newControlledCrawler(t)
+	c.scope.Store(seed.ScopesOf([]seed.Seed{{URL: "http://a.com/"}}))
+	if wait := c.slots.tryAcquire("a.com", 1, time.Hour); wait != 0 {
+		t.Fatal("the slot is not free")
+	}
+
+	// the task waits in the queue, not in the memory of the crawler
+	fetched, err := c.runTask(&FetchTask{Link: "http://a.com/1", Depth: 1, Seed: "http://a.com/"})
+	if fetched || err != nil {
+		t.Fatalf("got fetched %v, %v for a busy host", fetched, err)
+	}
+	if queue.Size() != 1 || queue.Deferred() != 1 {
+		t.Fatalf("got %d queued, %d deferred", queue.Size(), queue.Deferred())
+	}
+	if item, _, _ := queue.Get("http://a.com/1"); item.Depth != 1 || item.Seed != "http://a.com/" {
+		t.Errorf("got %+v, want the deferred task", item)
+	}
+}
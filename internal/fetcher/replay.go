@@ -0,0 +1,83 @@
+package fetcher
+
+import (
+	"bufio"
+	"bytes"
+	"crawler/internal/warc"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// ReplayFetcher serves the responses recorded in WARC files instead of the live web.
+// Pages missing in the archive are reported as download errors, the same way as unreachable ones.
+type ReplayFetcher struct {
+	acceptableMimeType map[string]bool
+	index              *warc.Index
+	profiles           HostProfiles
+}
+
+func NewReplayFetcher(mimetypes []string, warcFiles ...string) (*ReplayFetcher, error) {
+	index, err := warc.NewIndex(warcFiles...)
+	if err != nil {
+		return nil, err
+	}
+	acceptableMime := make(map[string]bool)
+	for _, mime := range mimetypes {
+		acceptableMime[mime] = true
+	}
+	return &ReplayFetcher{
+		acceptableMimeType: acceptableMime,
+		index:              index,
+	}, nil
+}
+
+// SetProfiles makes the replay accept the mime types and body sizes of the host profiles, like the live crawl
+func (rf *ReplayFetcher) SetProfiles(profiles HostProfiles) {
+	rf.profiles = profiles
+}
+
+func (rf *ReplayFetcher) Download(urlString string) (*Response, error) {
+	block, entry, err := rf.index.Response(urlString)
+	if err != nil {
+		return nil, fmt.Errorf("unable to reach the address, %w", err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, urlString, nil)
+	acceptable, maxBodySize := acceptance(rf.profiles, req.URL.Hostname(), rf.acceptableMimeType)
+	response, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(block)), req)
+	if err != nil {
+		return nil, fmt.Errorf("broken archived response, %w", err)
+	}
+	defer response.Body.Close()
+
+	fetchedAt, _ := time.Parse("20060102150405", entry.Timestamp)
+	resp := &Response{
+		StatusCode:  response.StatusCode,
+		Status:      response.Status,
+		Proto:       response.Proto,
+		Header:      response.Header,
+		FinalURL:    finalURL(response),
+		ContentType: response.Header.Get("Content-Type"),
+		FetchedAt:   fetchedAt,
+	}
+	if response.StatusCode != http.StatusOK {
+		return resp, fmt.Errorf("unable to reach the address, %w %d", ErrStatus, response.StatusCode)
+	}
+	if !contains(acceptable, resp.ContentType) {
+		return resp, fmt.Errorf("%w: %s", ErrUnacceptableMimeType, resp.ContentType)
+	}
+
+	// the archived body is decoded already, its length is the one the live crawl limited
+	resp.Body, err = io.ReadAll(response.Body)
+	if err != nil {
+		return resp, err
+	}
+	if maxBodySize > 0 && int64(len(resp.Body)) > maxBodySize {
+		resp.Body = nil
+		return resp, fmt.Errorf("%w of %d bytes", ErrBodyTooLarge, maxBodySize)
+	}
+	resp.WireSize = int64(len(resp.Body))
+
+	return resp, nil
+}
@@ -0,0 +1,76 @@
+package fetcher
+
+import (
+	"crawler/internal/profile"
+	"crawler/internal/warc"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReplayFetcher(t *testing.T) {
+	dir := t.TempDir()
+	w, err := warc.NewWriter(dir, "test", 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ex := range []*warc.Exchange{
+		{URL: "http://example.com/", StatusCode: http.StatusOK, Header: http.Header{"Content-Type": []string{"text/html"}}, Body: []byte(`<a href="/next">next</a>`), Date: time.Now()},
+		{URL: "http://example.com/image.png", StatusCode: http.StatusOK, Header: http.Header{"Content-Type": []string{"image/png"}}, Body: []byte("png"), Date: time.Now()},
+		{URL: "http://example.com/old", StatusCode: http.StatusMovedPermanently, Header: http.Header{"Location": []string{"/"}}, Date: time.Now()},
+	} {
+		if err := w.WriteExchange(ex); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = w.Close()
+	files, _ := filepath.Glob(filepath.Join(dir, "*.warc.gz"))
+
+	rf, err := NewReplayFetcher([]string{"text/html"}, files...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := rf.Download("http://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp.Body) != `<a href="/next">next</a>` || resp.ContentType != "text/html" {
+		t.Errorf("got %+v", resp)
+	}
+
+	var failTest = []struct {
+		url        string
+		wantStatus int
+	}{
+		{url: "http://example.com/image.png", wantStatus: http.StatusOK},
+		{url: "http://example.com/old", wantStatus: http.StatusMovedPermanently},
+		{url: "http://example.com/not-recorded"},
+	}
+	for _, tt := range failTest {
+		resp, err := rf.Download(tt.url)
+		if err == nil {
+			t.Errorf("%s should fail", tt.url)
+		}
+		if tt.wantStatus != 0 && (resp == nil || resp.StatusCode != tt.wantStatus) {
+			t.Errorf("%s: got %+v, want status %d", tt.url, resp, tt.wantStatus)
+		}
+	}
+
+	// the profiles apply as in the live crawl
+	rf.SetProfiles(mustResolver(t, profile.Profile{MaxBodySize: 10}, profile.Rule{Match: "example.com", Profile: profile.Profile{
+		AcceptableMimeTypes: []string{"image/png"},
+	}}))
+	if _, err := rf.Download("http://example.com/image.png"); err != nil {
+		t.Errorf("the mime type of the profile is refused, %v", err)
+	}
+	if _, err := rf.Download("http://example.com/"); !errors.Is(err, ErrUnacceptableMimeType) {
+		t.Errorf("got %v, want the page refused by the profile", err)
+	}
+	rf.SetProfiles(mustResolver(t, profile.Profile{AcceptableMimeTypes: []string{"text/html"}, MaxBodySize: 10}))
+	if _, err := rf.Download("http://example.com/"); !errors.Is(err, ErrBodyTooLarge) {
+		t.Errorf("got %v, want the body over the limit refused", err)
+	}
+}
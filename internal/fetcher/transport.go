@@ -0,0 +1,99 @@
+package fetcher
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"golang.org/x/net/http/httpproxy"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// TransportConfig describes how the fetcher reaches the sites: through which proxy and with which TLS settings
+type TransportConfig struct {
+	// ProxyURL is either http://, https:// or socks5:// proxy, empty keeps the HTTP_PROXY environment settings
+	ProxyURL string
+	// NoProxy lists hosts, domains (matching subdomains too), IPs and CIDRs reached directly
+	NoProxy []string
+
+	// CAFile is a PEM bundle trusted in addition to the system roots
+	CAFile string
+	// CertFile and KeyFile are the client certificate for mTLS
+	CertFile string
+	KeyFile  string
+	// MinTLSVersion is one of 1.0, 1.1, 1.2, 1.3
+	MinTLSVersion      string
+	InsecureSkipVerify bool
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+func NewTransport(tc TransportConfig) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if tc.ProxyURL != "" {
+		u, err := url.Parse(tc.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy url: %w", err)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+		}
+		proxyFunc := (&httpproxy.Config{
+			HTTPProxy:  tc.ProxyURL,
+			HTTPSProxy: tc.ProxyURL,
+			NoProxy:    strings.Join(tc.NoProxy, ","),
+		}).ProxyFunc()
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			return proxyFunc(req.URL)
+		}
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: tc.InsecureSkipVerify,
+	}
+	if tc.MinTLSVersion != "" {
+		version, ok := tlsVersions[tc.MinTLSVersion]
+		if !ok {
+			return nil, fmt.Errorf("unknown tls version %q", tc.MinTLSVersion)
+		}
+		tlsConfig.MinVersion = version
+	}
+	if tc.CAFile != "" {
+		pem, err := os.ReadFile(tc.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read ca bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", tc.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if tc.CertFile != "" || tc.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(tc.CertFile, tc.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	return transport, nil
+}
+
+func (wf *WebFetcher) SetTransport(transport http.RoundTripper) {
+	wf.client.Transport = transport
+}
@@ -0,0 +1,149 @@
+package fetcher
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func htmlHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html")
+}
+
+func writePEM(t *testing.T, name, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// clientCertificate issues a self-signed client certificate and returns the pool trusting it
+func clientCertificate(t *testing.T) (certFile, keyFile string, pool *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "crawler"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	pool = x509.NewCertPool()
+	pool.AddCert(cert)
+
+	return writePEM(t, "client.pem", "CERTIFICATE", der), writePEM(t, "client.key", "PRIVATE KEY", keyDer), pool
+}
+
+func TestTransportTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(htmlHandler))
+	defer srv.Close()
+	caFile := writePEM(t, "ca.pem", "CERTIFICATE", srv.Certificate().Raw)
+
+	legacySrv := httptest.NewUnstartedServer(http.HandlerFunc(htmlHandler))
+	legacySrv.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	legacySrv.StartTLS()
+	defer legacySrv.Close()
+
+	certFile, keyFile, clientPool := clientCertificate(t)
+	mtlsSrv := httptest.NewUnstartedServer(http.HandlerFunc(htmlHandler))
+	mtlsSrv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientPool}
+	mtlsSrv.StartTLS()
+	defer mtlsSrv.Close()
+
+	var tlsTest = []struct {
+		name    string
+		config  TransportConfig
+		url     string
+		wantErr bool
+	}{
+		{name: "unknown ca", config: TransportConfig{}, url: srv.URL, wantErr: true},
+		{name: "custom ca", config: TransportConfig{CAFile: caFile}, url: srv.URL},
+		{name: "insecure skip verify", config: TransportConfig{InsecureSkipVerify: true}, url: srv.URL},
+		{name: "min version rejects", config: TransportConfig{InsecureSkipVerify: true, MinTLSVersion: "1.3"}, url: legacySrv.URL, wantErr: true},
+		{name: "min version accepts", config: TransportConfig{InsecureSkipVerify: true, MinTLSVersion: "1.2"}, url: legacySrv.URL},
+		{name: "mtls without certificate", config: TransportConfig{InsecureSkipVerify: true}, url: mtlsSrv.URL, wantErr: true},
+		{name: "mtls", config: TransportConfig{InsecureSkipVerify: true, CertFile: certFile, KeyFile: keyFile}, url: mtlsSrv.URL},
+	}
+
+	for _, tt := range tlsTest {
+		t.Run(tt.name, func(t *testing.T) {
+			transport, err := NewTransport(tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wf := NewWebFetcher([]string{"text/html"})
+			wf.SetTransport(transport)
+			_, err = wf.Download(tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTransportProxy(t *testing.T) {
+	var proxied atomic.Int32
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// a forward proxy receives the absolute url of the target
+		if r.URL.Host == "internal.example" {
+			proxied.Add(1)
+			htmlHandler(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer proxy.Close()
+
+	transport, err := NewTransport(TransportConfig{ProxyURL: proxy.URL, NoProxy: []string{"bypassed.example"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wf := NewWebFetcher([]string{"text/html"})
+	wf.SetTransport(transport)
+
+	if _, err := wf.Download("http://internal.example/page"); err != nil {
+		t.Fatal(err)
+	}
+	if proxied.Load() != 1 {
+		t.Errorf("request did not go through the proxy")
+	}
+	if _, err := wf.Download("http://sub.bypassed.example/page"); err == nil {
+		t.Errorf("bypassed host should be reached directly and fail to resolve")
+	}
+	if proxied.Load() != 1 {
+		t.Errorf("bypassed request went through the proxy")
+	}
+
+	if _, err := NewTransport(TransportConfig{ProxyURL: "ftp://proxy"}); err == nil {
+		t.Errorf("unsupported proxy scheme should be rejected")
+	}
+}
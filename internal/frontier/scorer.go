@@ -0,0 +1,135 @@
+package frontier
+
+import (
+	"crawler/internal/storage"
+	"fmt"
+	"math"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Boost raises (or lowers with a negative value) the score of the urls matching the pattern
+type Boost struct {
+	Pattern string
+	Value   float64
+}
+
+// Config holds the weights of the score parts, the nil ones are replaced with DefaultConfig ones.
+// A zero weight turns its part off.
+type Config struct {
+	// DepthWeight is subtracted per link hop from the seed
+	DepthWeight *float64
+	// HostPenalty is multiplied by ln(1 + urls of the host waiting), so a single big host
+	// can't push the others out
+	HostPenalty   *float64
+	SourceWeights map[string]float64
+	// MimeWeights are keyed by the class guessed from the extension: html, document, style, script, image, media, archive
+	MimeWeights map[string]float64
+	Boosts      []Boost
+}
+
+func DefaultConfig() Config {
+	return Config{
+		DepthWeight: Weight(1),
+		HostPenalty: Weight(0.5),
+		SourceWeights: map[string]float64{
+			"seed":    10,
+			"sitemap": 3,
+			"nav":     2,
+			"content": 1,
+			"asset":   0,
+		},
+		MimeWeights: map[string]float64{
+			"html":     1,
+			"document": 0.5,
+			"style":    0.5,
+			"script":   0.5,
+			"image":    -1,
+			"media":    -2,
+			"archive":  -2,
+		},
+	}
+}
+
+// Weight returns a pointer to the weight, for the optional fields of Config
+func Weight(v float64) *float64 {
+	return &v
+}
+
+// extensionClasses guess the mime class from the extension, urls without an extension are html
+var extensionClasses = map[string]string{
+	".html": "html", ".htm": "html", ".php": "html", ".asp": "html", ".aspx": "html", ".jsp": "html",
+	".xml": "document", ".json": "document", ".pdf": "document", ".txt": "document",
+	".css": "style",
+	".js":  "script", ".mjs": "script",
+	".png": "image", ".jpg": "image", ".jpeg": "image", ".gif": "image", ".svg": "image", ".webp": "image", ".ico": "image",
+	".mp3": "media", ".mp4": "media", ".webm": "media", ".avi": "media", ".mov": "media", ".woff": "media", ".woff2": "media", ".ttf": "media",
+	".zip": "archive", ".gz": "archive", ".tar": "archive", ".rar": "archive", ".7z": "archive", ".exe": "archive", ".iso": "archive",
+}
+
+type boost struct {
+	pattern *regexp.Regexp
+	value   float64
+}
+
+type Scorer struct {
+	cfg         Config
+	depthWeight float64
+	hostPenalty float64
+	boosts      []boost
+}
+
+func NewScorer(cfg Config) (*Scorer, error) {
+	defaults := DefaultConfig()
+	if cfg.DepthWeight == nil {
+		cfg.DepthWeight = defaults.DepthWeight
+	}
+	if cfg.HostPenalty == nil {
+		cfg.HostPenalty = defaults.HostPenalty
+	}
+	if cfg.SourceWeights == nil {
+		cfg.SourceWeights = defaults.SourceWeights
+	}
+	if cfg.MimeWeights == nil {
+		cfg.MimeWeights = defaults.MimeWeights
+	}
+
+	s := &Scorer{cfg: cfg, depthWeight: *cfg.DepthWeight, hostPenalty: *cfg.HostPenalty}
+	for _, b := range cfg.Boosts {
+		pattern, err := regexp.Compile(b.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid boost pattern %q: %w", b.Pattern, err)
+		}
+		s.boosts = append(s.boosts, boost{pattern: pattern, value: b.Value})
+	}
+	return s, nil
+}
+
+func (s *Scorer) Score(item storage.QueueItem, hostCount int) float64 {
+	score := s.cfg.SourceWeights[item.Source]
+	score -= s.depthWeight * float64(item.Depth)
+	score -= s.hostPenalty * math.Log1p(float64(hostCount))
+	score += s.cfg.MimeWeights[MimeClass(item.URL)]
+	score += item.Priority
+	for _, b := range s.boosts {
+		if b.pattern.MatchString(item.URL) {
+			score += b.value
+		}
+	}
+	return score
+}
+
+// MimeClass guesses the kind of the resource from the extension of the url path
+func MimeClass(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	ext := strings.ToLower(path.Ext(u.Path))
+	if ext == "" {
+		return "html"
+	}
+	return extensionClasses[ext]
+}
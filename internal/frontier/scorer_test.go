@@ -0,0 +1,74 @@
+package frontier
+
+import (
+	"crawler/internal/storage"
+	"testing"
+)
+
+func TestScorer(t *testing.T) {
+	s, err := NewScorer(Config{Boosts: []Boost{{Pattern: `/docs/`, Value: 5}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var orderTest = []struct {
+		name          string
+		better, worse storage.QueueItem
+		betterHosts   int
+		worseHosts    int
+	}{
+		{
+			name:   "sitemap before content",
+			better: storage.QueueItem{URL: "http://a.com/x", Source: "sitemap", Depth: 1},
+			worse:  storage.QueueItem{URL: "http://a.com/y", Source: "content", Depth: 1},
+		},
+		{
+			name:   "shallow before deep",
+			better: storage.QueueItem{URL: "http://a.com/x", Source: "content", Depth: 1},
+			worse:  storage.QueueItem{URL: "http://a.com/y", Source: "content", Depth: 3},
+		},
+		{
+			name:   "pages before images",
+			better: storage.QueueItem{URL: "http://a.com/page", Source: "content", Depth: 1},
+			worse:  storage.QueueItem{URL: "http://a.com/img/1.png", Source: "content", Depth: 1},
+		},
+		{
+			name:       "quiet host before crowded one",
+			better:     storage.QueueItem{URL: "http://b.com/x", Source: "content", Depth: 1},
+			worse:      storage.QueueItem{URL: "http://a.com/x", Source: "content", Depth: 1},
+			worseHosts: 1000,
+		},
+		{
+			name:   "boosted pattern",
+			better: storage.QueueItem{URL: "http://a.com/docs/x", Source: "content", Depth: 3},
+			worse:  storage.QueueItem{URL: "http://a.com/x", Source: "content", Depth: 1},
+		},
+		{
+			name:   "priority set by hand",
+			better: storage.QueueItem{URL: "http://a.com/img/1.png", Source: "asset", Depth: 5, Priority: 20},
+			worse:  storage.QueueItem{URL: "http://a.com/", Source: "seed"},
+		},
+	}
+	for _, tt := range orderTest {
+		t.Run(tt.name, func(t *testing.T) {
+			better, worse := s.Score(tt.better, tt.betterHosts), s.Score(tt.worse, tt.worseHosts)
+			if better <= worse {
+				t.Errorf("got %v <= %v", better, worse)
+			}
+		})
+	}
+
+	flat, err := NewScorer(Config{DepthWeight: Weight(0), HostPenalty: Weight(0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	shallow := storage.QueueItem{URL: "http://a.com/x", Source: "content", Depth: 1}
+	deep := storage.QueueItem{URL: "http://a.com/y", Source: "content", Depth: 5}
+	if flat.Score(shallow, 0) != flat.Score(deep, 1000) {
+		t.Errorf("the zero weights are not turned off")
+	}
+
+	if _, err := NewScorer(Config{Boosts: []Boost{{Pattern: `(`}}}); err == nil {
+		t.Error("invalid boost pattern accepted")
+	}
+}
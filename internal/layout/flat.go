@@ -0,0 +1,40 @@
+package layout
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"path"
+)
+
+// ContentHash names the files by the SHA-256 of the body, identical bodies are stored once:
+// example.com/ab/abcdef....html
+type ContentHash struct{}
+
+func (ContentHash) Path(u *url.URL, body []byte) string {
+	sum := sha256.Sum256(body)
+	digest := hex.EncodeToString(sum[:])
+	return safeSegment(hostDir(u)) + "/" + digest[:2] + "/" + digest + extension(u)
+}
+
+func (ContentHash) Indexed() bool {
+	return true
+}
+
+// Flat puts all the files of a host into a single directory, the name is a hash of the url
+// followed by the original file name for readability: example.com/1a2b3c4d5e6f7a8b-page.html
+type Flat struct{}
+
+func (Flat) Path(u *url.URL, body []byte) string {
+	sum := sha1.Sum([]byte(u.String()))
+	name := path.Base(u.Path)
+	if name == "/" || name == "." {
+		name = "index"
+	}
+	return safeSegment(hostDir(u)) + "/" + safeSegment(hex.EncodeToString(sum[:])[:16]+"-"+name)
+}
+
+func (Flat) Indexed() bool {
+	return true
+}
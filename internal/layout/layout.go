@@ -0,0 +1,108 @@
+package layout
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Strategy maps a fetched url to a slash-separated file path relative to the downloads root.
+// The Saver makes sure the path stays inside the root whatever the strategy returns.
+type Strategy interface {
+	Path(u *url.URL, body []byte) string
+	// Indexed strategies lose the url in the file name, the Saver keeps an index file for them
+	Indexed() bool
+}
+
+// NewStrategy returns the strategy by its config name, an empty name means mirrored
+func NewStrategy(name string) (Strategy, error) {
+	switch name {
+	case "", "mirrored":
+		return Mirrored{}, nil
+	case "content_hash":
+		return ContentHash{}, nil
+	case "flat":
+		return Flat{}, nil
+	default:
+		return nil, fmt.Errorf("unknown downloads layout %q", name)
+	}
+}
+
+// maxSegmentLength keeps every name well below the 255 bytes most file systems allow
+const maxSegmentLength = 200
+
+// reservedNames can't be used as file names on Windows, whatever the extension is
+var reservedNames = map[string]bool{
+	"con": true, "prn": true, "aux": true, "nul": true,
+	"com1": true, "com2": true, "com3": true, "com4": true, "com5": true, "com6": true, "com7": true, "com8": true, "com9": true,
+	"lpt1": true, "lpt2": true, "lpt3": true, "lpt4": true, "lpt5": true, "lpt6": true, "lpt7": true, "lpt8": true, "lpt9": true,
+}
+
+// safeSegment makes a single path segment valid on Linux, macOS and Windows: illegal and control
+// characters are replaced, dot segments and reserved names are escaped, long names are shortened
+// with a hash of the full name, so they stay unique
+func safeSegment(segment string) string {
+	var b strings.Builder
+	for _, r := range segment {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(`<>:"/\|?*`, r) {
+			b.WriteRune('_')
+			continue
+		}
+		b.WriteRune(r)
+	}
+	safe := b.String()
+
+	switch {
+	case safe == "" || safe == "." || safe == "..":
+		safe = "_" + safe
+	case isReservedName(safe):
+		safe = "_" + safe
+	}
+	if strings.HasSuffix(safe, ".") || strings.HasSuffix(safe, " ") {
+		safe += "_"
+	}
+
+	if len(safe) > maxSegmentLength {
+		ext := path.Ext(safe)
+		if len(ext) > 16 {
+			ext = ""
+		}
+		sum := sha1.Sum([]byte(segment))
+		cut := maxSegmentLength - len(ext) - 13
+		// do not cut a multibyte character in half
+		for cut > 0 && !isRuneStart(safe[cut]) {
+			cut--
+		}
+		safe = safe[:cut] + "~" + hex.EncodeToString(sum[:])[:12] + ext
+	}
+	return safe
+}
+
+// isReservedName tells the name is a Windows device, with an extension or without
+func isReservedName(name string) bool {
+	return reservedNames[strings.ToLower(strings.SplitN(name, ".", 2)[0])]
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xc0 != 0x80
+}
+
+// hostDir is the top level directory of the site, the port is kept as different ports are different sites
+func hostDir(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return u.Hostname() + "_" + port
+	}
+	return u.Hostname()
+}
+
+// extension guesses the file extension from the url path
+func extension(u *url.URL) string {
+	ext := path.Ext(u.Path)
+	if ext == "" || len(ext) > 10 || strings.ContainsAny(ext, `<>:"/\|?* `) {
+		return ""
+	}
+	return strings.ToLower(ext)
+}
@@ -0,0 +1,152 @@
+package layout
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+// dynamicExtensions are served as HTML, the saved copy gets .html appended so a browser opens it
+var dynamicExtensions = map[string]bool{
+	".php":  true,
+	".asp":  true,
+	".aspx": true,
+	".jsp":  true,
+	".cgi":  true,
+	".pl":   true,
+}
+
+// maxQueryLength is the longest query kept readable in a file name, longer ones are hashed
+const maxQueryLength = 64
+
+// Mirrored keeps the site structure: http://example.com/blog/?page=2 is saved as
+// example.com/blog/index@page=2.html. The path does not depend on the body, so links can be rewritten to it.
+type Mirrored struct{}
+
+func (Mirrored) Path(u *url.URL, body []byte) string {
+	return MirroredPath(u)
+}
+
+func (Mirrored) Indexed() bool {
+	return false
+}
+
+// MirroredPath maps the url to a slash-separated path relative to the downloads root. Different urls
+// never share a path and a file never takes the name of a directory, so the links between the files
+// are known without looking at the other files:
+//   - "%", "@" and the characters the file systems refuse are percent-encoded
+//   - a file name is followed by "@" and the query when there is one, and by .html when the path
+//     has no extension or a dynamic one: /about is about@.html, /main.css?v=3 is main.css@v=3.css
+//   - the directory index is index.html, the files named index or index.html and the names of the
+//     Windows devices get an "@" prefix
+//   - a file name always has an extension, a directory name with a dot gets an "@" suffix
+func MirroredPath(u *url.URL) string {
+	// the segments are decoded one by one, an encoded slash stays a part of its segment
+	segments := strings.Split(strings.TrimPrefix(u.EscapedPath(), "/"), "/")
+	var clean []string
+	for i, segment := range segments {
+		if unescaped, err := url.PathUnescape(segment); err == nil {
+			segment = unescaped
+		}
+		switch segment {
+		case ".":
+		case "..":
+			if len(clean) > 0 {
+				clean = clean[:len(clean)-1]
+			}
+		default:
+			clean = append(clean, segment)
+			continue
+		}
+		if i == len(segments)-1 {
+			// /blog/.. is the directory itself
+			clean = append(clean, "")
+		}
+	}
+
+	safe := []string{safeSegment(hostDir(u))}
+	for _, dir := range clean[:len(clean)-1] {
+		safe = append(safe, mirroredDirName(dir))
+	}
+	return strings.Join(append(safe, mirroredFileName(clean[len(clean)-1], u.RawQuery)), "/")
+}
+
+func mirroredDirName(dir string) string {
+	if dir == "" {
+		return "@"
+	}
+	name := escapeName(dir)
+	if strings.Contains(dir, ".") {
+		name += "@"
+	}
+	if isReservedName(name) {
+		name = "@" + name
+	}
+	return safeSegment(name)
+}
+
+func mirroredFileName(file, rawQuery string) string {
+	ext := path.Ext(file)
+	asHTML := ext == "" || dynamicExtensions[strings.ToLower(ext)]
+	if file == "" && rawQuery == "" {
+		return "index.html"
+	}
+
+	name := escapeName(file)
+	if file == "" {
+		name = "index"
+	}
+	if rawQuery != "" || asHTML {
+		name += "@" + queryName(rawQuery)
+		if asHTML {
+			name += ".html"
+		} else {
+			name += escapeName(ext)
+		}
+	}
+	if file == "index" || file == "index.html" || isReservedName(name) {
+		name = "@" + name
+	}
+	return safeSegment(name)
+}
+
+// escapeName percent-encodes the bytes which are not allowed or not safe in a file name, the encoding
+// can be reversed, so different names stay different
+func escapeName(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); {
+		r, size := utf8.DecodeRuneInString(name[i:])
+		last := i+size == len(name)
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(`<>:"/\|?*%@`, r) || (r == utf8.RuneError && size == 1) ||
+			(last && (r == '.' || r == ' ')) {
+			fmt.Fprintf(&b, "%%%02X", name[i])
+		} else {
+			b.WriteString(name[i : i+size])
+		}
+		i += size
+	}
+	return b.String()
+}
+
+// queryName keeps short queries readable and hashes the rest
+func queryName(rawQuery string) string {
+	if len(rawQuery) <= maxQueryLength {
+		safe := true
+		for _, r := range rawQuery {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("=&-_.", r)) {
+				safe = false
+				break
+			}
+		}
+		if safe {
+			return rawQuery
+		}
+	}
+	// a readable query never has a "~", the hashed ones do not look like one
+	sum := sha1.Sum([]byte(rawQuery))
+	return "~" + hex.EncodeToString(sum[:])[:12]
+}
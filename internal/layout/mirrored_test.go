@@ -0,0 +1,85 @@
+package layout
+
+import (
+	"net/url"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestMirroredPath(t *testing.T) {
+	var pathTest = []struct {
+		url  string
+		want string
+	}{
+		{url: "http://example.com", want: "example.com/index.html"},
+		{url: "http://example.com/", want: "example.com/index.html"},
+		{url: "http://example.com/?page=2", want: "example.com/index@page=2.html"},
+		{url: "http://example.com/index.html", want: "example.com/@index.html"},
+		{url: "http://example.com/index", want: "example.com/@index@.html"},
+		{url: "http://example.com/a/b.html", want: "example.com/a/b.html"},
+		{url: "http://example.com/about", want: "example.com/about@.html"},
+		{url: "http://example.com/about.html", want: "example.com/about.html"},
+		{url: "http://example.com/blog/", want: "example.com/blog/index.html"},
+		{url: "http://example.com/blog/?page=2", want: "example.com/blog/index@page=2.html"},
+		{url: "http://example.com/list.html?page=2", want: "example.com/list.html@page=2.html"},
+		{url: "http://example.com/list?page=2", want: "example.com/list@page=2.html"},
+		{url: "http://example.com/main.css?v=3", want: "example.com/main.css@v=3.css"},
+		{url: "http://example.com/view.php?id=1", want: "example.com/view.php@id=1.html"},
+		{url: "http://example.com/search?q=a%20b", want: "example.com/search@~94c0892792a8.html"},
+		{url: "http://example.com/app.js", want: "example.com/app.js"},
+		{url: "http://example.com/app.js/map.json", want: "example.com/app.js@/map.json"},
+		{url: "http://example.com/v2/api", want: "example.com/v2/api@.html"},
+		{url: "http://example.com/../../etc/passwd", want: "example.com/etc/passwd@.html"},
+		{url: "http://example.com/a/b/..", want: "example.com/a/index.html"},
+		{url: "http://example.com:8080/a%3Ab/c%2A.txt", want: "example.com_8080/a%3Ab/c%2A.txt"},
+		{url: "http://example.com/a%2Fb.txt", want: "example.com/a%2Fb.txt"},
+		{url: "http://example.com/a%40b.txt", want: "example.com/a%40b.txt"},
+		{url: "http://example.com/a%25b.txt", want: "example.com/a%25b.txt"},
+		{url: "http://example.com/a//b.txt", want: "example.com/a/@/b.txt"},
+		{url: "http://example.com/trailing.", want: "example.com/trailing%2E"},
+		{url: "http://example.com/con.txt", want: "example.com/@con.txt"},
+		{url: "http://example.com/caf%C3%A9.txt", want: "example.com/café.txt"},
+		{url: "http://example.com/" + strings.Repeat("x", 300) + ".css", want: "example.com/" + strings.Repeat("x", 183) + "~90a46d28c0ce.css"},
+	}
+
+	for _, tt := range pathTest {
+		t.Run(tt.url, func(t *testing.T) {
+			u, _ := url.Parse(tt.url)
+			if got := MirroredPath(u); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMirroredPathCollisions(t *testing.T) {
+	// the urls stay different once the path is percent-decoded
+	urls := []string{
+		"/", "/index.html", "/index", "/index.htm", "/?a", "/index?a", "/index.html?a",
+		"/about", "/about.html", "/about@.html",
+		"/list?page=2", "/list.html?page=2", "/list@page=2.html",
+		"/app.js", "/app.js/", "/app.js/map.json", "/app.js@/map.json",
+		"/a:b", "/a_b", "/a%253Ab", "/a/b", "/a%2Fb",
+		"/con", "/con.txt", "/@con.txt", "/a.", "/a%252E", "/a//b", "/a/@/b",
+		"/x?con", "/x?~abc", "/q?" + strings.Repeat("a", 100), "/q?" + strings.Repeat("a", 101),
+	}
+	paths := make(map[string]string)
+	dirs := make(map[string]bool)
+	for _, rawURL := range urls {
+		u, _ := url.Parse("http://example.com" + rawURL)
+		p := MirroredPath(u)
+		if other, ok := paths[p]; ok {
+			t.Errorf("%s and %s are both saved as %s", rawURL, other, p)
+		}
+		paths[p] = rawURL
+		for dir := path.Dir(p); dir != "."; dir = path.Dir(dir) {
+			dirs[dir] = true
+		}
+	}
+	for p, rawURL := range paths {
+		if dirs[p] {
+			t.Errorf("the file of %s is a directory of another file", rawURL)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package layout
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// IndexFileName lists "file path<TAB>url" lines for the indexed strategies
+const IndexFileName = "index.tsv"
+
+// Saver writes the downloaded bodies under the root directory using the strategy
+type Saver struct {
+	root     string
+	strategy Strategy
+	mu       sync.Mutex
+}
+
+func NewSaver(root string, strategy Strategy) *Saver {
+	return &Saver{root: root, strategy: strategy}
+}
+
+// Save writes the body and returns the path of the file
+func (s *Saver) Save(u *url.URL, body []byte) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	root, err := filepath.Abs(s.root)
+	if err != nil {
+		return "", err
+	}
+	current := root
+	for _, segment := range strings.Split(s.strategy.Path(u, body), "/") {
+		current = filepath.Join(current, safeSegment(segment))
+	}
+
+	rel, err := filepath.Rel(root, current)
+	if err != nil || rel == "." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || rel == ".." {
+		return "", fmt.Errorf("%s escapes the downloads directory", u)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(current), 0755); err != nil {
+		return "", fmt.Errorf("unable to create directory: %w", err)
+	}
+	if err := os.WriteFile(current, body, 0644); err != nil {
+		return "", err
+	}
+
+	if s.strategy.Indexed() {
+		if err := s.appendIndex(root, filepath.ToSlash(rel), u); err != nil {
+			return "", err
+		}
+	}
+	return current, nil
+}
+
+func (s *Saver) appendIndex(root, rel string, u *url.URL) error {
+	f, err := os.OpenFile(filepath.Join(root, IndexFileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintf(f, "%s\t%s\n", rel, u)
+	return err
+}
@@ -0,0 +1,74 @@
+package layout
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type escapingStrategy struct{}
+
+func (escapingStrategy) Path(u *url.URL, body []byte) string { return "../../outside" }
+func (escapingStrategy) Indexed() bool                       { return false }
+
+func TestSaver(t *testing.T) {
+	root := t.TempDir()
+	save := func(s *Saver, rawURL, body string) string {
+		t.Helper()
+		u, _ := url.Parse(rawURL)
+		p, err := s.Save(u, []byte(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rel, _ := filepath.Rel(root, p)
+		return filepath.ToSlash(rel)
+	}
+
+	mirrored := NewSaver(root, Mirrored{})
+	var saveTest = []struct {
+		url  string
+		want string
+	}{
+		{url: "http://example.com/a/b.html", want: "example.com/a/b.html"},
+		{url: "http://example.com/list?page=1", want: "example.com/list@page=1.html"},
+		{url: "http://example.com/list?page=2", want: "example.com/list@page=2.html"},
+		{url: "http://example.com/app.js", want: "example.com/app.js"},
+		{url: "http://example.com/app.js/map.json", want: "example.com/app.js@/map.json"},
+		{url: "http://example.com/app.js/map.json", want: "example.com/app.js@/map.json"},
+	}
+	for _, tt := range saveTest {
+		if got := save(mirrored, tt.url, tt.url); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.url, got, tt.want)
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "example.com/list@page=1.html")); string(data) != "http://example.com/list?page=1" {
+		t.Errorf("query pages overwrite each other")
+	}
+
+	hashed := NewSaver(root, ContentHash{})
+	first := save(hashed, "http://example.com/print?id=1", "same")
+	second := save(hashed, "http://example.com/print?id=2", "same")
+	if first != second || !strings.HasPrefix(first, "example.com/09/0967115f") {
+		t.Errorf("identical bodies are stored as %s and %s", first, second)
+	}
+	index, _ := os.ReadFile(filepath.Join(root, IndexFileName))
+	if !strings.Contains(string(index), first+"\thttp://example.com/print?id=2\n") {
+		t.Errorf("unexpected index %q", index)
+	}
+
+	flat := NewSaver(root, Flat{})
+	if got := save(flat, "http://example.com/deep/dir/page.html?x=1", ""); !strings.HasPrefix(got, "example.com/") || strings.Count(got, "/") != 1 {
+		t.Errorf("flat layout saved to %s", got)
+	}
+
+	u, _ := url.Parse("http://example.com/")
+	p, err := NewSaver(root, escapingStrategy{}).Save(u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rel, _ := filepath.Rel(root, p); strings.HasPrefix(rel, "..") {
+		t.Errorf("file escaped the root: %s", p)
+	}
+}
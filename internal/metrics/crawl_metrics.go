@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"mime"
+	"strconv"
+	"time"
+)
+
+var (
+	latencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+	// bodySizeBuckets are 1KiB to 64MiB by a factor of 4
+	bodySizeBuckets = []float64{1 << 10, 1 << 12, 1 << 14, 1 << 16, 1 << 18, 1 << 20, 1 << 22, 1 << 24, 1 << 26}
+)
+
+// FetchMetrics are the Prometheus metrics of the downloads, see fetcher.WebFetcher.SetMetrics
+type FetchMetrics struct {
+	fetches  *CounterVec
+	latency  *HistogramVec
+	bodySize *HistogramVec
+}
+
+func NewFetchMetrics(r *Registry) *FetchMetrics {
+	return &FetchMetrics{
+		fetches: r.NewCounter("crawler_fetches_total",
+			"Requests by host, status code (none without an answer) and MIME type.", "host", "status", "mime"),
+		latency: r.NewHistogram("crawler_fetch_duration_seconds",
+			"Time from sending the request to reading the body by host.", latencyBuckets, "host"),
+		bodySize: r.NewHistogram("crawler_body_size_bytes",
+			"Size of the read bodies on the wire by MIME type.", bodySizeBuckets, "mime"),
+	}
+}
+
+// ObserveFetch records a request, status is zero when the server did not answer
+func (m *FetchMetrics) ObserveFetch(host string, status int, contentType string, duration time.Duration, bytes int64) {
+	statusLabel := "none"
+	if status != 0 {
+		statusLabel = strconv.Itoa(status)
+	}
+	mimeType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mimeType == "" {
+		mimeType = "none"
+	}
+	m.fetches.Add(1, host, statusLabel, mimeType)
+	m.latency.Observe(duration.Seconds(), host)
+	if bytes > 0 {
+		m.bodySize.Observe(float64(bytes), mimeType)
+	}
+}
+
+// RegisterCollector exposes the crawl counters of the collector
+func RegisterCollector(r *Registry, c *Collector) {
+	r.NewGaugeFunc("crawler_requests_in_flight", "Requests being fetched and processed.", func() float64 {
+		return float64(c.Snapshot().InFlight)
+	})
+	r.NewCounterFunc("crawler_fetch_errors_total", "Failed pages by error type.", "type", func() map[string]float64 {
+		values := make(map[string]float64)
+		for errorType, n := range c.Snapshot().ByErrorType {
+			values[errorType] = float64(n)
+		}
+		return values
+	})
+}
@@ -0,0 +1,213 @@
+package metrics
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Request is the outcome of a single fetch
+type Request struct {
+	Host string
+	// StatusCode is zero when the server did not answer
+	StatusCode int
+	// ErrorType classifies the failure, empty when the page was fetched
+	ErrorType string
+	// Bytes is the size of the body on the wire
+	Bytes    int64
+	Duration time.Duration
+}
+
+// Windows are the spans of the throughput rates
+var Windows = []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute}
+
+// Collector aggregates the requests of the crawl, it is safe for concurrent use
+type Collector struct {
+	now     func() time.Time
+	started time.Time
+
+	mu            sync.Mutex
+	inFlight      int
+	fetched       int
+	failed        int
+	byStatusClass map[string]int
+	byErrorType   map[string]int
+	bytes         int64
+	latency       time.Duration
+	byHost        map[string]*HostStats
+	// seconds is a ring of per-second buckets covering the longest window
+	seconds []bucket
+}
+
+type bucket struct {
+	second int64
+	pages  int
+	bytes  int64
+}
+
+func NewCollector() *Collector {
+	return newCollector(time.Now)
+}
+
+func newCollector(now func() time.Time) *Collector {
+	return &Collector{
+		now:           now,
+		started:       now(),
+		byStatusClass: make(map[string]int),
+		byErrorType:   make(map[string]int),
+		byHost:        make(map[string]*HostStats),
+		seconds:       make([]bucket, int(Windows[len(Windows)-1]/time.Second)),
+	}
+}
+
+// RequestStarted counts the request in flight until RequestDone
+func (c *Collector) RequestStarted() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.inFlight++
+}
+
+func (c *Collector) RequestDone(r Request) {
+	second := c.now().Unix()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.inFlight--
+	if r.ErrorType == "" {
+		c.fetched++
+	} else {
+		c.failed++
+		c.byErrorType[r.ErrorType]++
+	}
+	c.byStatusClass[StatusClass(r.StatusCode)]++
+	c.bytes += r.Bytes
+	c.latency += r.Duration
+
+	host := c.byHost[r.Host]
+	if host == nil {
+		host = &HostStats{Host: r.Host}
+		c.byHost[r.Host] = host
+	}
+	if r.ErrorType == "" {
+		host.Fetched++
+	} else {
+		host.Failed++
+	}
+	host.Bytes += r.Bytes
+	host.latency += r.Duration
+
+	b := &c.seconds[second%int64(len(c.seconds))]
+	if b.second != second {
+		*b = bucket{second: second}
+	}
+	b.pages++
+	b.bytes += r.Bytes
+}
+
+// StatusClass groups the status codes like 2xx, the requests without an answer are "none"
+func StatusClass(code int) string {
+	if code < 100 || code > 599 {
+		return "none"
+	}
+	return fmt.Sprintf("%dxx", code/100)
+}
+
+// HostStats are the requests of a single host
+type HostStats struct {
+	Host           string  `json:"host"`
+	Fetched        int     `json:"fetched"`
+	Failed         int     `json:"failed"`
+	Bytes          int64   `json:"bytes_downloaded"`
+	AverageLatency Seconds `json:"average_latency_seconds"`
+	latency        time.Duration
+}
+
+// Hosts returns the requests by host in no particular order
+func (c *Collector) Hosts() []HostStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	hosts := make([]HostStats, 0, len(c.byHost))
+	for _, h := range c.byHost {
+		stats := *h
+		stats.AverageLatency = Seconds(h.latency / time.Duration(h.Fetched+h.Failed))
+		hosts = append(hosts, stats)
+	}
+	return hosts
+}
+
+// Snapshot is the state of the collector at a point in time
+type Snapshot struct {
+	StartedAt     time.Time      `json:"started_at"`
+	Uptime        Seconds        `json:"uptime_seconds"`
+	InFlight      int            `json:"in_flight"`
+	Fetched       int            `json:"fetched"`
+	Failed        int            `json:"failed"`
+	ByStatusClass map[string]int `json:"by_status_class"`
+	ByErrorType   map[string]int `json:"by_error_type"`
+	Bytes         int64          `json:"bytes_downloaded"`
+	// AverageLatency is of all the requests since the start
+	AverageLatency Seconds      `json:"average_latency_seconds"`
+	Throughput     []Throughput `json:"throughput"`
+}
+
+// Throughput is the rate of the requests over the last Window, the crawls younger than the window
+// are rated over their uptime
+type Throughput struct {
+	Window         string  `json:"window"`
+	PagesPerSecond float64 `json:"pages_per_second"`
+	BytesPerSecond float64 `json:"bytes_per_second"`
+}
+
+// Seconds is a duration encoded as fractional seconds
+type Seconds time.Duration
+
+func (s Seconds) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf("%.3f", time.Duration(s).Seconds())), nil
+}
+
+func (c *Collector) Snapshot() Snapshot {
+	now := c.now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := Snapshot{
+		StartedAt:     c.started,
+		Uptime:        Seconds(now.Sub(c.started)),
+		InFlight:      c.inFlight,
+		Fetched:       c.fetched,
+		Failed:        c.failed,
+		ByStatusClass: make(map[string]int, len(c.byStatusClass)),
+		ByErrorType:   make(map[string]int, len(c.byErrorType)),
+		Bytes:         c.bytes,
+	}
+	for k, v := range c.byStatusClass {
+		s.ByStatusClass[k] = v
+	}
+	for k, v := range c.byErrorType {
+		s.ByErrorType[k] = v
+	}
+	if requests := c.fetched + c.failed; requests > 0 {
+		s.AverageLatency = Seconds(c.latency / time.Duration(requests))
+	}
+
+	second := now.Unix()
+	for _, window := range Windows {
+		span := min(window, now.Sub(c.started)).Seconds()
+		t := Throughput{Window: fmt.Sprintf("%dm", int(window.Minutes()))}
+		if span >= 1 {
+			var pages int
+			var bytes int64
+			for _, b := range c.seconds {
+				if b.second > second-int64(window/time.Second) {
+					pages += b.pages
+					bytes += b.bytes
+				}
+			}
+			t.PagesPerSecond = float64(pages) / span
+			t.BytesPerSecond = float64(bytes) / span
+		}
+		s.Throughput = append(s.Throughput, t)
+	}
+	return s
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCollector(t *testing.T) {
+	now := time.Unix(1000, 0)
+	c := newCollector(func() time.Time { return now })
+
+	c.RequestStarted()
+	c.RequestStarted()
+	now = now.Add(30 * time.Second)
+	c.RequestDone(Request{Host: "a.com", StatusCode: 200, Bytes: 600, Duration: time.Second})
+	if s := c.Snapshot(); s.InFlight != 1 {
+		t.Errorf("got %d in flight, want 1", s.InFlight)
+	}
+	c.RequestDone(Request{Host: "a.com", StatusCode: 404, ErrorType: "http_status", Duration: 3 * time.Second})
+	c.RequestStarted()
+	c.RequestDone(Request{Host: "b.com", ErrorType: "network"})
+
+	now = now.Add(10 * time.Minute)
+	c.RequestStarted()
+	c.RequestDone(Request{Host: "b.com", StatusCode: 200, Bytes: 300})
+
+	s := c.Snapshot()
+	if s.Fetched != 2 || s.Failed != 2 || s.InFlight != 0 || s.Bytes != 900 {
+		t.Errorf("got %+v", s)
+	}
+	if s.ByStatusClass["2xx"] != 2 || s.ByStatusClass["4xx"] != 1 || s.ByStatusClass["none"] != 1 {
+		t.Errorf("got status classes %v", s.ByStatusClass)
+	}
+	if s.ByErrorType["http_status"] != 1 || s.ByErrorType["network"] != 1 {
+		t.Errorf("got error types %v", s.ByErrorType)
+	}
+	if s.AverageLatency != Seconds(time.Second) {
+		t.Errorf("got average latency %s", time.Duration(s.AverageLatency))
+	}
+	if time.Duration(s.Uptime) != 630*time.Second {
+		t.Errorf("got uptime %s", time.Duration(s.Uptime))
+	}
+
+	hosts := make(map[string]HostStats)
+	for _, h := range c.Hosts() {
+		hosts[h.Host] = h
+	}
+	if a := hosts["a.com"]; a.Fetched != 1 || a.Failed != 1 || a.Bytes != 600 || a.AverageLatency != Seconds(2*time.Second) {
+		t.Errorf("got a.com %+v", a)
+	}
+	if b := hosts["b.com"]; b.Fetched != 1 || b.Failed != 1 || b.Bytes != 300 || len(hosts) != 2 {
+		t.Errorf("got hosts %+v", hosts)
+	}
+
+	rates := make(map[string]Throughput)
+	for _, r := range s.Throughput {
+		rates[r.Window] = r
+	}
+	if r := rates["1m"]; r.PagesPerSecond != 1.0/60 || r.BytesPerSecond != 5 {
+		t.Errorf("got the 1m rate %+v", r)
+	}
+	// the crawl is younger than the window, the rate is over the uptime
+	if r := rates["15m"]; r.PagesPerSecond != 4.0/630 {
+		t.Errorf("got the 15m rate %+v", r)
+	}
+}
@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegistryWriteText(t *testing.T) {
+	r := NewRegistry()
+	m := NewFetchMetrics(r)
+	m.ObserveFetch("a.com", 200, "text/html; charset=utf-8", 300*time.Millisecond, 2000)
+	m.ObserveFetch("a.com", 200, "text/html", 2*time.Second, 500)
+	m.ObserveFetch(`b"c.com`, 0, "", time.Second, 0)
+	r.NewGaugeFunc("crawler_queue_depth", "URLs waiting in the queue.", func() float64 { return 42 })
+	r.NewCounterFunc("crawler_fetch_errors_total", "Failed pages by error type.", "type", func() map[string]float64 {
+		return map[string]float64{"network": 1}
+	})
+
+	var b strings.Builder
+	r.WriteText(&b)
+	text := b.String()
+	for _, line := range []string{
+		"# TYPE crawler_fetches_total counter",
+		`crawler_fetches_total{host="a.com",status="200",mime="text/html"} 2`,
+		`crawler_fetches_total{host="b\"c.com",status="none",mime="none"} 1`,
+		"# TYPE crawler_fetch_duration_seconds histogram",
+		`crawler_fetch_duration_seconds_bucket{host="a.com",le="0.25"} 0`,
+		`crawler_fetch_duration_seconds_bucket{host="a.com",le="0.5"} 1`,
+		`crawler_fetch_duration_seconds_bucket{host="a.com",le="+Inf"} 2`,
+		`crawler_fetch_duration_seconds_sum{host="a.com"} 2.3`,
+		`crawler_fetch_duration_seconds_count{host="a.com"} 2`,
+		`crawler_body_size_bytes_bucket{mime="text/html",le="1024"} 1`,
+		`crawler_body_size_bytes_count{mime="text/html"} 2`,
+		"crawler_queue_depth 42",
+		`crawler_fetch_errors_total{type="network"} 1`,
+	} {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("%s is missing in\n%s", line, text)
+		}
+	}
+	if strings.Contains(text, `crawler_body_size_bytes_count{mime="none"}`) {
+		t.Error("the body size of the failed request is observed")
+	}
+}
@@ -0,0 +1,35 @@
+package mirror
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+// RelativeLink returns the link from the page saved at fromPath to the file saved at toPath
+func RelativeLink(fromPath, toPath string) string {
+	fromDir := strings.Split(path.Dir("/"+fromPath), "/")[1:]
+	to := strings.Split(path.Clean("/"+toPath), "/")[1:]
+	if len(fromDir) == 1 && fromDir[0] == "" {
+		fromDir = nil
+	}
+
+	common := 0
+	for common < len(fromDir) && common < len(to)-1 && fromDir[common] == to[common] {
+		common++
+	}
+	parts := make([]string, 0, len(fromDir)-common+len(to)-common)
+	for i := common; i < len(fromDir); i++ {
+		parts = append(parts, "..")
+	}
+	for _, part := range to[common:] {
+		parts = append(parts, url.PathEscape(part))
+	}
+
+	// a colon in the first segment would be read as a scheme
+	link := strings.Join(parts, "/")
+	if strings.Contains(strings.SplitN(link, "/", 2)[0], ":") {
+		link = "./" + link
+	}
+	return link
+}
@@ -0,0 +1,25 @@
+package mirror
+
+import "testing"
+
+func TestRelativeLink(t *testing.T) {
+	var linkTest = []struct {
+		from string
+		to   string
+		want string
+	}{
+		{from: "example.com/index.html", to: "example.com/about.html", want: "about.html"},
+		{from: "example.com/a/b/page.html", to: "example.com/a/c/style.css", want: "../c/style.css"},
+		{from: "example.com/a/page.html", to: "example.com/a/page.html", want: "page.html"},
+		{from: "example.com/index.html", to: "example.com/docs/my file.html", want: "docs/my%20file.html"},
+		{from: "example.com/index.html", to: "example.com/a:b.html", want: "./a:b.html"},
+	}
+
+	for _, tt := range linkTest {
+		t.Run(tt.to, func(t *testing.T) {
+			if got := RelativeLink(tt.from, tt.to); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package mirror
+
+import (
+	"bytes"
+	"crawler/internal/layout"
+	"golang.org/x/net/html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Resolver decides whether the url is a part of the mirror, only those links are made local
+type Resolver func(u *url.URL) bool
+
+var (
+	cssURLPattern    = regexp.MustCompile(`url\(\s*(['"]?)([^'")]*)(['"]?)\s*\)`)
+	cssImportPattern = regexp.MustCompile(`@import\s+(['"])([^'"]+)(['"])`)
+)
+
+// urlAttributes are the attributes holding a single url
+var urlAttributes = map[string]bool{
+	"href":       true,
+	"src":        true,
+	"poster":     true,
+	"background": true,
+	"data":       true,
+}
+
+// Rewriter turns the absolute links of the saved pages into relative links between the local files
+type Rewriter struct {
+	inMirror Resolver
+}
+
+func NewRewriter(inMirror Resolver) *Rewriter {
+	return &Rewriter{inMirror: inMirror}
+}
+
+// local returns the link to the local copy of ref as seen from the page, or false to keep it as is
+func (rw *Rewriter) local(page *url.URL, ref string) (string, bool) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || strings.HasPrefix(ref, "#") {
+		return "", false
+	}
+	target, err := page.Parse(ref)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || !rw.inMirror(target) {
+		return "", false
+	}
+
+	link := RelativeLink(layout.MirroredPath(page), layout.MirroredPath(target))
+	if target.Fragment != "" {
+		link += "#" + target.EscapedFragment()
+	}
+	return link, true
+}
+
+// HTML rewrites href, src, srcset, inline styles and <style> blocks. Untouched tokens are copied
+// byte for byte, so the original encoding and formatting survive.
+func (rw *Rewriter) HTML(body []byte, page *url.URL) []byte {
+	var out bytes.Buffer
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	inStyle := false
+
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			return out.Bytes()
+		}
+		// Token() lowercases the tag name inside the raw buffer, the copy keeps the original
+		raw := append([]byte(nil), tokenizer.Raw()...)
+
+		switch tokenType {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			inStyle = token.Data == "style" && tokenType == html.StartTagToken
+			changed := false
+			for i, attr := range token.Attr {
+				var value string
+				switch {
+				case urlAttributes[attr.Key]:
+					value = attr.Val
+					if link, ok := rw.local(page, attr.Val); ok {
+						value = link
+					}
+				case attr.Key == "srcset":
+					value = rw.srcset(attr.Val, page)
+				case attr.Key == "style":
+					value = string(rw.CSS([]byte(attr.Val), page))
+				default:
+					continue
+				}
+				if value != attr.Val {
+					token.Attr[i].Val = value
+					changed = true
+				}
+			}
+			if changed {
+				out.WriteString(token.String())
+				continue
+			}
+		case html.EndTagToken:
+			inStyle = false
+		case html.TextToken:
+			if inStyle {
+				out.Write(rw.CSS(raw, page))
+				continue
+			}
+		}
+		out.Write(raw)
+	}
+}
+
+// srcset is a comma separated list of "url descriptor" candidates
+func (rw *Rewriter) srcset(value string, page *url.URL) string {
+	candidates := strings.Split(value, ",")
+	changed := false
+	for i, candidate := range candidates {
+		fields := strings.Fields(candidate)
+		if len(fields) == 0 {
+			continue
+		}
+		if link, ok := rw.local(page, fields[0]); ok {
+			fields[0] = link
+			changed = true
+		}
+		candidates[i] = strings.Join(fields, " ")
+	}
+	if !changed {
+		return value
+	}
+	return strings.Join(candidates, ", ")
+}
+
+// CSS rewrites url() references and @import strings
+func (rw *Rewriter) CSS(css []byte, page *url.URL) []byte {
+	replace := func(pattern *regexp.Regexp, format func(open, link, close string) string) {
+		css = pattern.ReplaceAllFunc(css, func(match []byte) []byte {
+			groups := pattern.FindSubmatch(match)
+			link, ok := rw.local(page, string(groups[2]))
+			if !ok {
+				return match
+			}
+			return []byte(format(string(groups[1]), link, string(groups[3])))
+		})
+	}
+	replace(cssURLPattern, func(open, link, close string) string { return "url(" + open + link + close + ")" })
+	replace(cssImportPattern, func(open, link, close string) string { return "@import " + open + link + close })
+	return css
+}
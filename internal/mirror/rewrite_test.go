@@ -0,0 +1,73 @@
+package mirror
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestRewriter(t *testing.T) {
+	rw := NewRewriter(func(u *url.URL) bool {
+		return u.Hostname() == "example.com"
+	})
+
+	var rewriteTest = []struct {
+		name string
+		page string
+		css  bool
+		body string
+		want string
+	}{
+		{
+			name: "anchors and scripts",
+			page: "http://example.com/blog/post",
+			body: `<a href="/about">About</a><a href="https://other.com/x">x</a><script src="../js/app.js"></script><a href="#top">top</a>`,
+			want: `<a href="../about@.html">About</a><a href="https://other.com/x">x</a><script src="../js/app.js"></script><a href="#top">top</a>`,
+		},
+		{
+			name: "query and fragment",
+			page: "http://example.com/",
+			body: `<a href="list?page=2#results">next</a>`,
+			want: `<a href="list@page=2.html#results">next</a>`,
+		},
+		{
+			name: "srcset and inline style",
+			page: "http://example.com/",
+			body: `<img srcset="/a.png 1x,  /b.png 2x" style="background: url('/bg.png')">`,
+			want: `<img srcset="a.png 1x, b.png 2x" style="background: url(&#39;bg.png&#39;)">`,
+		},
+		{
+			name: "style block",
+			page: "http://example.com/",
+			body: `<style>body { background: url(/img/bg.png) }</style><p>url(/img/bg.png)</p>`,
+			want: `<style>body { background: url(img/bg.png) }</style><p>url(/img/bg.png)</p>`,
+		},
+		{
+			name: "untouched markup is kept",
+			page: "http://example.com/",
+			body: "<!DOCTYPE html>\n<P CLASS=x>caf\xe9 &amp; <a href='https://other.com/'>o</a>",
+			want: "<!DOCTYPE html>\n<P CLASS=x>caf\xe9 &amp; <a href='https://other.com/'>o</a>",
+		},
+		{
+			name: "stylesheet",
+			page: "http://example.com/css/main.css",
+			css:  true,
+			body: `@import "print.css"; .a { background: url("/img/a.png") } .b { background: url(data:image/png;base64,AA) }`,
+			want: `@import "print.css"; .a { background: url("../img/a.png") } .b { background: url(data:image/png;base64,AA) }`,
+		},
+	}
+
+	for _, tt := range rewriteTest {
+		t.Run(tt.name, func(t *testing.T) {
+			page, _ := url.Parse(tt.page)
+			var got []byte
+			if tt.css {
+				got = rw.CSS([]byte(tt.body), page)
+			} else {
+				got = rw.HTML([]byte(tt.body), page)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,8 +1,12 @@
 package parser
 
 import (
+	"bytes"
 	"fmt"
 	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/transform"
+	"io"
 	"strings"
 )
 
@@ -24,9 +28,39 @@ func NewParser() *Parser {
 	}
 }
 
-func (p *Parser) ParseLinks(body []byte) ([]string, error) {
-	links := make([]string, 0)
-	tokenizer := html.NewTokenizer(strings.NewReader(string(body)))
+// Link sources tell where on the page the link was found
+const (
+	// SourceSeed is not produced by the parser, it marks the start urls
+	SourceSeed    = "seed"
+	SourceSitemap = "sitemap"
+	SourceNav     = "nav"
+	SourceContent = "content"
+	SourceAsset   = "asset"
+)
+
+type Link struct {
+	URL    string
+	Source string
+}
+
+// navElements hold the site navigation, the links inside are usually the most important ones
+var navElements = map[string]bool{
+	"nav":    true,
+	"header": true,
+	"footer": true,
+	"menu":   true,
+}
+
+// ParseLinks extracts the links of HTML pages and of XML sitemaps, where every <loc> is a link
+func (p *Parser) ParseLinks(body []byte, contentType string) ([]Link, error) {
+	links := make([]Link, 0)
+	utf8Body, err := ToUTF8(body, contentType)
+	if err != nil {
+		return links, fmt.Errorf("unable to decode the page: %w", err)
+	}
+	tokenizer := html.NewTokenizer(bytes.NewReader(utf8Body))
+	navDepth := 0
+	inLoc := false
 
 	for {
 		tokenType := tokenizer.Next()
@@ -38,13 +72,92 @@ func (p *Parser) ParseLinks(body []byte) ([]string, error) {
 			return links, nil
 		case html.StartTagToken, html.SelfClosingTagToken:
 			token := tokenizer.Token()
+			if navElements[token.Data] && tokenType == html.StartTagToken {
+				navDepth++
+			}
+			inLoc = token.Data == "loc" && tokenType == html.StartTagToken
 			if token.Data == "a" || token.Data == "link" || token.Data == "script" {
+				source := SourceContent
+				if token.Data != "a" {
+					source = SourceAsset
+				} else if navDepth > 0 {
+					source = SourceNav
+				}
 				for _, attr := range token.Attr {
 					if attr.Key == "href" || attr.Key == "src" {
-						links = append(links, attr.Val)
+						links = append(links, Link{URL: attr.Val, Source: source})
 					}
 				}
 			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			if navElements[string(name)] && navDepth > 0 {
+				navDepth--
+			}
+			inLoc = false
+		case html.TextToken:
+			if inLoc {
+				if loc := strings.TrimSpace(string(tokenizer.Text())); loc != "" {
+					links = append(links, Link{URL: loc, Source: SourceSitemap})
+				}
+			}
+		}
+	}
+}
+
+// ToUTF8 transcodes the body to UTF-8. The encoding is taken from the BOM first,
+// then the charset parameter of the Content-Type header, then a <meta charset> prescan
+// of the first 1024 bytes, falling back to UTF-8 sniffing and finally windows-1252.
+func ToUTF8(body []byte, contentType string) ([]byte, error) {
+	enc, name, _ := charset.DetermineEncoding(body, contentType)
+	if name == "utf-8" {
+		// DetermineEncoding does not strip the BOM, the tokenizer would treat it as text
+		return bytes.TrimPrefix(body, []byte("\xef\xbb\xbf")), nil
+	}
+
+	return io.ReadAll(transform.NewReader(bytes.NewReader(body), enc.NewDecoder()))
+}
+
+// invisibleElements hold no text a reader sees on the page
+var invisibleElements = map[string]bool{
+	"script":   true,
+	"style":    true,
+	"noscript": true,
+	"template": true,
+}
+
+// VisibleText returns the text of the page a reader sees, separated by spaces
+func (p *Parser) VisibleText(body []byte, contentType string) (string, error) {
+	utf8Body, err := ToUTF8(body, contentType)
+	if err != nil {
+		return "", fmt.Errorf("unable to decode the page: %w", err)
+	}
+	tokenizer := html.NewTokenizer(bytes.NewReader(utf8Body))
+
+	var text strings.Builder
+	hidden := 0
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			if tokenizer.Err() != io.EOF {
+				return text.String(), tokenizer.Err()
+			}
+			return text.String(), nil
+		case html.StartTagToken:
+			name, _ := tokenizer.TagName()
+			if invisibleElements[string(name)] {
+				hidden++
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			if invisibleElements[string(name)] && hidden > 0 {
+				hidden--
+			}
+		case html.TextToken:
+			if hidden == 0 {
+				text.Write(tokenizer.Text())
+				text.WriteByte(' ')
+			}
 		}
 	}
 }
@@ -0,0 +1,114 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseLinksCharset(t *testing.T) {
+	var charsetTest = []struct {
+		name        string
+		body        []byte
+		contentType string
+		want        []Link
+	}{
+		{
+			name:        "windows-1251 from the header",
+			body:        []byte("<a href=\"/\xef\xf0\xe8\xe2\xe5\xf2\">\xef\xf0\xe8\xe2\xe5\xf2</a>"),
+			contentType: "text/html; charset=windows-1251",
+			want:        []Link{{URL: "/привет", Source: SourceContent}},
+		},
+		{
+			name:        "shift_jis from the meta tag",
+			body:        []byte("<meta charset=\"Shift_JIS\"><a href=\"/\x93\xfa\x96\x7b\">x</a>"),
+			contentType: "text/html",
+			want:        []Link{{URL: "/日本", Source: SourceContent}},
+		},
+		{
+			name:        "iso-8859-1 from the meta http-equiv",
+			body:        []byte("<meta http-equiv=\"Content-Type\" content=\"text/html; charset=ISO-8859-1\"><a href=\"/caf\xe9\">x</a>"),
+			contentType: "text/html",
+			want:        []Link{{URL: "/café", Source: SourceContent}},
+		},
+		{
+			name:        "utf-8 with bom",
+			body:        []byte("\xef\xbb\xbf<a href=\"/café\">x</a>"),
+			contentType: "text/html; charset=windows-1251",
+			want:        []Link{{URL: "/café", Source: SourceContent}},
+		},
+	}
+
+	p := NewParser()
+	for _, tt := range charsetTest {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := p.ParseLinks(tt.body, tt.contentType)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLinksSources(t *testing.T) {
+	var sourceTest = []struct {
+		name        string
+		body        string
+		contentType string
+		want        []Link
+	}{
+		{
+			name:        "html",
+			body:        `<link href="/main.css"><header><nav><a href="/about">About</a></nav></header><p><a href="/post">Post</a></p><script src="/app.js"></script>`,
+			contentType: "text/html",
+			want: []Link{
+				{URL: "/main.css", Source: SourceAsset},
+				{URL: "/about", Source: SourceNav},
+				{URL: "/post", Source: SourceContent},
+				{URL: "/app.js", Source: SourceAsset},
+			},
+		},
+		{
+			name: "sitemap",
+			body: `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>https://example.com/</loc><lastmod>2024-01-01</lastmod></url>
+  <url><loc> https://example.com/about </loc></url>
+</urlset>`,
+			contentType: "application/xml",
+			want: []Link{
+				{URL: "https://example.com/", Source: SourceSitemap},
+				{URL: "https://example.com/about", Source: SourceSitemap},
+			},
+		},
+	}
+
+	p := NewParser()
+	for _, tt := range sourceTest {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := p.ParseLinks([]byte(tt.body), tt.contentType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVisibleText(t *testing.T) {
+	body := []byte(`<html><head><title>Title</title><style>p { color: red }</style><script>var x = "hidden";</script></head>
+<body><p>Hello <b>world</b></p><noscript>enable js</noscript><template><p>later</p></template></body></html>`)
+
+	got, err := NewParser().VisibleText(body, "text/html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fields := strings.Fields(got); !reflect.DeepEqual(fields, []string{"Title", "Hello", "world"}) {
+		t.Errorf("got %q", fields)
+	}
+}
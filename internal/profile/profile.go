@@ -0,0 +1,139 @@
+package profile
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Auth is the credentials of the hosts of a profile, it has the fields of fetcher.HostAuth
+type Auth struct {
+	Username    string
+	Password    string
+	BearerToken string
+	Cookies     map[string]string
+}
+
+// Profile is the settings resolved for a single host
+type Profile struct {
+	// Parallelism limits the concurrent requests to the host, zero means only the global limit applies
+	Parallelism int
+	// Delay is the pause between the starts of two requests to the host
+	Delay               time.Duration
+	Headers             map[string]string
+	Auth                *Auth
+	AcceptableMimeTypes []string
+	// MaxBodySize limits the bytes read of a body, zero means no limit
+	MaxBodySize int64
+	// Timeout limits a request from the connection to the end of the body, zero means no limit
+	Timeout time.Duration
+	// InScope includes or excludes the host regardless of the seeds, nil leaves it to the seeds
+	InScope *bool
+}
+
+// Rule applies the profile to the hosts matching the pattern: a host name or a glob like "*.example.com".
+// Only the set fields of the profile override, the headers are merged.
+type Rule struct {
+	Match   string
+	Profile Profile
+}
+
+// Resolver merges the rules matching a host over the defaults in the order of the rules,
+// so the more specific rules go last
+type Resolver struct {
+	defaults Profile
+	rules    []Rule
+
+	mu    sync.Mutex
+	cache map[string]*Profile
+}
+
+func NewResolver(defaults Profile, rules []Rule) (*Resolver, error) {
+	if err := checkRules(rules); err != nil {
+		return nil, err
+	}
+	return &Resolver{defaults: defaults, rules: rules, cache: make(map[string]*Profile)}, nil
+}
+
+// Reload replaces the defaults and the rules at once, the profiles returned before are not changed.
+// Invalid rules are an error and keep the current ones.
+func (r *Resolver) Reload(defaults Profile, rules []Rule) error {
+	if err := checkRules(rules); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.defaults = defaults
+	r.rules = rules
+	r.cache = make(map[string]*Profile)
+	return nil
+}
+
+func checkRules(rules []Rule) error {
+	for _, rule := range rules {
+		if _, err := path.Match(rule.Match, ""); err != nil || rule.Match == "" {
+			return fmt.Errorf("invalid host pattern %q", rule.Match)
+		}
+	}
+	return nil
+}
+
+// For returns the profile of the host, the result is shared and must not be changed
+func (r *Resolver) For(host string) *Profile {
+	host = strings.ToLower(host)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if p, ok := r.cache[host]; ok {
+		return p
+	}
+
+	p := r.defaults
+	p.Headers = merge(nil, r.defaults.Headers)
+	for _, rule := range r.rules {
+		if matched, _ := path.Match(strings.ToLower(rule.Match), host); matched {
+			p.apply(rule.Profile)
+		}
+	}
+	r.cache[host] = &p
+	return &p
+}
+
+func (p *Profile) apply(o Profile) {
+	if o.Parallelism != 0 {
+		p.Parallelism = o.Parallelism
+	}
+	if o.Delay != 0 {
+		p.Delay = o.Delay
+	}
+	p.Headers = merge(p.Headers, o.Headers)
+	if o.Auth != nil {
+		p.Auth = o.Auth
+	}
+	if len(o.AcceptableMimeTypes) > 0 {
+		p.AcceptableMimeTypes = o.AcceptableMimeTypes
+	}
+	if o.MaxBodySize != 0 {
+		p.MaxBodySize = o.MaxBodySize
+	}
+	if o.Timeout != 0 {
+		p.Timeout = o.Timeout
+	}
+	if o.InScope != nil {
+		p.InScope = o.InScope
+	}
+}
+
+func merge(dst, src map[string]string) map[string]string {
+	if len(src) == 0 {
+		return dst
+	}
+	if dst == nil {
+		dst = make(map[string]string, len(src))
+	}
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
@@ -0,0 +1,68 @@
+package profile
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResolverFor(t *testing.T) {
+	no := false
+	r, err := NewResolver(Profile{Delay: time.Second, Headers: map[string]string{"User-Agent": "crawler"}}, []Rule{
+		{Match: "*.example.com", Profile: Profile{Parallelism: 2, Headers: map[string]string{"Accept-Language": "en"}}},
+		{Match: "slow.example.com", Profile: Profile{Delay: 5 * time.Second, Headers: map[string]string{"User-Agent": "polite"}}},
+		{Match: "ads.*", Profile: Profile{InScope: &no}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := r.For("Slow.Example.com")
+	if p.Parallelism != 2 || p.Delay != 5*time.Second {
+		t.Errorf("got parallelism %d and delay %s", p.Parallelism, p.Delay)
+	}
+	if p.Headers["User-Agent"] != "polite" || p.Headers["Accept-Language"] != "en" {
+		t.Errorf("got headers %v", p.Headers)
+	}
+
+	p = r.For("www.example.com")
+	if p.Parallelism != 2 || p.Delay != time.Second || p.Headers["User-Agent"] != "crawler" {
+		t.Errorf("got %+v", p)
+	}
+
+	p = r.For("example.com")
+	if p.Parallelism != 0 || p.InScope != nil || len(p.Headers) != 1 {
+		t.Errorf("the subdomain rule matched the domain: %+v", p)
+	}
+	if p := r.For("ads.example.org"); p.InScope == nil || *p.InScope {
+		t.Errorf("got in scope %v", p.InScope)
+	}
+
+	if _, err := NewResolver(Profile{}, []Rule{{Match: "[a-"}}); err == nil {
+		t.Error("expected an error for a broken pattern")
+	}
+}
+
+func TestResolverReload(t *testing.T) {
+	r, err := NewResolver(Profile{Delay: time.Second}, []Rule{{Match: "a.com", Profile: Profile{Parallelism: 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := r.For("a.com")
+
+	if err := r.Reload(Profile{}, []Rule{{Match: "[a-"}}); err == nil {
+		t.Error("expected an error for a broken pattern")
+	}
+	if p := r.For("a.com"); p.Parallelism != 1 || p.Delay != time.Second {
+		t.Errorf("the rejected rules are applied: %+v", p)
+	}
+
+	if err := r.Reload(Profile{Delay: 2 * time.Second}, []Rule{{Match: "a.com", Profile: Profile{Parallelism: 4}}}); err != nil {
+		t.Fatal(err)
+	}
+	if p := r.For("a.com"); p.Parallelism != 4 || p.Delay != 2*time.Second {
+		t.Errorf("got %+v after the reload", p)
+	}
+	if before.Parallelism != 1 {
+		t.Error("the profile returned before the reload is changed")
+	}
+}
@@ -0,0 +1,188 @@
+package seed
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Seed is a start url. Its host is always in scope, Scope adds more hosts, "*.example.com" matches the subdomains.
+// MaxDepth limits the link hops from the seed, zero means no limit.
+type Seed struct {
+	URL      string   `json:"url"`
+	MaxDepth int      `json:"depth,omitempty"`
+	Scope    []string `json:"scope,omitempty"`
+}
+
+// Read parses a seed list: one url per line or a JSON object per line, the formats can be mixed.
+// Empty lines and lines starting with # are skipped.
+func Read(r io.Reader) ([]Seed, error) {
+	var seeds []Seed
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		s := Seed{URL: line}
+		if strings.HasPrefix(line, "{") {
+			s = Seed{}
+			if err := json.Unmarshal([]byte(line), &s); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+		}
+		if err := s.validate(); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		seeds = append(seeds, s)
+	}
+	return seeds, scanner.Err()
+}
+
+// ReadFile reads the seed list from the file, "-" reads the standard input
+func ReadFile(path string) ([]Seed, error) {
+	if path == "-" {
+		return Read(os.Stdin)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// FromURLs makes the seeds of the command line arguments
+func FromURLs(urls []string) ([]Seed, error) {
+	seeds := make([]Seed, 0, len(urls))
+	for _, u := range urls {
+		s := Seed{URL: u}
+		if err := s.validate(); err != nil {
+			return nil, err
+		}
+		seeds = append(seeds, s)
+	}
+	return seeds, nil
+}
+
+func (s Seed) validate() error {
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		return fmt.Errorf("invalid seed %q: %w", s.URL, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return fmt.Errorf("invalid seed %q: an absolute http(s) url is required", s.URL)
+	}
+	if s.MaxDepth < 0 {
+		return fmt.Errorf("invalid seed %q: negative depth", s.URL)
+	}
+	return nil
+}
+
+// Scope is the set of hosts the crawler stays in
+type Scope struct {
+	mu      sync.RWMutex
+	hosts   map[string]bool
+	domains []string
+}
+
+func NewScope() *Scope {
+	return &Scope{hosts: make(map[string]bool)}
+}
+
+// Scopes is the scope of a crawl. The hosts of the seeds are in scope for every page, the scope
+// overrides of a seed only for the pages reached from that seed.
+type Scopes struct {
+	hosts *Scope
+
+	mu    sync.RWMutex
+	seeds map[string]*Scope
+}
+
+func NewScopes() *Scopes {
+	return &Scopes{hosts: NewScope(), seeds: make(map[string]*Scope)}
+}
+
+// ScopesOf builds the scope of the seeds
+func ScopesOf(seeds []Seed) *Scopes {
+	scopes := NewScopes()
+	for _, s := range seeds {
+		scopes.Add(s)
+	}
+	return scopes
+}
+
+// Add includes the host of the seed and its scope overrides
+func (s *Scopes) Add(seed Seed) {
+	if u, err := url.Parse(seed.URL); err == nil {
+		s.hosts.Add(u.Hostname())
+	}
+	if len(seed.Scope) == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scope, ok := s.seeds[seed.URL]
+	if !ok {
+		scope = NewScope()
+		s.seeds[seed.URL] = scope
+	}
+	for _, host := range seed.Scope {
+		scope.Add(host)
+	}
+}
+
+// Contains tells whether the host is in scope for the pages reached from the seed url. An empty
+// seed url stands for any seed, e.g. for the urls queued by older versions, all the overrides apply.
+func (s *Scopes) Contains(host, seedURL string) bool {
+	if s.hosts.Contains(host) {
+		return true
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if seedURL != "" {
+		scope, ok := s.seeds[seedURL]
+		return ok && scope.Contains(host)
+	}
+	for _, scope := range s.seeds {
+		if scope.Contains(host) {
+			return true
+		}
+	}
+	return false
+}
+
+// Add includes the host, "*.example.com" includes example.com and all its subdomains
+func (s *Scope) Add(host string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	host = strings.ToLower(host)
+	if domain, ok := strings.CutPrefix(host, "*."); ok {
+		s.domains = append(s.domains, domain)
+		return
+	}
+	s.hosts[host] = true
+}
+
+func (s *Scope) Contains(host string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	host = strings.ToLower(host)
+	if s.hosts[host] {
+		return true
+	}
+	for _, domain := range s.domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
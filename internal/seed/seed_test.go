@@ -0,0 +1,80 @@
+package seed
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	var readTest = []struct {
+		name    string
+		input   string
+		want    []Seed
+		wantErr bool
+	}{
+		{
+			name:  "plain urls",
+			input: "http://example.com/\n\n# comment\n  https://example.org/a  \n",
+			want:  []Seed{{URL: "http://example.com/"}, {URL: "https://example.org/a"}},
+		},
+		{
+			name:  "jsonl with overrides",
+			input: "http://example.com/\n{\"url\": \"https://docs.example.com/\", \"depth\": 2, \"scope\": [\"*.cdn.com\"]}\n",
+			want: []Seed{
+				{URL: "http://example.com/"},
+				{URL: "https://docs.example.com/", MaxDepth: 2, Scope: []string{"*.cdn.com"}},
+			},
+		},
+		{name: "relative url", input: "/index.html\n", wantErr: true},
+		{name: "broken json", input: "{\"url\": \n", wantErr: true},
+		{name: "negative depth", input: "{\"url\": \"http://example.com/\", \"depth\": -1}\n", wantErr: true},
+	}
+
+	for _, tt := range readTest {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Read(strings.NewReader(tt.input))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScope(t *testing.T) {
+	scope := ScopesOf([]Seed{
+		{URL: "http://Example.com:8080/"},
+		{URL: "https://docs.site.org/", Scope: []string{"cdn.site.org", "*.other.net"}},
+	})
+
+	var scopeTest = []struct {
+		host string
+		// fromDocs is the scope of the pages reached from the seed with the overrides,
+		// fromExample the scope of the pages of the other seed
+		fromDocs, fromExample bool
+	}{
+		{host: "example.com", fromDocs: true, fromExample: true},
+		{host: "www.example.com"},
+		{host: "docs.site.org", fromDocs: true, fromExample: true},
+		{host: "cdn.site.org", fromDocs: true},
+		{host: "site.org"},
+		{host: "other.net", fromDocs: true},
+		{host: "a.b.other.net", fromDocs: true},
+		{host: "evilother.net"},
+	}
+	for _, tt := range scopeTest {
+		if got := scope.Contains(tt.host, "https://docs.site.org/"); got != tt.fromDocs {
+			t.Errorf("%s: got %v, want %v", tt.host, got, tt.fromDocs)
+		}
+		if got := scope.Contains(tt.host, "http://Example.com:8080/"); got != tt.fromExample {
+			t.Errorf("%s: got %v from the other seed, want %v", tt.host, got, tt.fromExample)
+		}
+		// an unknown seed gets the overrides of all the seeds
+		if got := scope.Contains(tt.host, ""); got != tt.fromDocs {
+			t.Errorf("%s: got %v from an unknown seed, want %v", tt.host, got, tt.fromDocs)
+		}
+	}
+}
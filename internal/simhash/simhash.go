@@ -0,0 +1,120 @@
+package simhash
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// shingleSize is the number of consecutive words hashed together, so the word order matters
+const shingleSize = 3
+
+// Fingerprint computes the 64-bit SimHash of the text over word shingles.
+// Texts differing in a few words (dates, counters, ads) get fingerprints a few bits apart.
+func Fingerprint(text string) uint64 {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(words) == 0 {
+		return 0
+	}
+
+	var weights [64]int
+	size := shingleSize
+	if len(words) < size {
+		size = len(words)
+	}
+	for i := 0; i+size <= len(words); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words[i:i+size], " ")))
+		sum := h.Sum64()
+		for bit := 0; bit < 64; bit++ {
+			if sum&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	var fingerprint uint64
+	for bit := 0; bit < 64; bit++ {
+		if weights[bit] > 0 {
+			fingerprint |= 1 << bit
+		}
+	}
+	return fingerprint
+}
+
+// Distance is the number of differing bits
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// blocks is the number of 16-bit parts the fingerprint is split into. Two fingerprints within
+// blocks-1 bits share at least one part exactly, so only the pages sharing a part are compared.
+const blocks = 4
+
+// MaxDistance is the biggest distance the Index finds reliably
+const MaxDistance = blocks - 1
+
+type entry struct {
+	url         string
+	fingerprint uint64
+}
+
+// Index finds the fingerprints within the distance of a new one
+type Index struct {
+	maxDistance int
+	mu          sync.Mutex
+	tables      [blocks]map[uint16][]entry
+}
+
+func NewIndex(maxDistance int) *Index {
+	if maxDistance > MaxDistance {
+		maxDistance = MaxDistance
+	}
+	idx := &Index{maxDistance: maxDistance}
+	for i := range idx.tables {
+		idx.tables[i] = make(map[uint16][]entry)
+	}
+	return idx
+}
+
+// Add returns the closest known url within the distance and adds the fingerprint to the index
+func (idx *Index) Add(url string, fingerprint uint64) (string, int, bool) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	nearest, nearestDistance := "", idx.maxDistance+1
+	for i := range idx.tables {
+		for _, e := range idx.tables[i][block(fingerprint, i)] {
+			if d := Distance(e.fingerprint, fingerprint); d < nearestDistance && e.url != url {
+				nearest, nearestDistance = e.url, d
+			}
+		}
+	}
+	idx.insert(url, fingerprint)
+
+	return nearest, nearestDistance, nearest != ""
+}
+
+// Insert adds the fingerprint without the lookup, e.g. when the index is restored on resume
+func (idx *Index) Insert(url string, fingerprint uint64) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.insert(url, fingerprint)
+}
+
+func (idx *Index) insert(url string, fingerprint uint64) {
+	for i := range idx.tables {
+		b := block(fingerprint, i)
+		idx.tables[i][b] = append(idx.tables[i][b], entry{url: url, fingerprint: fingerprint})
+	}
+}
+
+func block(fingerprint uint64, i int) uint16 {
+	return uint16(fingerprint >> (16 * i))
+}
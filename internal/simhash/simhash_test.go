@@ -0,0 +1,57 @@
+package simhash
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func article(date string) string {
+	return "Quarterly report " + date + ". " + strings.Repeat("Revenue grew in every region while costs stayed flat and the outlook remains positive for the next year. ", 10) +
+		"Our team shipped twelve features, fixed forty bugs and onboarded three new customers in the northern market."
+}
+
+func TestFingerprint(t *testing.T) {
+	base := Fingerprint(article("2024-01-01"))
+	if d := Distance(base, Fingerprint(article("2024-01-01"))); d != 0 {
+		t.Errorf("same text got distance %d", d)
+	}
+	if d := Distance(base, Fingerprint(article("2024-03-15"))); d > MaxDistance {
+		t.Errorf("text with another date got distance %d", d)
+	}
+	if d := Distance(base, Fingerprint("A completely different page about gardening, tomatoes and the best time to plant them in spring.")); d <= MaxDistance {
+		t.Errorf("different text got distance %d", d)
+	}
+	if Fingerprint("") != 0 {
+		t.Errorf("empty text should have zero fingerprint")
+	}
+}
+
+func TestIndex(t *testing.T) {
+	idx := NewIndex(MaxDistance)
+	base := uint64(0xdeadbeefcafebabe)
+
+	if _, _, found := idx.Add("http://example.com/a", base); found {
+		t.Errorf("empty index found a match")
+	}
+	for bit := 1; bit <= MaxDistance; bit++ {
+		fp := base
+		// the flipped bits are spread over all the blocks
+		for i := 0; i < bit; i++ {
+			fp ^= 1 << (i*16 + 3)
+		}
+		of, distance, found := idx.Add(fmt.Sprintf("http://example.com/%d", bit), fp)
+		if !found || distance > bit {
+			t.Errorf("%d bits away: got %s, %d, %v", bit, of, distance, found)
+		}
+	}
+	if of, _, found := idx.Add("http://example.com/far", ^base); found {
+		t.Errorf("inverted fingerprint matched %s", of)
+	}
+
+	refetched := NewIndex(MaxDistance)
+	refetched.Insert("http://example.com/a", base)
+	if _, _, found := refetched.Add("http://example.com/a", base); found {
+		t.Errorf("the page matched itself")
+	}
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	bolt "go.etcd.io/bbolt"
+	"sync"
+)
+
+const blacklistBucketName = "blacklist"
+
+// BlacklistRepository is the persistent counterpart of Hashlist. Like the seen set it keeps only
+// a Bloom filter in memory, so the links which are not blacklisted are answered without the disk.
+type BlacklistRepository struct {
+	db     *bolt.DB
+	filter *BloomFilter
+	mu     sync.Mutex
+	size   int
+}
+
+func NewBlacklistRepository(db *bolt.DB, expected uint64, fpRate float64) (*BlacklistRepository, error) {
+	br := &BlacklistRepository{db: db, filter: NewBloomFilter(expected, fpRate)}
+	err := db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(blacklistBucketName))
+		if err != nil {
+			return err
+		}
+		return bucket.ForEach(func(k, _ []byte) error {
+			br.filter.Add(k)
+			br.size++
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return br, nil
+}
+
+// AddWithReason lists the url or replaces its reason and tells whether anything changed. The urls
+// listed with the same reason again, e.g. the external links of every page, cost no write.
+func (br *BlacklistRepository) AddWithReason(val string, reason string) (bool, error) {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+
+	key := []byte(val)
+	listed := false
+	if br.filter.MayContain(key) {
+		var stored []byte
+		err := br.db.View(func(tx *bolt.Tx) error {
+			if v := tx.Bucket([]byte(blacklistBucketName)).Get(key); v != nil {
+				listed, stored = true, append([]byte(nil), v...)
+			}
+			return nil
+		})
+		if err != nil {
+			return false, err
+		}
+		if listed && string(stored) == reason {
+			return false, nil
+		}
+	}
+	err := br.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(blacklistBucketName)).Put(key, []byte(reason))
+	})
+	if err != nil {
+		return false, err
+	}
+	if !listed {
+		br.size++
+	}
+	br.filter.Add(key)
+	return true, nil
+}
+
+func (br *BlacklistRepository) RemoveFromList(val string) error {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+
+	removed := false
+	err := br.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(blacklistBucketName))
+		if bucket.Get([]byte(val)) == nil {
+			return nil
+		}
+		removed = true
+		return bucket.Delete([]byte(val))
+	})
+	if removed && err == nil {
+		br.size--
+	}
+	return err
+}
+
+func (br *BlacklistRepository) DoesExist(url string) bool {
+	_, ok := br.Reason(url)
+	return ok
+}
+
+// Reason returns why the url is blacklisted
+func (br *BlacklistRepository) Reason(url string) (string, bool) {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+
+	key := []byte(url)
+	if !br.filter.MayContain(key) {
+		return "", false
+	}
+	var reason string
+	var found bool
+	_ = br.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket([]byte(blacklistBucketName)).Get(key)
+		reason, found = string(v), v != nil
+		return nil
+	})
+	return reason, found
+}
+
+// Entries returns the whole list, url to reason
+func (br *BlacklistRepository) Entries() map[string]string {
+	entries := make(map[string]string)
+	_ = br.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(blacklistBucketName)).ForEach(func(k, v []byte) error {
+			entries[string(k)] = string(v)
+			return nil
+		})
+	})
+	return entries
+}
+
+// ForEachEntry walks the urls with the reasons in url order without copying the list, until fn returns an error
+func (br *BlacklistRepository) ForEachEntry(fn func(url, reason string) error) error {
+	return br.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte(blacklistBucketName)).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if err := fn(string(k), string(v)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (br *BlacklistRepository) Size() int {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+	return br.size
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBlacklistRepository(t *testing.T) {
+	db := openTestDB(t)
+	br, err := NewBlacklistRepository(db, 100, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var addTest = []struct {
+		url, reason string
+		wantChanged bool
+	}{
+		{url: "http://example.com/a", reason: "fetch error", wantChanged: true},
+		{url: "http://example.com/b", reason: "", wantChanged: true},
+		{url: "http://example.com/a", reason: "trap: depth", wantChanged: true},
+		{url: "http://example.com/a", reason: "trap: depth", wantChanged: false},
+		{url: "http://example.com/b", reason: "", wantChanged: false},
+	}
+	for _, tt := range addTest {
+		changed, err := br.AddWithReason(tt.url, tt.reason)
+		if err != nil || changed != tt.wantChanged {
+			t.Errorf("%s %q: got changed %t, %v, want %t", tt.url, tt.reason, changed, err, tt.wantChanged)
+		}
+	}
+	br.RemoveFromList("http://example.com/missing")
+
+	if br.Size() != 2 {
+		t.Errorf("got size %d, want 2", br.Size())
+	}
+	if reason, ok := br.Reason("http://example.com/a"); !ok || reason != "trap: depth" {
+		t.Errorf("got %q, %v", reason, ok)
+	}
+	if !br.DoesExist("http://example.com/b") {
+		t.Error("the url with an empty reason is missing")
+	}
+
+	var walked []string
+	if err := br.ForEachEntry(func(url, reason string) error {
+		walked = append(walked, url+"="+reason)
+		return nil
+	}); err != nil || strings.Join(walked, " ") != "http://example.com/a=trap: depth http://example.com/b=" {
+		t.Errorf("got %v, %v", walked, err)
+	}
+
+	br.RemoveFromList("http://example.com/a")
+	if br.DoesExist("http://example.com/a") {
+		t.Error("the removed url exists")
+	}
+
+	// the list is restored from the database
+	br, err = NewBlacklistRepository(db, 100, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if br.Size() != 1 || !br.DoesExist("http://example.com/b") {
+		t.Errorf("got %v after the reload", br.Entries())
+	}
+}
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+)
+
+// BloomFilter is a fixed size set answering "definitely not added" or "maybe added".
+// It never grows, the false positive rate goes up once more than the expected items are added.
+type BloomFilter struct {
+	bits   []uint64
+	m      uint64
+	hashes int
+}
+
+// NewBloomFilter sizes the filter for the expected number of items at the false positive rate
+func NewBloomFilter(expected uint64, fpRate float64) *BloomFilter {
+	if expected == 0 {
+		expected = 1
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		fpRate = 0.01
+	}
+	m := uint64(math.Ceil(-float64(expected) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	m = (m + 63) / 64 * 64
+	hashes := int(math.Round(float64(m) / float64(expected) * math.Ln2))
+	if hashes < 1 {
+		hashes = 1
+	}
+	return &BloomFilter{bits: make([]uint64, m/64), m: m, hashes: hashes}
+}
+
+func (bf *BloomFilter) Add(item []byte) {
+	h1, h2 := bloomHashes(item)
+	for i := 0; i < bf.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % bf.m
+		bf.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+func (bf *BloomFilter) MayContain(item []byte) bool {
+	h1, h2 := bloomHashes(item)
+	for i := 0; i < bf.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % bf.m
+		if bf.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// SizeBytes is the memory taken by the bits
+func (bf *BloomFilter) SizeBytes() int {
+	return len(bf.bits) * 8
+}
+
+// bloomHashes splits a 128-bit FNV hash into the two hashes of the double hashing scheme
+func bloomHashes(item []byte) (uint64, uint64) {
+	h := fnv.New128a()
+	h.Write(item)
+	sum := h.Sum(nil)
+	// an odd step visits every bit position before repeating
+	return binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:]) | 1
+}
@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	bolt "go.etcd.io/bbolt"
+	"sort"
+)
+
+// Bodies are stored once per SHA-256 digest. The links bucket keeps a reference record:
+// the bodyFormatRef byte followed by the raw digest.
+const (
+	bodiesBucketName   = "bodies"
+	bodyRefsBucketName = "body_refs"
+)
+
+const bodyFormatRef byte = 0x02
+
+// BodyDigest is the hex SHA-256 of the body, the key of the body in the store
+func BodyDigest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func bodyRef(digest []byte) []byte {
+	return append([]byte{bodyFormatRef}, digest...)
+}
+
+func isBodyRef(stored []byte) bool {
+	return len(stored) == sha256.Size+1 && stored[0] == bodyFormatRef
+}
+
+// putBody links the url to the body, storing the body only when its digest is new.
+// The body previously linked to the url loses a reference and is deleted with the last one.
+func putBody(tx *bolt.Tx, url []byte, data []byte) error {
+	links := tx.Bucket([]byte(linksBucketName))
+	bodies := tx.Bucket([]byte(bodiesBucketName))
+	refs := tx.Bucket([]byte(bodyRefsBucketName))
+
+	sum := sha256.Sum256(data)
+	digest := sum[:]
+	if old := links.Get(url); isBodyRef(old) {
+		if string(old[1:]) == string(digest) {
+			return nil
+		}
+		if err := releaseBody(bodies, refs, old[1:]); err != nil {
+			return err
+		}
+	}
+
+	count := refCount(refs, digest)
+	if count == 0 {
+		stored, err := compressBody(data)
+		if err != nil {
+			return err
+		}
+		if err := bodies.Put(digest, stored); err != nil {
+			return err
+		}
+	}
+	if err := setRefCount(refs, digest, count+1); err != nil {
+		return err
+	}
+	return links.Put(url, bodyRef(digest))
+}
+
+func getBody(tx *bolt.Tx, stored []byte) ([]byte, error) {
+	if !isBodyRef(stored) {
+		return nil, fmt.Errorf("broken body reference %x", stored)
+	}
+	body := tx.Bucket([]byte(bodiesBucketName)).Get(stored[1:])
+	if body == nil {
+		return nil, fmt.Errorf("body %x is missing", stored[1:])
+	}
+	return decompressBody(body)
+}
+
+func releaseBody(bodies, refs *bolt.Bucket, digest []byte) error {
+	count := refCount(refs, digest)
+	if count <= 1 {
+		if err := refs.Delete(digest); err != nil {
+			return err
+		}
+		return bodies.Delete(digest)
+	}
+	return setRefCount(refs, digest, count-1)
+}
+
+func refCount(refs *bolt.Bucket, digest []byte) uint64 {
+	v := refs.Get(digest)
+	if len(v) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(v)
+}
+
+func setRefCount(refs *bolt.Bucket, digest []byte, count uint64) error {
+	v := make([]byte, 8)
+	binary.BigEndian.PutUint64(v, count)
+	return refs.Put(digest, v)
+}
+
+// DuplicateCluster is a group of urls sharing the identical body
+type DuplicateCluster struct {
+	Digest string
+	Size   int
+	URLs   []string
+}
+
+// DuplicateClusters returns the bodies referenced by more than one url, the biggest clusters first
+func (lr *LinkRepository) DuplicateClusters() ([]DuplicateCluster, error) {
+	clusters := make(map[string]*DuplicateCluster)
+	err := lr.db.View(func(tx *bolt.Tx) error {
+		refs := tx.Bucket([]byte(bodyRefsBucketName))
+		return tx.Bucket([]byte(linksBucketName)).ForEach(func(k, v []byte) error {
+			if !isBodyRef(v) || refCount(refs, v[1:]) < 2 {
+				return nil
+			}
+			digest := hex.EncodeToString(v[1:])
+			cluster, ok := clusters[digest]
+			if !ok {
+				body, err := getBody(tx, v)
+				if err != nil {
+					return err
+				}
+				cluster = &DuplicateCluster{Digest: digest, Size: len(body)}
+				clusters[digest] = cluster
+			}
+			cluster.URLs = append(cluster.URLs, string(k))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]DuplicateCluster, 0, len(clusters))
+	for _, c := range clusters {
+		result = append(result, *c)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if len(result[i].URLs) != len(result[j].URLs) {
+			return len(result[i].URLs) > len(result[j].URLs)
+		}
+		return result[i].Digest < result[j].Digest
+	})
+	return result, nil
+}
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// Every stored body starts with a single format byte
+const (
+	bodyFormatRaw  byte = 0x00
+	bodyFormatGzip byte = 0x01
+)
+
+// minCompressSize is the body size below which the gzip overhead is not worth it
+const minCompressSize = 256
+
+func compressBody(data []byte) ([]byte, error) {
+	if len(data) >= minCompressSize {
+		var buf bytes.Buffer
+		buf.WriteByte(bodyFormatGzip)
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		// images and archives are already compressed, keep them as they are
+		if buf.Len() < len(data)+1 {
+			return buf.Bytes(), nil
+		}
+	}
+
+	return append([]byte{bodyFormatRaw}, data...), nil
+}
+
+func decompressBody(stored []byte) ([]byte, error) {
+	if len(stored) == 0 {
+		return stored, nil
+	}
+
+	switch stored[0] {
+	case bodyFormatRaw:
+		return append([]byte(nil), stored[1:]...), nil
+	case bodyFormatGzip:
+		r, err := gzip.NewReader(bytes.NewReader(stored[1:]))
+		if err != nil {
+			return nil, fmt.Errorf("corrupted gzip body: %w", err)
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	default:
+		return nil, fmt.Errorf("unknown body format %d", stored[0])
+	}
+}
@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"encoding/json"
+	bolt "go.etcd.io/bbolt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// CookieRepository is an http.CookieJar which keeps every received cookie in the database,
+// so a resumed crawl continues with the same sessions
+type CookieRepository struct {
+	db  *bolt.DB
+	jar *cookiejar.Jar
+	mu  sync.Mutex
+}
+
+const cookiesBucketName = "cookies"
+
+func NewCookieRepository(db *bolt.DB) (*CookieRepository, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(cookiesBucketName))
+		if err != nil {
+			return err
+		}
+		// keys are origins (scheme://host), the jar needs an url to apply the domain and path rules
+		return b.ForEach(func(k, v []byte) error {
+			u, err := url.Parse(string(k))
+			if err != nil {
+				return nil
+			}
+			var cookies []*http.Cookie
+			if err := json.Unmarshal(v, &cookies); err != nil {
+				return err
+			}
+			jar.SetCookies(u, cookies)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &CookieRepository{db: db, jar: jar}, nil
+}
+
+func (cr *CookieRepository) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	cr.jar.SetCookies(u, cookies)
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	origin := []byte(u.Scheme + "://" + u.Host)
+	_ = cr.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(cookiesBucketName))
+		var stored []*http.Cookie
+		if data := bucket.Get(origin); data != nil {
+			if err := json.Unmarshal(data, &stored); err != nil {
+				stored = nil
+			}
+		}
+		stored = mergeCookies(stored, cookies, time.Now())
+		if len(stored) == 0 {
+			return bucket.Delete(origin)
+		}
+		data, err := json.Marshal(stored)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(origin, data)
+	})
+}
+
+func (cr *CookieRepository) Cookies(u *url.URL) []*http.Cookie {
+	return cr.jar.Cookies(u)
+}
+
+// mergeCookies replaces the stored cookies with the received ones and drops the expired.
+// Max-Age is relative to the moment of receiving, so it is turned into an absolute expiry.
+func mergeCookies(stored, received []*http.Cookie, now time.Time) []*http.Cookie {
+	for _, c := range received {
+		c := *c
+		if c.MaxAge > 0 {
+			c.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+			c.MaxAge = 0
+		}
+		replaced := false
+		for i := range stored {
+			if stored[i].Name == c.Name && stored[i].Path == c.Path && stored[i].Domain == c.Domain {
+				stored[i] = &c
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			stored = append(stored, &c)
+		}
+	}
+
+	alive := stored[:0]
+	for _, c := range stored {
+		if c.MaxAge < 0 || (!c.Expires.IsZero() && c.Expires.Before(now)) {
+			continue
+		}
+		alive = append(alive, c)
+	}
+	return alive
+}
@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestCookieRepositoryResume(t *testing.T) {
+	db := openTestDB(t)
+	u, _ := url.Parse("https://staging.example.com/app")
+
+	cr, err := NewCookieRepository(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cr.SetCookies(u, []*http.Cookie{
+		{Name: "session", Value: "first", Path: "/"},
+		{Name: "short", Value: "lived", Path: "/", MaxAge: 3600},
+	})
+	cr.SetCookies(u, []*http.Cookie{
+		{Name: "session", Value: "second", Path: "/"},
+		{Name: "short", Path: "/", MaxAge: -1},
+	})
+
+	resumed, err := NewCookieRepository(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookies := resumed.Cookies(u)
+	if len(cookies) != 1 || cookies[0].Name != "session" || cookies[0].Value != "second" {
+		t.Errorf("got %v, want only the refreshed session cookie", cookies)
+	}
+}
@@ -0,0 +1,487 @@
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	bolt "go.etcd.io/bbolt"
+	"math"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// QueueItem is a url waiting to be fetched together with what is known about how it was found
+type QueueItem struct {
+	URL    string `json:"url"`
+	Depth  int    `json:"depth"`
+	Source string `json:"source"`
+	// MaxDepth is inherited from the seed, zero means no limit
+	MaxDepth int `json:"max_depth,omitempty"`
+	// Priority is added to the score, it is set by hand, e.g. with the control API
+	Priority float64 `json:"priority,omitempty"`
+	// Seed is the url of the seed the item was reached from, the scope overrides of the seed apply to it
+	Seed string `json:"seed,omitempty"`
+}
+
+var ErrEmptyQueue = errors.New("queue is empty")
+
+// Scorer gives the priority of the item, hostCount is the number of urls of the host waiting before it
+type Scorer interface {
+	Score(item QueueItem, hostCount int) float64
+}
+
+const (
+	frontierBucketName     = "frontier"
+	frontierURLsBucketName = "frontier_urls"
+	// frontierDeferredBucketName holds the deferred items keyed by the time they are due, see Defer
+	frontierDeferredBucketName = "frontier_deferred"
+	// frontierHostsBucketName held the pushed urls by host, the counts are kept in memory now
+	frontierHostsBucketName = "frontier_hosts"
+)
+
+// The FIFO queue of the older versions, the whole queue was saved under queueData on stop
+const (
+	queueBucketName = "queue"
+	queueData       = "data"
+)
+
+// FrontierRepository is a priority queue kept entirely in the database. Keys of the frontier bucket
+// are the inverted score followed by a sequence number, so the first key is always the best item
+// and items of the same score come out in FIFO order. Nothing but the sizes are kept in memory,
+// they change only once the transaction is committed.
+// The urls index points to the key of the item, the keys of the deferred items start with deferredMark.
+type FrontierRepository struct {
+	db     *bolt.DB
+	scorer Scorer
+	mu     sync.Mutex
+	// size counts the deferred items too
+	size     int
+	deferred int
+	// queued is the number of the waiting urls by host
+	queued map[string]int
+	// seen is written together with the pushed urls by PushNew
+	seen *SeenSet
+}
+
+func NewFrontierRepository(db *bolt.DB, scorer Scorer) (*FrontierRepository, error) {
+	fr := &FrontierRepository{db: db, scorer: scorer, queued: make(map[string]int)}
+	err := db.Update(func(tx *bolt.Tx) error {
+		for _, name := range []string{frontierBucketName, frontierURLsBucketName, frontierDeferredBucketName} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return err
+			}
+		}
+		if tx.Bucket([]byte(frontierHostsBucketName)) != nil {
+			if err := tx.DeleteBucket([]byte(frontierHostsBucketName)); err != nil {
+				return err
+			}
+		}
+		err := tx.Bucket([]byte(frontierURLsBucketName)).ForEach(func(k, _ []byte) error {
+			fr.size++
+			fr.queued[hostOf(string(k))]++
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		fr.deferred = tx.Bucket([]byte(frontierDeferredBucketName)).Stats().KeyN
+		return fr.migrateQueue(tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return fr, nil
+}
+
+// migrateQueue moves the urls of the FIFO queue of the older versions to the frontier: the urls
+// saved on the last stop are a JSON list under queueData, the ones pushed since then are the keys
+func (fr *FrontierRepository) migrateQueue(tx *bolt.Tx) error {
+	legacy := tx.Bucket([]byte(queueBucketName))
+	if legacy == nil {
+		return nil
+	}
+	var urls []string
+	if data := legacy.Get([]byte(queueData)); data != nil {
+		if err := json.Unmarshal(data, &urls); err != nil {
+			return fmt.Errorf("broken queue of the older version: %w", err)
+		}
+	}
+	err := legacy.ForEach(func(k, _ []byte) error {
+		if string(k) != queueData {
+			urls = append(urls, string(k))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, u := range urls {
+		host := hostOf(u)
+		added, err := fr.push(tx, QueueItem{URL: u}, fr.queued[host])
+		if err != nil {
+			return err
+		}
+		if added {
+			fr.size++
+			fr.queued[host]++
+		}
+	}
+	return tx.DeleteBucket([]byte(queueBucketName))
+}
+
+// Push adds the item, urls already waiting in the frontier are ignored
+func (fr *FrontierRepository) Push(item QueueItem) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	host := hostOf(item.URL)
+	added := false
+	err := fr.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		added, err = fr.push(tx, item, fr.queued[host])
+		return err
+	})
+	if added && err == nil {
+		fr.size++
+		fr.queued[host]++
+	}
+	return err
+}
+
+// SetSeenSet gives PushNew the seen set to mark the pushed urls in, it has to be kept in the same database
+func (fr *FrontierRepository) SetSeenSet(seen *SeenSet) {
+	fr.seen = seen
+}
+
+// PushNew marks the url as seen and pushes the item in one transaction, so a new link costs a single
+// write. It tells whether the url was new, the urls seen before are not pushed.
+func (fr *FrontierRepository) PushNew(item QueueItem) (bool, error) {
+	if fr.seen == nil {
+		return false, errors.New("no seen set to mark the url in")
+	}
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	host := hostOf(item.URL)
+	added := false
+	isNew, err := fr.seen.addWith(item.URL, func(tx *bolt.Tx) error {
+		var err error
+		added, err = fr.push(tx, item, fr.queued[host])
+		return err
+	})
+	if err != nil || !isNew {
+		return false, err
+	}
+	if added {
+		fr.size++
+		fr.queued[host]++
+	}
+	return true, nil
+}
+
+// push stores the item in the transaction and tells whether the url was not waiting yet
+func (fr *FrontierRepository) push(tx *bolt.Tx, item QueueItem, hostCount int) (bool, error) {
+	urls := tx.Bucket([]byte(frontierURLsBucketName))
+	if urls.Get([]byte(item.URL)) != nil {
+		return false, nil
+	}
+
+	frontier := tx.Bucket([]byte(frontierBucketName))
+	seq, err := frontier.NextSequence()
+	if err != nil {
+		return false, err
+	}
+	key := frontierKey(fr.scorer.Score(item, hostCount), seq)
+	data, err := json.Marshal(item)
+	if err != nil {
+		return false, err
+	}
+	if err := frontier.Put(key, data); err != nil {
+		return false, err
+	}
+	return true, urls.Put([]byte(item.URL), key)
+}
+
+// Pull removes and returns the item with the highest score. The deferred items which are due are scored
+// again first, ErrEmptyQueue is returned while only the deferred items which are not due yet wait.
+func (fr *FrontierRepository) Pull() (QueueItem, error) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	var item QueueItem
+	due := 0
+	err := fr.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		if due, err = fr.undefer(tx, time.Now()); err != nil {
+			return err
+		}
+		c := tx.Bucket([]byte(frontierBucketName)).Cursor()
+		k, v := c.First()
+		if k == nil {
+			return ErrEmptyQueue
+		}
+		if err := json.Unmarshal(v, &item); err != nil {
+			return err
+		}
+		if err := c.Delete(); err != nil {
+			return err
+		}
+		return tx.Bucket([]byte(frontierURLsBucketName)).Delete([]byte(item.URL))
+	})
+	if err != nil {
+		// nothing was due when the frontier is empty, the transaction is rolled back anyway
+		return QueueItem{}, err
+	}
+	fr.deferred -= due
+	fr.size--
+	fr.dequeued(item.URL)
+	return item, nil
+}
+
+// Defer keeps the item aside until notBefore, e.g. while its host is busy, then it is scored again
+// among the others. The item stays in the database all along, so a restart does not lose it.
+func (fr *FrontierRepository) Defer(item QueueItem, notBefore time.Time) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	added := false
+	err := fr.db.Update(func(tx *bolt.Tx) error {
+		urls := tx.Bucket([]byte(frontierURLsBucketName))
+		if urls.Get([]byte(item.URL)) != nil {
+			return nil
+		}
+		deferred := tx.Bucket([]byte(frontierDeferredBucketName))
+		seq, err := deferred.NextSequence()
+		if err != nil {
+			return err
+		}
+		key := make([]byte, 16)
+		binary.BigEndian.PutUint64(key, uint64(notBefore.UnixNano()))
+		binary.BigEndian.PutUint64(key[8:], seq)
+		data, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		if err := deferred.Put(key, data); err != nil {
+			return err
+		}
+		added = true
+		return urls.Put([]byte(item.URL), append([]byte{deferredMark}, key...))
+	})
+	if added && err == nil {
+		fr.size++
+		fr.deferred++
+		fr.queued[hostOf(item.URL)]++
+	}
+	return err
+}
+
+// Deferred is the number of the deferred items, due or not
+func (fr *FrontierRepository) Deferred() int {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	return fr.deferred
+}
+
+// undefer moves the deferred items due at now back to the frontier and returns how many
+func (fr *FrontierRepository) undefer(tx *bolt.Tx, now time.Time) (int, error) {
+	urls := tx.Bucket([]byte(frontierURLsBucketName))
+	c := tx.Bucket([]byte(frontierDeferredBucketName)).Cursor()
+	moved := 0
+	for k, v := c.First(); k != nil && int64(binary.BigEndian.Uint64(k)) <= now.UnixNano(); k, v = c.First() {
+		var item QueueItem
+		if err := json.Unmarshal(v, &item); err != nil {
+			return moved, err
+		}
+		if err := c.Delete(); err != nil {
+			return moved, err
+		}
+		if err := urls.Delete([]byte(item.URL)); err != nil {
+			return moved, err
+		}
+		// the url is still counted among the waiting urls of its host, like for Reprioritize
+		if _, err := fr.push(tx, item, fr.queued[hostOf(item.URL)]-1); err != nil {
+			return moved, err
+		}
+		moved++
+	}
+	return moved, nil
+}
+
+// deferredMark starts the keys of the deferred items in the urls index
+const deferredMark = 'd'
+
+// locate returns the bucket and the key of the waiting url, the key is nil when it is not waiting
+func locate(tx *bolt.Tx, url string) (bucket *bolt.Bucket, key []byte, deferred bool) {
+	key = tx.Bucket([]byte(frontierURLsBucketName)).Get([]byte(url))
+	if key == nil {
+		return nil, nil, false
+	}
+	// the frontier keys are 16 bytes, the marked ones one more
+	if len(key) == 17 && key[0] == deferredMark {
+		return tx.Bucket([]byte(frontierDeferredBucketName)), key[1:], true
+	}
+	return tx.Bucket([]byte(frontierBucketName)), key, false
+}
+
+// List returns up to limit items in the order they are pulled, all of them when limit is 0.
+// The deferred items follow in the order they are due.
+func (fr *FrontierRepository) List(limit int) ([]QueueItem, error) {
+	var items []QueueItem
+	err := fr.db.View(func(tx *bolt.Tx) error {
+		for _, name := range []string{frontierBucketName, frontierDeferredBucketName} {
+			c := tx.Bucket([]byte(name)).Cursor()
+			for k, v := c.First(); k != nil && (limit <= 0 || len(items) < limit); k, v = c.Next() {
+				var item QueueItem
+				if err := json.Unmarshal(v, &item); err != nil {
+					return err
+				}
+				items = append(items, item)
+			}
+		}
+		return nil
+	})
+	return items, err
+}
+
+// Get returns the queued item of the url
+func (fr *FrontierRepository) Get(url string) (QueueItem, bool, error) {
+	var item QueueItem
+	found := false
+	err := fr.db.View(func(tx *bolt.Tx) error {
+		bucket, key, _ := locate(tx, url)
+		if key == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(bucket.Get(key), &item)
+	})
+	return item, found, err
+}
+
+// Remove drops the url from the frontier and tells whether it was queued
+func (fr *FrontierRepository) Remove(url string) (bool, error) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	removed := false
+	wasDeferred := false
+	err := fr.db.Update(func(tx *bolt.Tx) error {
+		bucket, key, deferred := locate(tx, url)
+		if key == nil {
+			return nil
+		}
+		if err := bucket.Delete(key); err != nil {
+			return err
+		}
+		removed, wasDeferred = true, deferred
+		return tx.Bucket([]byte(frontierURLsBucketName)).Delete([]byte(url))
+	})
+	if removed && err == nil {
+		fr.size--
+		if wasDeferred {
+			fr.deferred--
+		}
+		fr.dequeued(url)
+	}
+	return removed, err
+}
+
+// Reprioritize scores the waiting url again with the priority and tells whether it was queued.
+// The url stays in the frontier all along, it is moved within one transaction.
+func (fr *FrontierRepository) Reprioritize(url string, priority float64) (bool, error) {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	found := false
+	err := fr.db.Update(func(tx *bolt.Tx) error {
+		urls := tx.Bucket([]byte(frontierURLsBucketName))
+		bucket, key, deferred := locate(tx, url)
+		if key == nil {
+			return nil
+		}
+		var item QueueItem
+		if err := json.Unmarshal(bucket.Get(key), &item); err != nil {
+			return err
+		}
+		item.Priority = priority
+		found = true
+		if deferred {
+			// the deferred item is scored once it is due
+			data, err := json.Marshal(item)
+			if err != nil {
+				return err
+			}
+			return bucket.Put(key, data)
+		}
+		if err := bucket.Delete(key); err != nil {
+			return err
+		}
+		if err := urls.Delete([]byte(url)); err != nil {
+			return err
+		}
+		// the url is not counted among the waiting urls of its host, like when it was pushed
+		_, err := fr.push(tx, item, fr.queued[hostOf(url)]-1)
+		return err
+	})
+	return found && err == nil, err
+}
+
+func (fr *FrontierRepository) dequeued(url string) {
+	host := hostOf(url)
+	if fr.queued[host]--; fr.queued[host] <= 0 {
+		delete(fr.queued, host)
+	}
+}
+
+func (fr *FrontierRepository) Size() int {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	return fr.size
+}
+
+// SizeByHost returns the number of the waiting urls of every host
+func (fr *FrontierRepository) SizeByHost() map[string]int {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	sizes := make(map[string]int, len(fr.queued))
+	for host, n := range fr.queued {
+		sizes[host] = n
+	}
+	return sizes
+}
+
+// SaveState does nothing, every change is already committed
+func (fr *FrontierRepository) SaveState() {}
+
+// frontierKey orders the keys by the score descending, the float bits are mapped
+// so that the byte order follows the numeric order
+func frontierKey(score float64, seq uint64) []byte {
+	if score == 0 {
+		// the negative zero has the sign bit set, it is ordered as zero
+		score = 0
+	}
+	bits := math.Float64bits(score)
+	if score >= 0 {
+		bits ^= 1 << 63
+	} else {
+		bits = ^bits
+	}
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key, ^bits)
+	binary.BigEndian.PutUint64(key[8:], seq)
+	return key
+}
+
+// hostOf is the host name without the port, the key of the request metrics too
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
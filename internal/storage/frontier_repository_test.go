@@ -0,0 +1,328 @@
+package storage
+
+import (
+	"errors"
+	bolt "go.etcd.io/bbolt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// depthScorer prefers shallow items and penalizes crowded hosts, the priority set by hand is added
+type depthScorer struct{}
+
+func (depthScorer) Score(item QueueItem, hostCount int) float64 {
+	return item.Priority - float64(item.Depth) - float64(hostCount)/10
+}
+
+func TestFrontierRepositoryOrder(t *testing.T) {
+	fr, err := NewFrontierRepository(openTestDB(t), depthScorer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items := []QueueItem{
+		{URL: "http://a.com/deep", Depth: 3},
+		{URL: "http://a.com/", Depth: 0},
+		{URL: "http://a.com/1", Depth: 1},
+		{URL: "http://b.com/1", Depth: 1},
+		{URL: "http://a.com/", Depth: 0},
+		{URL: "http://c.com/1", Depth: 1},
+	}
+	for _, item := range items {
+		if err := fr.Push(item); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fr.Size() != 5 {
+		t.Errorf("got size %d, want 5", fr.Size())
+	}
+	if sizes := fr.SizeByHost(); sizes["a.com"] != 3 || sizes["b.com"] != 1 || sizes["c.com"] != 1 {
+		t.Errorf("got sizes by host %v", sizes)
+	}
+
+	want := []string{"http://a.com/", "http://b.com/1", "http://c.com/1", "http://a.com/1", "http://a.com/deep"}
+	for i := range want {
+		item, err := fr.Pull()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if item.URL != want[i] {
+			t.Errorf("pull %d: got %s, want %s", i, item.URL, want[i])
+		}
+	}
+	if _, err := fr.Pull(); !errors.Is(err, ErrEmptyQueue) {
+		t.Errorf("got %v, want ErrEmptyQueue", err)
+	}
+	if sizes := fr.SizeByHost(); len(sizes) != 0 {
+		t.Errorf("got sizes by host %v of the empty frontier", sizes)
+	}
+
+	// the host penalty counts the waiting urls only
+	for _, item := range []QueueItem{{URL: "http://a.com/again", Depth: 1}, {URL: "http://b.com/2", Depth: 1}} {
+		if err := fr.Push(item); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if item, _ := fr.Pull(); item.URL != "http://a.com/again" {
+		t.Errorf("got %s, the pulled urls still penalize their host", item.URL)
+	}
+}
+
+func TestFrontierRepositoryMigratesQueue(t *testing.T) {
+	// the older versions saved the queue as a JSON list on stop and kept the urls pushed since as keys
+	db := openTestDB(t)
+	err := db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte(queueBucketName))
+		if err != nil {
+			return err
+		}
+		if err := b.Put([]byte(queueData), []byte(`["http://a.com/1","http://b.com/1","http://a.com/2"]`)); err != nil {
+			return err
+		}
+		for _, url := range []string{"http://a.com/2", "http://c.com/1"} {
+			if err := b.Put([]byte(url), []byte{}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fr, err := NewFrontierRepository(db, depthScorer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fr.Size() != 4 {
+		t.Errorf("got size %d, want 4", fr.Size())
+	}
+	items, err := fr.List(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, item := range items {
+		got = append(got, item.URL)
+	}
+	if want := "http://a.com/1 http://b.com/1 http://c.com/1 http://a.com/2"; strings.Join(got, " ") != want {
+		t.Errorf("got %v, want %s", got, want)
+	}
+	_ = db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket([]byte(queueBucketName)) != nil {
+			t.Error("the old queue is kept")
+		}
+		return nil
+	})
+}
+
+func TestFrontierRepositoryPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fr, err := NewFrontierRepository(db, depthScorer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range []QueueItem{{URL: "http://a.com/2", Depth: 2, Source: "content"}, {URL: "http://a.com/1", Depth: 1, Source: "nav"}} {
+		if err := fr.Push(item); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	db, err = bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	fr, err = NewFrontierRepository(db, depthScorer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fr.Size() != 2 || fr.SizeByHost()["a.com"] != 2 {
+		t.Fatalf("got size %d, %v by host, want 2", fr.Size(), fr.SizeByHost())
+	}
+	item, err := fr.Pull()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item != (QueueItem{URL: "http://a.com/1", Depth: 1, Source: "nav"}) {
+		t.Errorf("got %+v", item)
+	}
+}
+
+func TestFrontierKeyOrder(t *testing.T) {
+	scores := []float64{100, 1.5, 0, -0.5, -100}
+	for i := 1; i < len(scores); i++ {
+		if string(frontierKey(scores[i-1], 1)) >= string(frontierKey(scores[i], 0)) {
+			t.Errorf("key of %v does not sort before the key of %v", scores[i-1], scores[i])
+		}
+	}
+	if string(frontierKey(math.Copysign(0, -1), 1)) != string(frontierKey(0, 1)) {
+		t.Errorf("negative zero is not ordered as zero")
+	}
+}
+
+func TestFrontierRepositoryPushNew(t *testing.T) {
+	db := openTestDB(t)
+	fr, err := NewFrontierRepository(db, depthScorer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fr.PushNew(QueueItem{URL: "http://a.com/"}); err == nil {
+		t.Error("pushed without a seen set")
+	}
+	ss, err := NewSeenSet(db, 1000, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fr.SetSeenSet(ss)
+
+	var pushTest = []struct {
+		url     string
+		wantNew bool
+	}{
+		{url: "http://a.com/", wantNew: true},
+		{url: "http://a.com/1", wantNew: true},
+		{url: "http://a.com/", wantNew: false},
+	}
+	for _, tt := range pushTest {
+		added, err := fr.PushNew(QueueItem{URL: tt.url})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if added != tt.wantNew {
+			t.Errorf("%s: got new %t, want %t", tt.url, added, tt.wantNew)
+		}
+	}
+	if fr.Size() != 2 || !ss.Contains("http://a.com/1") {
+		t.Errorf("got size %d, seen %t", fr.Size(), ss.Contains("http://a.com/1"))
+	}
+
+	// a pulled url stays seen
+	if _, err := fr.Pull(); err != nil {
+		t.Fatal(err)
+	}
+	if added, _ := fr.PushNew(QueueItem{URL: "http://a.com/"}); added || fr.Size() != 1 {
+		t.Errorf("the seen url is pushed again")
+	}
+}
+
+func TestFrontierRepositoryReprioritize(t *testing.T) {
+	fr, err := NewFrontierRepository(openTestDB(t), depthScorer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range []QueueItem{{URL: "http://a.com/1", Depth: 1}, {URL: "http://a.com/2", Depth: 2}, {URL: "http://b.com/3", Depth: 3}} {
+		if err := fr.Push(item); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if found, err := fr.Reprioritize("http://a.com/2", 1.5); !found || err != nil {
+		t.Fatalf("got %t, %v", found, err)
+	}
+	if found, err := fr.Reprioritize("http://c.com/", 1); found || err != nil {
+		t.Errorf("got %t, %v for a url which is not queued", found, err)
+	}
+	if fr.Size() != 3 || fr.SizeByHost()["a.com"] != 2 {
+		t.Errorf("got size %d, %v by host", fr.Size(), fr.SizeByHost())
+	}
+
+	// a.com/2 scores 1.5-2-0.1, the url itself is not counted for its host
+	var got []string
+	for fr.Size() > 0 {
+		item, err := fr.Pull()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, item.URL)
+	}
+	if want := "http://a.com/2 http://a.com/1 http://b.com/3"; strings.Join(got, " ") != want {
+		t.Errorf("got %v, want %s", got, want)
+	}
+}
+
+func TestFrontierRepositorySizeByHost(t *testing.T) {
+	fr, err := NewFrontierRepository(openTestDB(t), depthScorer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	for _, path := range []string{"/1", "/2"} {
+		if err := fr.Push(QueueItem{URL: server.URL + path}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the urls of a test server carry a port, the metrics count the host name only
+	u, _ := url.Parse(server.URL)
+	if sizes := fr.SizeByHost(); len(sizes) != 1 || sizes[u.Hostname()] != 2 {
+		t.Errorf("got %v, want 2 urls of %s", sizes, u.Hostname())
+	}
+}
+
+func TestFrontierRepositoryDefer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fr, err := NewFrontierRepository(db, depthScorer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fr.Push(QueueItem{URL: "http://b.com/", Depth: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := fr.Defer(QueueItem{URL: "http://a.com/", Depth: 1}, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := fr.Push(QueueItem{URL: "http://a.com/"}); err != nil || fr.Size() != 2 || fr.Deferred() != 1 {
+		t.Fatalf("got size %d, %d deferred, %v", fr.Size(), fr.Deferred(), err)
+	}
+	if item, _, _ := fr.Get("http://a.com/"); item.Depth != 1 {
+		t.Errorf("got %+v, want the deferred item", item)
+	}
+	if item, err := fr.Pull(); err != nil || item.URL != "http://b.com/" {
+		t.Fatalf("got %+v, %v, want the item which is not deferred", item, err)
+	}
+	if _, err := fr.Pull(); !errors.Is(err, ErrEmptyQueue) || fr.Size() != 1 {
+		t.Errorf("got %v with size %d, the deferred item is pulled before it is due", err, fr.Size())
+	}
+	db.Close()
+
+	// the deferred item outlives a restart and is scored like the others once due
+	db, err = bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if fr, err = NewFrontierRepository(db, depthScorer{}); err != nil {
+		t.Fatal(err)
+	}
+	if fr.Size() != 1 || fr.Deferred() != 1 || fr.SizeByHost()["a.com"] != 1 {
+		t.Fatalf("got size %d, %d deferred, %v by host", fr.Size(), fr.Deferred(), fr.SizeByHost())
+	}
+	if err := fr.Defer(QueueItem{URL: "http://c.com/", Depth: 2}, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := fr.Push(QueueItem{URL: "http://d.com/", Depth: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if item, err := fr.Pull(); err != nil || item.URL != "http://c.com/" || fr.Deferred() != 1 {
+		t.Errorf("got %+v, %v, %d deferred, want the due item first by its score", item, err, fr.Deferred())
+	}
+	if ok, err := fr.Remove("http://a.com/"); !ok || err != nil || fr.Deferred() != 0 || fr.Size() != 1 {
+		t.Errorf("got %t, %v, %d deferred, size %d", ok, err, fr.Deferred(), fr.Size())
+	}
+}
@@ -2,37 +2,63 @@ package storage
 
 import "sync"
 
+// Hashlist is the in-memory set of urls, every url keeps the reason it was added for
 type Hashlist struct {
-	urlList map[string]struct{}
+	urlList map[string]string
 	mu      *sync.Mutex
 }
 
 func NewHashList() *Hashlist {
 	return &Hashlist{
-		urlList: make(map[string]struct{}),
+		urlList: make(map[string]string),
 		mu:      &sync.Mutex{},
 	}
 }
 
 func (b *Hashlist) AddToList(val string) {
+	b.AddWithReason(val, "")
+}
+
+// AddWithReason lists the url or replaces its reason and tells whether anything changed
+func (b *Hashlist) AddWithReason(val string, reason string) (bool, error) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	b.urlList[val] = struct{}{}
+	if stored, ok := b.urlList[val]; ok && stored == reason {
+		return false, nil
+	}
+	b.urlList[val] = reason
+	return true, nil
 }
 
-func (b *Hashlist) RemoveFromList(val string) {
+func (b *Hashlist) RemoveFromList(val string) error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 	delete(b.urlList, val)
+	return nil
 }
 
 func (b *Hashlist) DoesExist(url string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
 	if _, ok := b.urlList[url]; ok {
 		return true
 	}
 	return false
 }
 
+// Entries returns a copy of the list, url to reason
+func (b *Hashlist) Entries() map[string]string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	entries := make(map[string]string, len(b.urlList))
+	for k, v := range b.urlList {
+		entries[k] = v
+	}
+	return entries
+}
+
 func (b *Hashlist) Size() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
 	return len(b.urlList)
 }
@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	bolt "go.etcd.io/bbolt"
+)
+
+// The link graph of the stored pages. The outlinks bucket keeps the JSON list of the out-links by url,
+// the inlinks bucket is its reverse index: the keys are the target, a zero byte and the linking url.
+const (
+	outLinksBucketName = "outlinks"
+	inLinksBucketName  = "inlinks"
+)
+
+func inLinkKey(target, source string) []byte {
+	return append(append([]byte(target), 0), source...)
+}
+
+// SaveLinks replaces the out-links of the page, the in-links of the targets follow
+func (lr *LinkRepository) SaveLinks(url string, links []string) error {
+	return lr.db.Update(func(tx *bolt.Tx) error {
+		out := tx.Bucket([]byte(outLinksBucketName))
+		in := tx.Bucket([]byte(inLinksBucketName))
+
+		if old := out.Get([]byte(url)); old != nil {
+			var previous []string
+			if err := json.Unmarshal(old, &previous); err != nil {
+				return err
+			}
+			for _, target := range previous {
+				if err := in.Delete(inLinkKey(target, url)); err != nil {
+					return err
+				}
+			}
+		}
+
+		unique := make([]string, 0, len(links))
+		seen := make(map[string]bool, len(links))
+		for _, target := range links {
+			if seen[target] {
+				continue
+			}
+			seen[target] = true
+			unique = append(unique, target)
+			if err := in.Put(inLinkKey(target, url), nil); err != nil {
+				return err
+			}
+		}
+		data, err := json.Marshal(unique)
+		if err != nil {
+			return err
+		}
+		return out.Put([]byte(url), data)
+	})
+}
+
+// PageLinks are the links of a page recorded by the crawl, the links of the pages fetched before
+// the link graph was kept are unknown
+type PageLinks struct {
+	Outgoing []string `json:"outgoing"`
+	Incoming []string `json:"incoming"`
+	// IncomingTotal counts all the in-links when Incoming is limited
+	IncomingTotal int `json:"incoming_total"`
+}
+
+// Links reads both directions in one transaction, limit caps the in-links, 0 returns all
+func (lr *LinkRepository) Links(url string, limit int) (PageLinks, error) {
+	links := PageLinks{Outgoing: make([]string, 0), Incoming: make([]string, 0)}
+	err := lr.db.View(func(tx *bolt.Tx) error {
+		if data := tx.Bucket([]byte(outLinksBucketName)).Get([]byte(url)); data != nil {
+			if err := json.Unmarshal(data, &links.Outgoing); err != nil {
+				return err
+			}
+		}
+
+		prefix := inLinkKey(url, "")
+		c := tx.Bucket([]byte(inLinksBucketName)).Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			links.IncomingTotal++
+			if limit <= 0 || len(links.Incoming) < limit {
+				links.Incoming = append(links.Incoming, string(k[len(prefix):]))
+			}
+		}
+		return nil
+	})
+	return links, err
+}
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLinks(t *testing.T) {
+	lr, err := NewLinkRepository(openTestDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	save := func(url string, links ...string) {
+		t.Helper()
+		if err := lr.SaveLinks(url, links); err != nil {
+			t.Fatal(err)
+		}
+	}
+	save("http://a.com/", "http://a.com/1", "http://a.com/2", "http://a.com/1")
+	save("http://a.com/1", "http://a.com/", "http://a.com/2")
+	save("http://a.com/2", "http://a.com/")
+	// the page changed, it no longer links to /2
+	save("http://a.com/1", "http://a.com/")
+
+	tests := []struct {
+		url         string
+		limit       int
+		wantOut     string
+		wantIn      string
+		wantInTotal int
+	}{
+		{url: "http://a.com/", wantOut: "http://a.com/1 http://a.com/2", wantIn: "http://a.com/1 http://a.com/2", wantInTotal: 2},
+		{url: "http://a.com/", limit: 1, wantOut: "http://a.com/1 http://a.com/2", wantIn: "http://a.com/1", wantInTotal: 2},
+		{url: "http://a.com/2", wantOut: "http://a.com/", wantIn: "http://a.com/", wantInTotal: 1},
+		{url: "http://a.com/3", wantOut: "", wantIn: "", wantInTotal: 0},
+	}
+	for _, tt := range tests {
+		links, err := lr.Links(tt.url, tt.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, in := strings.Join(links.Outgoing, " "), strings.Join(links.Incoming, " ")
+		if out != tt.wantOut || in != tt.wantIn || links.IncomingTotal != tt.wantInTotal {
+			t.Errorf("%s: got out %q in %q (%d), want out %q in %q (%d)", tt.url, out, in, links.IncomingTotal, tt.wantOut, tt.wantIn, tt.wantInTotal)
+		}
+	}
+}
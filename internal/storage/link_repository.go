@@ -1,6 +1,10 @@
 package storage
 
-import bolt "go.etcd.io/bbolt"
+import (
+	"bytes"
+	"fmt"
+	bolt "go.etcd.io/bbolt"
+)
 
 type LinkRepository struct {
 	db *bolt.DB
@@ -8,23 +12,108 @@ type LinkRepository struct {
 
 const linksBucketName = "links"
 
+// The meta bucket records the layout of the stored data. bodyFormatKey holds the version of the
+// body records, databases without it keep the raw page bodies inline in the links bucket.
+// bodyMigrationKey holds the last url moved by a migration which has not finished yet.
+const (
+	metaBucketName   = "meta"
+	bodyFormatKey    = "body_format"
+	bodyMigrationKey = "body_migration"
+)
+
+const bodyFormatVersion byte = 1
+
+// A migration batch moves at most bodyMigrationBatch bodies and about bodyMigrationBatchSize bytes
+// in one transaction
+const (
+	bodyMigrationBatch     = 500
+	bodyMigrationBatchSize = 16 << 20
+)
+
+var linkBuckets = []string{linksBucketName, pagesBucketName, bodiesBucketName, bodyRefsBucketName, outLinksBucketName, inLinksBucketName, metaBucketName}
+
 func NewLinkRepository(db *bolt.DB) (*LinkRepository, error) {
 	err := db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists([]byte(linksBucketName))
-		return err
+		for _, name := range linkBuckets {
+			if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return err
+			}
+		}
+		return nil
 	})
 	if err != nil {
 		return nil, err
 	}
+	if err := migrateBodies(db, bodyMigrationBatch); err != nil {
+		return nil, err
+	}
 
 	return &LinkRepository{db: db}, nil
 }
 
+// migrateBodies moves the raw bodies of an older database to the body store once, the format
+// version is never guessed from the stored bytes. The bodies are moved in batches, an interrupted
+// migration goes on after the last url it moved.
+func migrateBodies(db *bolt.DB, batchSize int) error {
+	for {
+		done, err := migrateBodyBatch(db, batchSize)
+		if err != nil || done {
+			return err
+		}
+	}
+}
+
+// migrateBodyBatch moves the next batch and tells whether the migration is done
+func migrateBodyBatch(db *bolt.DB, batchSize int) (bool, error) {
+	done := false
+	err := db.Update(func(tx *bolt.Tx) error {
+		meta := tx.Bucket([]byte(metaBucketName))
+		if v := meta.Get([]byte(bodyFormatKey)); v != nil {
+			if len(v) != 1 || v[0] != bodyFormatVersion {
+				return fmt.Errorf("unsupported body format %x", v)
+			}
+			done = true
+			return nil
+		}
+
+		c := tx.Bucket([]byte(linksBucketName)).Cursor()
+		k, v := c.First()
+		if last := meta.Get([]byte(bodyMigrationKey)); last != nil {
+			k, v = c.Seek(last)
+			if k != nil && bytes.Equal(k, last) {
+				k, v = c.Next()
+			}
+		}
+		type legacyBody struct{ url, body []byte }
+		var batch []legacyBody
+		size := 0
+		for ; k != nil && len(batch) < batchSize && size < bodyMigrationBatchSize; k, v = c.Next() {
+			batch = append(batch, legacyBody{url: append([]byte(nil), k...), body: append([]byte(nil), v...)})
+			size += len(v)
+		}
+		for _, l := range batch {
+			if err := putBody(tx, l.url, l.body); err != nil {
+				return err
+			}
+		}
+
+		if k != nil {
+			return meta.Put([]byte(bodyMigrationKey), batch[len(batch)-1].url)
+		}
+		done = true
+		if err := meta.Delete([]byte(bodyMigrationKey)); err != nil {
+			return err
+		}
+		return meta.Put([]byte(bodyFormatKey), []byte{bodyFormatVersion})
+	})
+	return done, err
+}
+
+// SaveByKey stores the page body compressed and only once for identical bodies,
+// GetByKey reverses it transparently
 func (lr *LinkRepository) SaveByKey(url string, data []byte) error {
 	return lr.db.Update(func(tx *bolt.Tx) error {
-		bucket := tx.Bucket([]byte(linksBucketName))
-
-		return bucket.Put([]byte(url), data)
+		return putBody(tx, []byte(url), data)
 	})
 }
 
@@ -36,8 +125,14 @@ func (lr *LinkRepository) GetByKey(url string) ([]byte, error) {
 			return nil
 		}
 
-		data = bucket.Get([]byte(url))
-		return nil
+		stored := bucket.Get([]byte(url))
+		if stored == nil {
+			return nil
+		}
+		// the slice is only valid inside the transaction, getBody always returns a copy
+		var err error
+		data, err = getBody(tx, stored)
+		return err
 	})
 	return data, err
 }
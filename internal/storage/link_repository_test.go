@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"bytes"
+	bolt "go.etcd.io/bbolt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func openTestDB(t *testing.T) *bolt.DB {
+	t.Helper()
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "test.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestLinkRepositoryCompression(t *testing.T) {
+	var compressionTest = []struct {
+		name       string
+		body       []byte
+		wantFormat byte
+	}{
+		{name: "short body kept raw", body: []byte("<html></html>"), wantFormat: bodyFormatRaw},
+		{name: "long body gzipped", body: []byte(strings.Repeat("<p>hello</p>", 100)), wantFormat: bodyFormatGzip},
+		{name: "empty body", body: []byte{}, wantFormat: bodyFormatRaw},
+	}
+
+	lr, err := NewLinkRepository(openTestDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range compressionTest {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := lr.SaveByKey(tt.name, tt.body); err != nil {
+				t.Fatal(err)
+			}
+			var format byte
+			_ = lr.db.View(func(tx *bolt.Tx) error {
+				ref := tx.Bucket([]byte(linksBucketName)).Get([]byte(tt.name))
+				format = tx.Bucket([]byte(bodiesBucketName)).Get(ref[1:])[0]
+				return nil
+			})
+			if format != tt.wantFormat {
+				t.Errorf("got format %d, want %d", format, tt.wantFormat)
+			}
+			got, err := lr.GetByKey(tt.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tt.body) {
+				t.Errorf("got %q, want %q", got, tt.body)
+			}
+		})
+	}
+}
+
+func TestLinkRepositoryMigratesRawBodies(t *testing.T) {
+	// the bodies of the older databases are stored inline and raw, whatever byte they start with
+	legacy := map[string][]byte{
+		"https://example.com/":      []byte("<html>old</html>"),
+		"https://example.com/utf16": {0x00, '<', 0x00, 'p', 0x00, '>'},
+		"https://example.com/bin":   append([]byte{0x02}, bytes.Repeat([]byte{0xff}, 32)...),
+		"https://example.com/empty": {},
+	}
+	db := openTestDB(t)
+	err := db.Update(func(tx *bolt.Tx) error {
+		links, err := tx.CreateBucket([]byte(linksBucketName))
+		if err != nil {
+			return err
+		}
+		for url, body := range legacy {
+			if err := links.Put([]byte(url), body); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		for _, name := range linkBuckets {
+			if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// an interrupted migration has moved the first batch only, the open goes on after it
+	if done, err := migrateBodyBatch(db, 2); done || err != nil {
+		t.Fatalf("got done %t, %v after the first batch", done, err)
+	}
+
+	lr, err := NewLinkRepository(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for url, body := range legacy {
+		got, err := lr.GetByKey(url)
+		if err != nil || !bytes.Equal(got, body) {
+			t.Errorf("%s: got %q, %v, want %q", url, got, err, body)
+		}
+	}
+
+	// the second open finds the format version and leaves the records alone
+	if _, err := NewLinkRepository(db); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := lr.GetByKey("https://example.com/bin"); !bytes.Equal(got, legacy["https://example.com/bin"]) {
+		t.Errorf("got %q after reopening", got)
+	}
+}
+
+func TestLinkRepositoryDeduplication(t *testing.T) {
+	lr, err := NewLinkRepository(openTestDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bodies := func() int {
+		var n int
+		_ = lr.db.View(func(tx *bolt.Tx) error {
+			n = tx.Bucket([]byte(bodiesBucketName)).Stats().KeyN
+			return nil
+		})
+		return n
+	}
+
+	_ = lr.SaveByKey("https://example.com/page", []byte("article"))
+	_ = lr.SaveByKey("https://example.com/page?print=1", []byte("article"))
+	_ = lr.SaveByKey("https://example.com/page?sid=42", []byte("article"))
+	_ = lr.SaveByKey("https://example.com/other", []byte("other"))
+	if got := bodies(); got != 2 {
+		t.Errorf("got %d stored bodies, want 2", got)
+	}
+
+	clusters, err := lr.DuplicateClusters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clusters) != 1 || len(clusters[0].URLs) != 3 || clusters[0].Digest != BodyDigest([]byte("article")) || clusters[0].Size != 7 {
+		t.Errorf("got clusters %+v", clusters)
+	}
+
+	// re-fetched pages move their reference, the last reference removes the body
+	_ = lr.SaveByKey("https://example.com/other", []byte("article"))
+	if got := bodies(); got != 1 {
+		t.Errorf("got %d stored bodies after the update, want 1", got)
+	}
+	if data, _ := lr.GetByKey("https://example.com/other"); string(data) != "article" {
+		t.Errorf("got %q", data)
+	}
+}
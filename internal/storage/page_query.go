@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	bolt "go.etcd.io/bbolt"
+	"regexp"
+	"time"
+)
+
+// PageQuery selects the stored pages in url order
+type PageQuery struct {
+	Prefix string
+	// Match is applied to the whole url, nil matches everything
+	Match *regexp.Regexp
+	// After is the url the previous page of results ended with, see PageList.Next
+	After string
+	// Limit is the number of the pages returned, 0 returns all
+	Limit int
+}
+
+func (q PageQuery) matches(url []byte) bool {
+	return q.Match == nil || q.Match.Match(url)
+}
+
+// PageSummary describes a stored page in the listings
+type PageSummary struct {
+	URL         string    `json:"url"`
+	StatusCode  int       `json:"status_code,omitempty"`
+	ContentType string    `json:"content_type,omitempty"`
+	Size        int64     `json:"size"`
+	FetchedAt   time.Time `json:"fetched_at"`
+}
+
+// PageList is a page of the results, Next is the After of the following page, empty on the last page
+type PageList struct {
+	Pages []PageSummary `json:"pages"`
+	Next  string        `json:"next"`
+}
+
+// eachStoredPage walks the urls with a stored body which match the query, until fn returns an error
+// or the limit is reached. It tells whether more matching urls follow.
+func eachStoredPage(tx *bolt.Tx, q PageQuery, fn func(url []byte, record *PageRecord) error) (bool, error) {
+	pages := tx.Bucket([]byte(pagesBucketName))
+	c := tx.Bucket([]byte(linksBucketName)).Cursor()
+	prefix := []byte(q.Prefix)
+
+	k, _ := c.Seek(prefix)
+	if q.After != "" && q.After >= q.Prefix {
+		k, _ = c.Seek([]byte(q.After))
+		if k != nil && string(k) == q.After {
+			k, _ = c.Next()
+		}
+	}
+	n := 0
+	for ; k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		if !q.matches(k) {
+			continue
+		}
+		if q.Limit > 0 && n == q.Limit {
+			return true, nil
+		}
+		var record *PageRecord
+		if data := pages.Get(k); data != nil {
+			var err error
+			if record, err = decodePageRecord(data); err != nil {
+				return false, fmt.Errorf("broken page record %s: %w", k, err)
+			}
+		}
+		if err := fn(k, record); err != nil {
+			return false, err
+		}
+		n++
+	}
+	return false, nil
+}
+
+// ListPages lists the urls with a stored body, the listing is consistent as it is read in one transaction
+func (lr *LinkRepository) ListPages(q PageQuery) (PageList, error) {
+	list := PageList{Pages: make([]PageSummary, 0)}
+	err := lr.db.View(func(tx *bolt.Tx) error {
+		more, err := eachStoredPage(tx, q, func(url []byte, record *PageRecord) error {
+			summary := PageSummary{URL: string(url)}
+			if record != nil {
+				summary.StatusCode = record.StatusCode
+				summary.ContentType = record.Header.Get("Content-Type")
+				summary.Size = record.Size
+				summary.FetchedAt = record.FetchedAt
+			}
+			list.Pages = append(list.Pages, summary)
+			return nil
+		})
+		if more {
+			list.Next = list.Pages[len(list.Pages)-1].URL
+		}
+		return err
+	})
+	return list, err
+}
+
+// GetStoredPage returns the record and the body of the url read together, both are nil when
+// nothing is stored
+func (lr *LinkRepository) GetStoredPage(url string) (*PageRecord, []byte, error) {
+	var record *PageRecord
+	var body []byte
+	err := lr.db.View(func(tx *bolt.Tx) error {
+		if data := tx.Bucket([]byte(pagesBucketName)).Get([]byte(url)); data != nil {
+			var err error
+			if record, err = decodePageRecord(data); err != nil {
+				return err
+			}
+		}
+		if stored := tx.Bucket([]byte(linksBucketName)).Get([]byte(url)); stored != nil {
+			var err error
+			body, err = getBody(tx, stored)
+			return err
+		}
+		return nil
+	})
+	return record, body, err
+}
+
+// ForEachStoredPage walks the matching pages with their bodies in one read transaction, e.g. to archive
+// them, so the pages, records and bodies are a consistent snapshot. The record is nil for bodies stored
+// without one. fn is called inside the transaction and should only spool the page, e.g. to a file: a
+// transaction kept open by a slow fn holds up the database growing under the crawl.
+func (lr *LinkRepository) ForEachStoredPage(q PageQuery, fn func(url string, record *PageRecord, body []byte) error) error {
+	return lr.db.View(func(tx *bolt.Tx) error {
+		links := tx.Bucket([]byte(linksBucketName))
+		_, err := eachStoredPage(tx, q, func(url []byte, record *PageRecord) error {
+			body, err := getBody(tx, links.Get(url))
+			if err != nil {
+				return err
+			}
+			return fn(string(url), record, body)
+		})
+		return err
+	})
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestListPages(t *testing.T) {
+	lr, err := NewLinkRepository(openTestDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	urls := []string{"http://a.com/", "http://a.com/blog/1", "http://a.com/blog/2", "http://a.com/blog/3", "http://a.com/docs", "http://b.com/"}
+	for _, u := range urls {
+		if err := lr.SaveByKey(u, []byte("body of "+u)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := lr.SavePageRecord(&PageRecord{URL: "http://a.com/blog/1", StatusCode: 200, Size: 27}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		query    PageQuery
+		wantURLs string
+		wantNext string
+	}{
+		{name: "all", query: PageQuery{}, wantURLs: strings.Join(urls, " ")},
+		{name: "prefix", query: PageQuery{Prefix: "http://a.com/blog/"}, wantURLs: "http://a.com/blog/1 http://a.com/blog/2 http://a.com/blog/3"},
+		{name: "first page", query: PageQuery{Prefix: "http://a.com/blog/", Limit: 2}, wantURLs: "http://a.com/blog/1 http://a.com/blog/2", wantNext: "http://a.com/blog/2"},
+		{name: "last page", query: PageQuery{Prefix: "http://a.com/blog/", After: "http://a.com/blog/2", Limit: 2}, wantURLs: "http://a.com/blog/3"},
+		{name: "exactly the limit", query: PageQuery{Prefix: "http://a.com/blog/", Limit: 3}, wantURLs: "http://a.com/blog/1 http://a.com/blog/2 http://a.com/blog/3"},
+		{name: "match", query: PageQuery{Match: regexp.MustCompile(`/(docs|blog/3)$`)}, wantURLs: "http://a.com/blog/3 http://a.com/docs"},
+		{name: "match with limit", query: PageQuery{Match: regexp.MustCompile(`/$`), Limit: 1}, wantURLs: "http://a.com/", wantNext: "http://a.com/"},
+		{name: "after outside the prefix", query: PageQuery{Prefix: "http://b.com/", After: "http://a.com/docs"}, wantURLs: "http://b.com/"},
+		{name: "nothing", query: PageQuery{Prefix: "http://c.com/"}, wantURLs: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := lr.ListPages(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, p := range list.Pages {
+				got = append(got, p.URL)
+			}
+			if strings.Join(got, " ") != tt.wantURLs || list.Next != tt.wantNext {
+				t.Errorf("got %v next %q, want %s next %q", got, list.Next, tt.wantURLs, tt.wantNext)
+			}
+		})
+	}
+
+	list, _ := lr.ListPages(PageQuery{Prefix: "http://a.com/blog/1"})
+	if p := list.Pages[0]; p.StatusCode != 200 || p.Size != 27 {
+		t.Errorf("got summary %+v", p)
+	}
+
+	record, body, err := lr.GetStoredPage("http://a.com/blog/1")
+	if err != nil || record == nil || string(body) != "body of http://a.com/blog/1" {
+		t.Errorf("got %v, %q, %v", record, body, err)
+	}
+	if record, body, err := lr.GetStoredPage("http://c.com/"); record != nil || body != nil || err != nil {
+		t.Errorf("got %v, %q, %v for a missing page", record, body, err)
+	}
+
+	var walked []string
+	err = lr.ForEachStoredPage(PageQuery{Prefix: "http://a.com/blog/", Limit: 2}, func(url string, record *PageRecord, body []byte) error {
+		walked = append(walked, url+"="+string(body))
+		return nil
+	})
+	if err != nil || strings.Join(walked, ",") != "http://a.com/blog/1=body of http://a.com/blog/1,http://a.com/blog/2=body of http://a.com/blog/2" {
+		t.Errorf("got %v, %v", walked, err)
+	}
+}
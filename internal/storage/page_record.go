@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	bolt "go.etcd.io/bbolt"
+	"net/http"
+	"time"
+)
+
+const pagesBucketName = "pages"
+
+// PageRecordVersion is bumped on every incompatible change of the PageRecord encoding
+const PageRecordVersion = 1
+
+// PageRecord is the response metadata kept for every fetched url, failed ones included
+type PageRecord struct {
+	Version    int    `json:"version"`
+	URL        string `json:"url"`
+	FinalURL   string `json:"final_url,omitempty"`
+	StatusCode int    `json:"status_code,omitempty"`
+	// Header is the response header without the session of the crawler, see sessionHeaders
+	Header    http.Header   `json:"header,omitempty"`
+	IP        string        `json:"ip,omitempty"`
+	Size      int64         `json:"size"`
+	WireSize  int64         `json:"wire_size"`
+	Duration  time.Duration `json:"duration_ns"`
+	FetchedAt time.Time     `json:"fetched_at"`
+	Error     string        `json:"error,omitempty"`
+
+	// Digest is the BodyDigest of the stored body, pages with the same digest are duplicates
+	Digest string `json:"digest,omitempty"`
+
+	// SimHash is the fingerprint of the visible text of HTML pages
+	SimHash               uint64 `json:"simhash,omitempty"`
+	NearDuplicateOf       string `json:"near_duplicate_of,omitempty"`
+	NearDuplicateDistance int    `json:"near_duplicate_distance,omitempty"`
+}
+
+// sessionHeaders carry the cookies of the crawler, e.g. of the logged in hosts. The records are served
+// without a token, so the headers are neither stored nor read from the records of the older versions.
+var sessionHeaders = []string{"Set-Cookie", "Set-Cookie2"}
+
+func withoutSessionHeaders(header http.Header) http.Header {
+	if header == nil {
+		return nil
+	}
+	header = header.Clone()
+	for _, name := range sessionHeaders {
+		header.Del(name)
+	}
+	return header
+}
+
+func encodePageRecord(record *PageRecord) ([]byte, error) {
+	r := *record
+	r.Version = PageRecordVersion
+	r.Header = withoutSessionHeaders(r.Header)
+	return json.Marshal(&r)
+}
+
+func decodePageRecord(data []byte) (*PageRecord, error) {
+	var record PageRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+	if record.Version > PageRecordVersion {
+		return nil, fmt.Errorf("page record version %d is newer than supported %d", record.Version, PageRecordVersion)
+	}
+	record.Header = withoutSessionHeaders(record.Header)
+	return &record, nil
+}
+
+func (lr *LinkRepository) SavePageRecord(record *PageRecord) error {
+	data, err := encodePageRecord(record)
+	if err != nil {
+		return err
+	}
+	return lr.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(pagesBucketName)).Put([]byte(record.URL), data)
+	})
+}
+
+// GetPageRecord returns nil without an error when the url has not been fetched yet
+func (lr *LinkRepository) GetPageRecord(url string) (*PageRecord, error) {
+	var record *PageRecord
+	err := lr.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket([]byte(pagesBucketName)).Get([]byte(url))
+		if data == nil {
+			return nil
+		}
+		var err error
+		record, err = decodePageRecord(data)
+		return err
+	})
+	return record, err
+}
+
+// ForEachPageRecord walks the records in url order until fn returns an error
+func (lr *LinkRepository) ForEachPageRecord(fn func(record *PageRecord) error) error {
+	return lr.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(pagesBucketName)).ForEach(func(k, v []byte) error {
+			record, err := decodePageRecord(v)
+			if err != nil {
+				return fmt.Errorf("broken page record %s: %w", k, err)
+			}
+			return fn(record)
+		})
+	})
+}
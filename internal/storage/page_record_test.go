@@ -0,0 +1,73 @@
+package storage
+
+import (
+	bolt "go.etcd.io/bbolt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestPageRecord(t *testing.T) {
+	lr, err := NewLinkRepository(openTestDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if record, err := lr.GetPageRecord("https://example.com/missing"); record != nil || err != nil {
+		t.Errorf("got %v, %v for a missing record", record, err)
+	}
+
+	saved := &PageRecord{
+		URL:        "https://example.com/",
+		FinalURL:   "https://example.com/",
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"text/html"}, "Set-Cookie": []string{"session=secret"}},
+		IP:         "93.184.216.34:443",
+		Size:       1024,
+		WireSize:   300,
+		Duration:   150 * time.Millisecond,
+		FetchedAt:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	if err := lr.SavePageRecord(saved); err != nil {
+		t.Fatal(err)
+	}
+	if err := lr.SavePageRecord(&PageRecord{URL: "https://example.com/404", StatusCode: http.StatusNotFound, Error: "status 404"}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := lr.GetPageRecord(saved.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Version != PageRecordVersion || got.Header.Get("Content-Type") != "text/html" ||
+		got.Duration != saved.Duration || !got.FetchedAt.Equal(saved.FetchedAt) || got.WireSize != saved.WireSize {
+		t.Errorf("got %+v, want %+v", got, saved)
+	}
+	if got.Header.Get("Set-Cookie") != "" || saved.Header.Get("Set-Cookie") == "" {
+		t.Errorf("got header %v, the session cookie is stored or the saved record changed", got.Header)
+	}
+
+	cnt := 0
+	_ = lr.ForEachPageRecord(func(record *PageRecord) error {
+		cnt++
+		return nil
+	})
+	if cnt != 2 {
+		t.Errorf("got %d records, want 2", cnt)
+	}
+
+	// the cookies stored by the older versions are not read either
+	_ = lr.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(pagesBucketName)).Put([]byte("legacy"), []byte(`{"version": 1, "header": {"Set-Cookie": ["session=secret"]}}`))
+	})
+	if legacy, err := lr.GetPageRecord("legacy"); err != nil || legacy.Header.Get("Set-Cookie") != "" {
+		t.Errorf("got %+v, %v", legacy, err)
+	}
+
+	_ = lr.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(pagesBucketName)).Put([]byte("future"), []byte(`{"version": 99}`))
+	})
+	if _, err := lr.GetPageRecord("future"); err == nil {
+		t.Errorf("newer record version should be rejected")
+	}
+}
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"crawler/internal/seed"
+	"encoding/json"
+	bolt "go.etcd.io/bbolt"
+)
+
+const seedsBucketName = "seeds"
+
+// SeedRepository keeps the seeds of the crawl, so a resumed crawl gets the same scope
+type SeedRepository struct {
+	db *bolt.DB
+}
+
+func NewSeedRepository(db *bolt.DB) (*SeedRepository, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(seedsBucketName))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &SeedRepository{db: db}, nil
+}
+
+// Save adds the seeds, a seed saved before is replaced with the new overrides
+func (sr *SeedRepository) Save(seeds []seed.Seed) error {
+	return sr.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(seedsBucketName))
+		for _, s := range seeds {
+			data, err := json.Marshal(s)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put([]byte(s.URL), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// All returns the seeds in url order
+func (sr *SeedRepository) All() ([]seed.Seed, error) {
+	var seeds []seed.Seed
+	err := sr.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(seedsBucketName)).ForEach(func(_, v []byte) error {
+			var s seed.Seed
+			if err := json.Unmarshal(v, &s); err != nil {
+				return err
+			}
+			seeds = append(seeds, s)
+			return nil
+		})
+	})
+	return seeds, err
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	bolt "go.etcd.io/bbolt"
+	"sync"
+)
+
+const seenBucketName = "seen"
+
+// SeenSet remembers every url the crawler has queued or fetched. The Bloom filter answers most lookups
+// of new urls without touching the disk, only its "maybe" answers are checked in the database.
+type SeenSet struct {
+	db     *bolt.DB
+	filter *BloomFilter
+	mu     sync.Mutex
+}
+
+// NewSeenSet loads the urls seen before the restart into the filter, the fetched pages of databases
+// written before the seen set existed are added on the first start
+func NewSeenSet(db *bolt.DB, expected uint64, fpRate float64) (*SeenSet, error) {
+	ss := &SeenSet{db: db, filter: NewBloomFilter(expected, fpRate)}
+	err := db.Update(func(tx *bolt.Tx) error {
+		seen, err := tx.CreateBucketIfNotExists([]byte(seenBucketName))
+		if err != nil {
+			return err
+		}
+		if links := tx.Bucket([]byte(linksBucketName)); links != nil && seen.Stats().KeyN == 0 {
+			err = links.ForEach(func(k, _ []byte) error {
+				return seen.Put(k, []byte{})
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return seen.ForEach(func(k, _ []byte) error {
+			ss.filter.Add(k)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ss, nil
+}
+
+// Add marks the url as seen and tells whether it was new
+func (ss *SeenSet) Add(url string) (bool, error) {
+	return ss.addWith(url, nil)
+}
+
+// addWith marks the url as seen and runs fn in the same transaction when the url is new, e.g. to queue it.
+// It tells whether the url was new, nothing is written for the urls seen before.
+func (ss *SeenSet) addWith(url string, fn func(tx *bolt.Tx) error) (bool, error) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	key := []byte(url)
+	if ss.filter.MayContain(key) && ss.exists(key) {
+		return false, nil
+	}
+	err := ss.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket([]byte(seenBucketName)).Put(key, []byte{}); err != nil {
+			return err
+		}
+		if fn == nil {
+			return nil
+		}
+		return fn(tx)
+	})
+	if err != nil {
+		return false, err
+	}
+	ss.filter.Add(key)
+	return true, nil
+}
+
+func (ss *SeenSet) Contains(url string) bool {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	key := []byte(url)
+	return ss.filter.MayContain(key) && ss.exists(key)
+}
+
+// Remove forgets the url, so it is queued again when a page links to it. The filter can't forget,
+// the url just costs a database lookup from now on.
+func (ss *SeenSet) Remove(url string) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return ss.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(seenBucketName)).Delete([]byte(url))
+	})
+}
+
+// ForEach walks the seen urls in one transaction, e.g. to restore the counters of the trap detector
+func (ss *SeenSet) ForEach(fn func(url string) error) error {
+	return ss.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(seenBucketName)).ForEach(func(k, _ []byte) error {
+			return fn(string(k))
+		})
+	})
+}
+
+func (ss *SeenSet) exists(key []byte) bool {
+	var exists bool
+	_ = ss.db.View(func(tx *bolt.Tx) error {
+		exists = tx.Bucket([]byte(seenBucketName)).Get(key) != nil
+		return nil
+	})
+	return exists
+}
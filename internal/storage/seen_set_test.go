@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"fmt"
+	bolt "go.etcd.io/bbolt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBloomFilterFalsePositives(t *testing.T) {
+	bf := NewBloomFilter(10000, 0.01)
+	for i := 0; i < 10000; i++ {
+		bf.Add([]byte(fmt.Sprintf("http://example.com/%d", i)))
+	}
+	for i := 0; i < 10000; i++ {
+		if !bf.MayContain([]byte(fmt.Sprintf("http://example.com/%d", i))) {
+			t.Fatalf("added item %d is missing", i)
+		}
+	}
+
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if bf.MayContain([]byte(fmt.Sprintf("http://other.com/%d", i))) {
+			falsePositives++
+		}
+	}
+	if falsePositives > 200 {
+		t.Errorf("got %d false positives of 10000, want about 100", falsePositives)
+	}
+}
+
+func TestSeenSet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lr, err := NewLinkRepository(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lr.SaveByKey("http://example.com/fetched", []byte("page")); err != nil {
+		t.Fatal(err)
+	}
+
+	ss, err := NewSeenSet(db, 100, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ss.Contains("http://example.com/fetched") {
+		t.Error("the fetched page is not seen")
+	}
+	if added, err := ss.Add("http://example.com/new"); err != nil || !added {
+		t.Errorf("got %v, %v, want the new url added", added, err)
+	}
+	if added, err := ss.Add("http://example.com/new"); err != nil || added {
+		t.Errorf("got %v, %v, want the url seen", added, err)
+	}
+	db.Close()
+
+	db, err = bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ss, err = NewSeenSet(db, 100, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ss.Contains("http://example.com/new") {
+		t.Error("the url is lost after the restart")
+	}
+	if ss.Contains("http://example.com/other") {
+		t.Error("the url is seen but was never added")
+	}
+	var urls []string
+	if err := ss.ForEach(func(url string) error {
+		urls = append(urls, url)
+		return nil
+	}); err != nil || strings.Join(urls, " ") != "http://example.com/fetched http://example.com/new" {
+		t.Errorf("got %v, %v", urls, err)
+	}
+}
@@ -0,0 +1,128 @@
+package trap
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// ReasonPrefix starts the blacklist reason of every url rejected as a trap
+const ReasonPrefix = "trap: "
+
+// Config holds the limits of the heuristics, zero disables the heuristic
+type Config struct {
+	// MaxPathDepth is the number of path segments
+	MaxPathDepth int
+	// MaxSegmentRepeats is how many times a single segment may appear in the path, /a/b/a/b/a/b has "a" three times
+	MaxSegmentRepeats int
+	// MaxQueryCombinations is the number of distinct query strings of a single path, e.g. calendar or filter pages
+	MaxQueryCombinations int
+	// MaxURLsPerPattern is the number of urls in a directory pattern, numbers in the path are wildcards,
+	// so /calendar/2024/05/ and /calendar/2031/12/ share the pattern. Only the directories with numbers
+	// are counted, a flat /wiki/ or /posts/ holds any number of pages.
+	MaxURLsPerPattern int
+}
+
+// Detector rejects the urls looking like an endless generated space. It counts only the urls it accepted,
+// the counts of a resumed crawl are restored by checking the urls queued before again. It keeps no url,
+// only the counts by path and pattern, the urls already seen are left out by the seen set of the crawl.
+type Detector struct {
+	cfg Config
+
+	mu           sync.Mutex
+	queryCombos  map[string]int
+	patternCount map[string]int
+}
+
+func NewDetector(cfg Config) *Detector {
+	return &Detector{
+		cfg:          cfg,
+		queryCombos:  make(map[string]int),
+		patternCount: make(map[string]int),
+	}
+}
+
+// Check returns the blacklist reason when the url is a trap. It is meant to be called once per new url,
+// a url checked again counts again.
+func (d *Detector) Check(link string) (string, bool) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", false
+	}
+	segments := pathSegments(u.Path)
+
+	if d.cfg.MaxPathDepth > 0 && len(segments) > d.cfg.MaxPathDepth {
+		return fmt.Sprintf("%spath depth %d exceeds %d", ReasonPrefix, len(segments), d.cfg.MaxPathDepth), true
+	}
+	if d.cfg.MaxSegmentRepeats > 0 {
+		repeats := make(map[string]int)
+		for _, s := range segments {
+			repeats[s]++
+			if repeats[s] > d.cfg.MaxSegmentRepeats {
+				return fmt.Sprintf("%ssegment %q repeats more than %d times", ReasonPrefix, s, d.cfg.MaxSegmentRepeats), true
+			}
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	pathKey := u.Host + u.Path
+	countQuery := d.cfg.MaxQueryCombinations > 0 && u.RawQuery != ""
+	if countQuery && d.queryCombos[pathKey] >= d.cfg.MaxQueryCombinations {
+		return fmt.Sprintf("%smore than %d query combinations for %s", ReasonPrefix, d.cfg.MaxQueryCombinations, u.Path), true
+	}
+	pattern, numeric := directoryPattern(segments)
+	pattern = u.Host + pattern
+	countPattern := d.cfg.MaxURLsPerPattern > 0 && numeric
+	if countPattern && d.patternCount[pattern] >= d.cfg.MaxURLsPerPattern {
+		return fmt.Sprintf("%smore than %d urls in %s", ReasonPrefix, d.cfg.MaxURLsPerPattern, pattern), true
+	}
+
+	if countQuery {
+		d.queryCombos[pathKey]++
+	}
+	if countPattern {
+		d.patternCount[pattern]++
+	}
+	return "", false
+}
+
+func pathSegments(p string) []string {
+	var segments []string
+	for _, s := range strings.Split(p, "/") {
+		if s != "" {
+			segments = append(segments, s)
+		}
+	}
+	return segments
+}
+
+// directoryPattern is the directory of the path with every digit run replaced by "#", numeric tells
+// whether there was any
+func directoryPattern(segments []string) (string, bool) {
+	if len(segments) > 0 {
+		segments = segments[:len(segments)-1]
+	}
+	var b strings.Builder
+	numeric := false
+	b.WriteByte('/')
+	for _, s := range segments {
+		inDigits := false
+		for _, r := range s {
+			if r >= '0' && r <= '9' {
+				if !inDigits {
+					b.WriteByte('#')
+				}
+				numeric = true
+				inDigits = true
+				continue
+			}
+			inDigits = false
+			b.WriteRune(r)
+		}
+		b.WriteByte('/')
+	}
+	return b.String(), numeric
+}
@@ -0,0 +1,87 @@
+package trap
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDetector(t *testing.T) {
+	var trapTest = []struct {
+		name        string
+		cfg         Config
+		links       []string
+		wantTrapped []bool
+	}{
+		{
+			name:        "path depth",
+			cfg:         Config{MaxPathDepth: 3},
+			links:       []string{"http://example.com/a/b/c", "http://example.com/a/b/c/d"},
+			wantTrapped: []bool{false, true},
+		},
+		{
+			name:        "repeated segments",
+			cfg:         Config{MaxSegmentRepeats: 2},
+			links:       []string{"http://example.com/a/b/a/b", "http://example.com/a/b/a/b/a/b"},
+			wantTrapped: []bool{false, true},
+		},
+		{
+			name: "query combinations",
+			cfg:  Config{MaxQueryCombinations: 2},
+			links: []string{
+				"http://example.com/cal?month=1",
+				"http://example.com/cal?month=2",
+				"http://example.com/cal?month=3",
+				"http://example.com/cal",
+				"http://example.com/other?month=3",
+			},
+			wantTrapped: []bool{false, false, true, false, false},
+		},
+		{
+			name: "directory pattern",
+			cfg:  Config{MaxURLsPerPattern: 2},
+			links: []string{
+				"http://example.com/calendar/2024/05/",
+				"http://example.com/calendar/2024/06/",
+				"http://example.com/calendar/2031/12/",
+				"http://example.com/calendar/about/",
+			},
+			wantTrapped: []bool{false, false, true, false},
+		},
+		{
+			name: "flat directory",
+			cfg:  Config{MaxURLsPerPattern: 2},
+			links: []string{
+				"http://example.com/wiki/go",
+				"http://example.com/wiki/rust",
+				"http://example.com/wiki/zig",
+				"http://example.com/wiki/2024/",
+			},
+			wantTrapped: []bool{false, false, false, false},
+		},
+	}
+
+	for _, tt := range trapTest {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDetector(tt.cfg)
+			for i, link := range tt.links {
+				reason, trapped := d.Check(link)
+				if trapped != tt.wantTrapped[i] {
+					t.Errorf("%s: got trapped %v (%s), want %v", link, trapped, reason, tt.wantTrapped[i])
+				}
+				if trapped && !strings.HasPrefix(reason, ReasonPrefix) {
+					t.Errorf("%s: reason %q has no trap prefix", link, reason)
+				}
+			}
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		d := NewDetector(Config{})
+		for i := 0; i < 100; i++ {
+			if reason, trapped := d.Check(fmt.Sprintf("http://example.com/a/a/a/a/%d?p=%d", i, i)); trapped {
+				t.Fatalf("disabled detector trapped: %s", reason)
+			}
+		}
+	})
+}
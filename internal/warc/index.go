@@ -0,0 +1,149 @@
+package warc
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+// IndexEntry locates a response or revisit record, the fields follow the CDX columns
+type IndexEntry struct {
+	URL       string
+	Timestamp string
+	MimeType  string
+	Status    int
+	Digest    string
+	Offset    int64
+	Filename  string
+	RecordID  string
+}
+
+// Index maps target urls to their latest capture in a set of WARC files
+type Index struct {
+	byURL map[string]*IndexEntry
+	byID  map[string]*IndexEntry
+}
+
+// NewIndex reads every file once and keeps only the locations of the records
+func NewIndex(paths ...string) (*Index, error) {
+	idx := &Index{
+		byURL: make(map[string]*IndexEntry),
+		byID:  make(map[string]*IndexEntry),
+	}
+	for _, path := range paths {
+		if err := idx.add(path); err != nil {
+			return nil, fmt.Errorf("unable to index %s: %w", path, err)
+		}
+	}
+	return idx, nil
+}
+
+func (idx *Index) add(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r, err := NewReader(f)
+	if err != nil {
+		return err
+	}
+	for {
+		record, offset, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if record.Type() != TypeResponse && record.Type() != TypeRevisit {
+			continue
+		}
+
+		entry := &IndexEntry{
+			URL:       record.TargetURI(),
+			Timestamp: record.Date().Format("20060102150405"),
+			Digest:    record.Header.Get("WARC-Payload-Digest"),
+			Offset:    offset,
+			Filename:  path,
+			RecordID:  record.ID(),
+		}
+		if resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(record.Block)), nil); err == nil {
+			entry.Status = resp.StatusCode
+			entry.MimeType = resp.Header.Get("Content-Type")
+			resp.Body.Close()
+		}
+		// files are indexed in order, the later capture wins
+		idx.byURL[entry.URL] = entry
+		idx.byID[entry.RecordID] = entry
+	}
+}
+
+func (idx *Index) Lookup(url string) (*IndexEntry, bool) {
+	entry, ok := idx.byURL[url]
+	return entry, ok
+}
+
+func (idx *Index) Size() int {
+	return len(idx.byURL)
+}
+
+// Response returns the archived HTTP response block of the url. Revisits are resolved
+// into the headers of the revisit with the payload of the referred record.
+func (idx *Index) Response(url string) ([]byte, *IndexEntry, error) {
+	entry, ok := idx.byURL[url]
+	if !ok {
+		return nil, nil, fmt.Errorf("%s is not archived", url)
+	}
+	record, err := ReadRecordAt(entry.Filename, entry.Offset)
+	if err != nil {
+		return nil, entry, err
+	}
+	if record.Type() != TypeRevisit {
+		return record.Block, entry, nil
+	}
+
+	original, ok := idx.byID[record.Header.Get("WARC-Refers-To")]
+	if !ok {
+		return nil, entry, fmt.Errorf("revisit of %s refers to a missing record", url)
+	}
+	originalRecord, err := ReadRecordAt(original.Filename, original.Offset)
+	if err != nil {
+		return nil, entry, err
+	}
+	_, payload, found := bytes.Cut(originalRecord.Block, []byte("\r\n\r\n"))
+	if !found {
+		return nil, entry, fmt.Errorf("referred record of %s has no payload", url)
+	}
+	return append(append([]byte(nil), record.Block...), payload...), entry, nil
+}
+
+// WriteCDX writes the index sorted by url as CDX lines: url timestamp mime status digest offset filename
+func (idx *Index) WriteCDX(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, " CDX a b m s k V g"); err != nil {
+		return err
+	}
+	urls := make([]string, 0, len(idx.byURL))
+	for u := range idx.byURL {
+		urls = append(urls, u)
+	}
+	sort.Strings(urls)
+	for _, u := range urls {
+		e := idx.byURL[u]
+		mime, _, _ := strings.Cut(e.MimeType, ";")
+		mime = strings.TrimSpace(mime)
+		if mime == "" {
+			mime = "-"
+		}
+		if _, err := fmt.Fprintf(w, "%s %s %s %d %s %d %s\n", e.URL, e.Timestamp, mime, e.Status, strings.TrimPrefix(e.Digest, "sha1:"), e.Offset, e.Filename); err != nil {
+			return err
+		}
+	}
+	return nil
+}
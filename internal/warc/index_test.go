@@ -0,0 +1,91 @@
+package warc
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIndexResponse(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewWriter(dir, "test", 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = w.WriteExchange(testExchange("http://example.com/a", "<html>same</html>"))
+	_ = w.WriteExchange(testExchange("http://example.com/b", "<html>same</html>"))
+	missing := testExchange("http://example.com/missing", "")
+	missing.StatusCode, missing.Status = 404, "404 Not Found"
+	_ = w.WriteExchange(missing)
+	_ = w.Close()
+
+	// an uncompressed file is written out of the gzipped one
+	plainDir := t.TempDir()
+	pw, _ := NewWriter(plainDir, "plain", 1<<20)
+	_ = pw.WriteExchange(testExchange("http://example.com/plain", "<html>plain</html>"))
+	_ = pw.Close()
+	plainFiles, _ := filepath.Glob(filepath.Join(plainDir, "*.warc.gz"))
+	var buf bytes.Buffer
+	r, _ := NewReader(bytes.NewReader(mustRead(t, plainFiles[0])))
+	for {
+		record, _, err := r.Next()
+		if err != nil {
+			break
+		}
+		_, _ = record.WriteTo(&buf)
+	}
+	plain := filepath.Join(plainDir, "plain.warc")
+	_ = os.WriteFile(plain, buf.Bytes(), 0644)
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.warc.gz"))
+	idx, err := NewIndex(append(files, plain)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var responseTest = []struct {
+		url        string
+		wantStatus int
+		wantBody   string
+	}{
+		{url: "http://example.com/a", wantStatus: 200, wantBody: "<html>same</html>"},
+		{url: "http://example.com/b", wantStatus: 200, wantBody: "<html>same</html>"},
+		{url: "http://example.com/missing", wantStatus: 404},
+		{url: "http://example.com/plain", wantStatus: 200, wantBody: "<html>plain</html>"},
+	}
+	for _, tt := range responseTest {
+		t.Run(tt.url, func(t *testing.T) {
+			block, entry, err := idx.Response(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if entry.Status != tt.wantStatus {
+				t.Errorf("got status %d, want %d", entry.Status, tt.wantStatus)
+			}
+			if !strings.HasSuffix(string(block), "\r\n\r\n"+tt.wantBody) {
+				t.Errorf("got block %q", block)
+			}
+		})
+	}
+
+	if _, _, err := idx.Response("http://example.com/unknown"); err == nil {
+		t.Errorf("unknown url should not be found")
+	}
+
+	var cdx bytes.Buffer
+	_ = idx.WriteCDX(&cdx)
+	if !strings.Contains(cdx.String(), "http://example.com/a 20240102030405 text/html 200 ") {
+		t.Errorf("unexpected cdx %q", cdx.String())
+	}
+}
+
+func mustRead(t *testing.T, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
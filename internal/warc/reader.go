@@ -0,0 +1,154 @@
+package warc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Reader reads the records of a WARC file one by one, both gzip-per-record and plain files are supported
+type Reader struct {
+	counter *countingReader
+	gzipped bool
+	plain   *bufio.Reader
+}
+
+// countingReader tracks the offset in the file. It implements io.ByteReader,
+// so gzip does not read ahead past the end of a member.
+type countingReader struct {
+	r      *bufio.Reader
+	offset int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.offset += int64(n)
+	return n, err
+}
+
+func (c *countingReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.offset++
+	}
+	return b, err
+}
+
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	reader := &Reader{counter: &countingReader{r: br}}
+	reader.gzipped = len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b
+	if !reader.gzipped {
+		reader.plain = bufio.NewReader(reader.counter)
+	}
+	return reader, nil
+}
+
+// Next returns the next record with its offset in the file, io.EOF is returned at the end
+func (r *Reader) Next() (*Record, int64, error) {
+	if !r.gzipped {
+		// the plain reader buffers ahead, the offset is the consumed part only
+		offset := r.counter.offset - int64(r.plain.Buffered())
+		record, err := readRecord(r.plain)
+		return record, offset, err
+	}
+
+	offset := r.counter.offset
+	if _, err := r.counter.r.Peek(1); err == io.EOF {
+		return nil, offset, io.EOF
+	}
+	gz, err := gzip.NewReader(r.counter)
+	if err != nil {
+		return nil, offset, err
+	}
+	gz.Multistream(false)
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		return nil, offset, err
+	}
+	record, err := readRecord(bufio.NewReader(bytes.NewReader(data)))
+	return record, offset, err
+}
+
+// ReadRecordAt reads a single record at the offset of the file
+func ReadRecordAt(path string, offset int64) (*Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	r, err := NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	record, _, err := r.Next()
+	return record, err
+}
+
+// readRecord reads a single uncompressed record, io.EOF is returned when there are no more records
+func readRecord(r *bufio.Reader) (*Record, error) {
+	var line string
+	var err error
+	for line == "" {
+		line, err = r.ReadString('\n')
+		if err != nil {
+			if err == io.EOF && strings.TrimSpace(line) == "" {
+				return nil, io.EOF
+			}
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+	}
+	if !strings.HasPrefix(line, "WARC/") {
+		return nil, fmt.Errorf("unexpected record start %q", line)
+	}
+
+	record := &Record{}
+	length := -1
+	for {
+		line, err = r.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("truncated record header: %w", err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("malformed header line %q", line)
+		}
+		value = strings.TrimSpace(value)
+		if strings.EqualFold(name, "Content-Length") {
+			if _, err := fmt.Sscanf(value, "%d", &length); err != nil {
+				return nil, fmt.Errorf("malformed content length %q", value)
+			}
+		}
+		record.Header = append(record.Header, Field{Name: name, Value: value})
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("record without content length")
+	}
+
+	record.Block = make([]byte, length)
+	if _, err := io.ReadFull(r, record.Block); err != nil {
+		return nil, fmt.Errorf("truncated record block: %w", err)
+	}
+	// the block is followed by two CRLFs
+	if _, err := r.Discard(4); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return record, nil
+}
@@ -0,0 +1,137 @@
+package warc
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const Version = "WARC/1.1"
+
+const (
+	TypeWarcinfo = "warcinfo"
+	TypeRequest  = "request"
+	TypeResponse = "response"
+	TypeMetadata = "metadata"
+	TypeRevisit  = "revisit"
+)
+
+// ProfileIdenticalPayload marks a revisit record whose payload is identical to the referred one
+const ProfileIdenticalPayload = "http://netpreserve.org/warc/1.1/revisit/identical-payload-digest"
+
+const dateLayout = "2006-01-02T15:04:05Z"
+
+// Field is a single named header field, the order of the fields is kept as written
+type Field struct {
+	Name  string
+	Value string
+}
+
+type Header []Field
+
+// Get returns the first value of the field, names are case-insensitive
+func (h Header) Get(name string) string {
+	for _, f := range h {
+		if strings.EqualFold(f.Name, name) {
+			return f.Value
+		}
+	}
+	return ""
+}
+
+func (h *Header) Set(name, value string) {
+	for i := range *h {
+		if strings.EqualFold((*h)[i].Name, name) {
+			(*h)[i].Value = value
+			return
+		}
+	}
+	*h = append(*h, Field{Name: name, Value: value})
+}
+
+// Record is a single WARC record. Content-Length is derived from the block on writing.
+type Record struct {
+	Header Header
+	Block  []byte
+}
+
+func (r *Record) Type() string {
+	return r.Header.Get("WARC-Type")
+}
+
+func (r *Record) ID() string {
+	return r.Header.Get("WARC-Record-ID")
+}
+
+func (r *Record) TargetURI() string {
+	return r.Header.Get("WARC-Target-URI")
+}
+
+func (r *Record) Date() time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, r.Header.Get("WARC-Date"))
+	return t
+}
+
+func newRecord(recordType string, date time.Time) *Record {
+	return &Record{Header: Header{
+		{Name: "WARC-Type", Value: recordType},
+		{Name: "WARC-Record-ID", Value: newRecordID()},
+		{Name: "WARC-Date", Value: date.UTC().Format(dateLayout)},
+	}}
+}
+
+// WriteTo writes the uncompressed record, the trailing blank lines included
+func (r *Record) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	buf.WriteString(Version + "\r\n")
+	for _, f := range r.Header {
+		if strings.EqualFold(f.Name, "Content-Length") {
+			continue
+		}
+		buf.WriteString(f.Name + ": " + f.Value + "\r\n")
+	}
+	fmt.Fprintf(&buf, "Content-Length: %d\r\n\r\n", len(r.Block))
+	buf.Write(r.Block)
+	buf.WriteString("\r\n\r\n")
+
+	return buf.WriteTo(w)
+}
+
+// Digest returns the labelled sha1 digest in the base32 form used by WARC and CDX tools
+func Digest(data []byte) string {
+	sum := sha1.Sum(data)
+	return "sha1:" + base32.StdEncoding.EncodeToString(sum[:])
+}
+
+func newRecordID() string {
+	var u [16]byte
+	_, _ = rand.Read(u[:])
+	u[6] = (u[6] & 0x0f) | 0x40
+	u[8] = (u[8] & 0x3f) | 0x80
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
+
+// httpHeaderBlock serializes the status or request line and the headers in a stable order
+func httpHeaderBlock(firstLine string, header http.Header) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(firstLine + "\r\n")
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range header[name] {
+			buf.WriteString(name + ": " + value + "\r\n")
+		}
+	}
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
@@ -0,0 +1,240 @@
+package warc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Exchange is a single request/response pair as seen by the crawler
+type Exchange struct {
+	URL  string
+	IP   string
+	Date time.Time
+
+	RequestMethod string
+	// RequestHeader is archived without the credentials, see sensitiveHeaders
+	RequestHeader http.Header
+
+	Proto      string
+	Status     string
+	StatusCode int
+	Header     http.Header
+	// Body is the decoded payload, the Content-Encoding is dropped from the archived headers accordingly
+	Body []byte
+	// Truncated is the WARC-Truncated reason when Body is incomplete, e.g. "length",
+	// the archived headers are then kept as they were sent
+	Truncated string
+
+	Outlinks []string
+	Duration time.Duration
+}
+
+// sensitiveHeaders carry the credentials and the session of the crawler, they are never archived
+var sensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+type payloadRef struct {
+	uri  string
+	date string
+	id   string
+}
+
+// Writer writes gzip-per-record WARC files to the directory, starting a new file once
+// the current one reaches maxSize. Identical payloads are written as revisit records of the first
+// one in the same file, so the remembered payloads are bounded by the file size. Empty payloads
+// are always written in full, they would all refer to an unrelated record.
+type Writer struct {
+	dir     string
+	prefix  string
+	maxSize int64
+
+	mu      sync.Mutex
+	file    *os.File
+	size    int64
+	serial  int
+	started string
+	// payloads are the digests of the payloads written to the current file
+	payloads map[string]payloadRef
+}
+
+func NewWriter(dir, prefix string, maxSize int64) (*Writer, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("unable to create warc directory: %w", err)
+	}
+	return &Writer{
+		dir:      dir,
+		prefix:   prefix,
+		maxSize:  maxSize,
+		started:  time.Now().UTC().Format("20060102150405"),
+		payloads: make(map[string]payloadRef),
+	}, nil
+}
+
+// WriteExchange writes the request, the response (or a revisit for an already archived payload)
+// and the metadata record with the outlinks
+func (w *Writer) WriteExchange(ex *Exchange) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.rotate(); err != nil {
+		return err
+	}
+
+	ip := ex.IP
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	u := ex.URL
+
+	header := ex.Header.Clone()
+	header.Del("Transfer-Encoding")
+	if ex.Truncated == "" {
+		header.Del("Content-Encoding")
+		header.Set("Content-Length", fmt.Sprint(len(ex.Body)))
+	}
+	proto := ex.Proto
+	if proto == "" {
+		proto = "HTTP/1.1"
+	}
+	status := ex.Status
+	if status == "" {
+		status = fmt.Sprintf("%d %s", ex.StatusCode, http.StatusText(ex.StatusCode))
+	}
+	httpHeader := httpHeaderBlock(proto+" "+status, header)
+	payloadDigest := Digest(ex.Body)
+
+	var response *Record
+	// an incomplete payload is never the reference of the identical ones
+	dedup := len(ex.Body) > 0 && ex.Truncated == ""
+	if ref, ok := w.payloads[payloadDigest]; ok && dedup {
+		response = newRecord(TypeRevisit, ex.Date)
+		response.Header.Set("WARC-Profile", ProfileIdenticalPayload)
+		response.Header.Set("WARC-Refers-To", ref.id)
+		response.Header.Set("WARC-Refers-To-Target-URI", ref.uri)
+		response.Header.Set("WARC-Refers-To-Date", ref.date)
+		response.Block = httpHeader
+	} else {
+		response = newRecord(TypeResponse, ex.Date)
+		response.Block = append(httpHeader, ex.Body...)
+		if dedup {
+			w.payloads[payloadDigest] = payloadRef{uri: u, date: response.Header.Get("WARC-Date"), id: response.ID()}
+		}
+		if ex.Truncated != "" {
+			response.Header.Set("WARC-Truncated", ex.Truncated)
+		}
+	}
+	response.Header.Set("WARC-Target-URI", u)
+	if ip != "" {
+		response.Header.Set("WARC-IP-Address", ip)
+	}
+	response.Header.Set("Content-Type", "application/http;msgtype=response")
+	response.Header.Set("WARC-Payload-Digest", payloadDigest)
+	response.Header.Set("WARC-Block-Digest", Digest(response.Block))
+
+	method := ex.RequestMethod
+	if method == "" {
+		method = http.MethodGet
+	}
+	request := newRecord(TypeRequest, ex.Date)
+	request.Header.Set("WARC-Target-URI", u)
+	request.Header.Set("WARC-Concurrent-To", response.ID())
+	request.Header.Set("Content-Type", "application/http;msgtype=request")
+	request.Block = requestLine(method, u, proto, ex.RequestHeader)
+	request.Header.Set("WARC-Block-Digest", Digest(request.Block))
+
+	var fields bytes.Buffer
+	fmt.Fprintf(&fields, "fetchTimeMs: %d\r\n", ex.Duration.Milliseconds())
+	for _, link := range ex.Outlinks {
+		fmt.Fprintf(&fields, "outlink: %s\r\n", link)
+	}
+	metadata := newRecord(TypeMetadata, ex.Date)
+	metadata.Header.Set("WARC-Target-URI", u)
+	metadata.Header.Set("WARC-Concurrent-To", response.ID())
+	metadata.Header.Set("Content-Type", "application/warc-fields")
+	metadata.Block = fields.Bytes()
+
+	for _, r := range []*Record{request, response, metadata} {
+		if err := w.write(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// rotate opens the next file when there is none or the current one is full
+func (w *Writer) rotate() error {
+	if w.file != nil && w.size < w.maxSize {
+		return nil
+	}
+	if w.file != nil {
+		if err := w.file.Close(); err != nil {
+			return err
+		}
+	}
+
+	w.serial++
+	name := fmt.Sprintf("%s-%s-%05d.warc.gz", w.prefix, w.started, w.serial)
+	file, err := os.OpenFile(filepath.Join(w.dir, name), os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to create warc file: %w", err)
+	}
+	w.file = file
+	w.size = 0
+	w.payloads = make(map[string]payloadRef)
+
+	info := newRecord(TypeWarcinfo, time.Now())
+	info.Header.Set("WARC-Filename", name)
+	info.Header.Set("Content-Type", "application/warc-fields")
+	info.Block = []byte("software: crawler\r\nformat: WARC File Format 1.1\r\n")
+	return w.write(info)
+}
+
+// write appends the record as a separate gzip member, so every record can be read on its own
+func (w *Writer) write(r *Record) error {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := r.WriteTo(gz); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	n, err := w.file.Write(buf.Bytes())
+	w.size += int64(n)
+	return err
+}
+
+// requestLine rebuilds the request headers, the Host header is not a part of http.Header in Go
+func requestLine(method, rawURL, proto string, header http.Header) []byte {
+	h := header.Clone()
+	if h == nil {
+		h = http.Header{}
+	}
+	for _, name := range sensitiveHeaders {
+		h.Del(name)
+	}
+	target := "/"
+	if u, err := url.Parse(rawURL); err == nil {
+		target = u.RequestURI()
+		h.Set("Host", u.Host)
+	}
+	return httpHeaderBlock(method+" "+target+" "+proto, h)
+}
@@ -0,0 +1,221 @@
+package warc
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readAllWarcs(t *testing.T, dir string) []string {
+	t.Helper()
+	files, _ := filepath.Glob(filepath.Join(dir, "*.warc.gz"))
+	var contents []string
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(gz)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+		contents = append(contents, string(data))
+	}
+	return contents
+}
+
+func testExchange(url, body string) *Exchange {
+	return &Exchange{
+		URL:           url,
+		IP:            "127.0.0.1:8080",
+		Date:          time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		RequestHeader: http.Header{"Accept-Encoding": []string{"gzip"}},
+		Proto:         "HTTP/1.1",
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Header:        http.Header{"Content-Type": []string{"text/html"}, "Content-Encoding": []string{"gzip"}},
+		Body:          []byte(body),
+		Outlinks:      []string{"http://example.com/next"},
+	}
+}
+
+func TestWriterRecords(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewWriter(dir, "test", 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteExchange(testExchange("http://example.com/a?x=1", "<html>same</html>")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteExchange(testExchange("http://example.com/b", "<html>same</html>")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	contents := readAllWarcs(t, dir)
+	if len(contents) != 1 {
+		t.Fatalf("got %d files, want 1", len(contents))
+	}
+	warc := contents[0]
+	for _, want := range []string{
+		"WARC/1.1\r\nWARC-Type: warcinfo\r\n",
+		"WARC-Type: request\r\n",
+		"GET /a?x=1 HTTP/1.1\r\nAccept-Encoding: gzip\r\nHost: example.com\r\n\r\n",
+		"WARC-Type: response\r\n",
+		"WARC-Target-URI: http://example.com/a?x=1\r\n",
+		"WARC-IP-Address: 127.0.0.1\r\n",
+		"WARC-Payload-Digest: " + Digest([]byte("<html>same</html>")) + "\r\n",
+		"HTTP/1.1 200 OK\r\nContent-Length: 17\r\nContent-Type: text/html\r\n\r\n<html>same</html>",
+		"WARC-Type: revisit\r\n",
+		"WARC-Profile: " + ProfileIdenticalPayload + "\r\n",
+		"WARC-Refers-To-Target-URI: http://example.com/a?x=1\r\n",
+		"WARC-Type: metadata\r\n",
+		"outlink: http://example.com/next\r\n",
+	} {
+		if !strings.Contains(warc, want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+	if strings.Count(warc, "<html>same</html>") != 1 {
+		t.Errorf("identical payload is stored more than once")
+	}
+	if strings.Contains(warc, "Content-Encoding") {
+		t.Errorf("content encoding of the decoded payload is archived")
+	}
+}
+
+func TestWriterRequestCredentials(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewWriter(dir, "test", 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ex := testExchange("http://example.com/private", "<html>private</html>")
+	ex.RequestHeader = http.Header{
+		"Accept-Encoding":     []string{"gzip"},
+		"Authorization":       []string{"Basic dXNlcjpzZWNyZXQ="},
+		"Proxy-Authorization": []string{"Basic cHJveHk6c2VjcmV0"},
+		"Cookie":              []string{"session=secret"},
+	}
+	if err := w.WriteExchange(ex); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	warc := readAllWarcs(t, dir)[0]
+	if !strings.Contains(warc, "GET /private HTTP/1.1\r\nAccept-Encoding: gzip\r\nHost: example.com\r\n\r\n") {
+		t.Errorf("the request record is missing")
+	}
+	for _, secret := range []string{"Authorization", "Cookie", "secret", "dXNlcjpzZWNyZXQ="} {
+		if strings.Contains(warc, secret) {
+			t.Errorf("%q is archived", secret)
+		}
+	}
+}
+
+func TestWriterTruncated(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewWriter(dir, "test", 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ex := testExchange("http://example.com/missing", "")
+	ex.Status, ex.StatusCode = "404 Not Found", http.StatusNotFound
+	ex.Header.Set("Content-Length", "5000")
+	ex.Truncated = "length"
+	if err := w.WriteExchange(ex); err != nil {
+		t.Fatal(err)
+	}
+	// the next empty payload is not a revisit of the incomplete one
+	if err := w.WriteExchange(testExchange("http://example.com/empty", "")); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	warc := readAllWarcs(t, dir)[0]
+	for _, want := range []string{
+		"WARC-Truncated: length\r\n",
+		"HTTP/1.1 404 Not Found\r\nContent-Encoding: gzip\r\nContent-Length: 5000\r\n",
+		"HTTP/1.1 200 OK\r\nContent-Length: 0\r\n",
+	} {
+		if !strings.Contains(warc, want) {
+			t.Errorf("missing %q", want)
+		}
+	}
+	if strings.Contains(warc, "WARC-Type: revisit") {
+		t.Errorf("an incomplete payload is referenced by a revisit")
+	}
+}
+
+func TestWriterRevisits(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewWriter(dir, "test", 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, page := range []string{"a", "b"} {
+		ex := testExchange("http://example.com/"+page, "")
+		ex.Status, ex.StatusCode = "204 No Content", http.StatusNoContent
+		if err := w.WriteExchange(ex); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Close()
+	if warc := readAllWarcs(t, dir)[0]; strings.Count(warc, "WARC-Type: response\r\n") != 2 || strings.Contains(warc, "WARC-Type: revisit") {
+		t.Errorf("an empty payload is written as a revisit")
+	}
+
+	// the payloads of the previous files are not referenced
+	dir = t.TempDir()
+	if w, err = NewWriter(dir, "test", 1); err != nil {
+		t.Fatal(err)
+	}
+	for _, page := range []string{"a", "b"} {
+		if err := w.WriteExchange(testExchange("http://example.com/"+page, "same")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Close()
+	for _, warc := range readAllWarcs(t, dir) {
+		if strings.Contains(warc, "WARC-Type: revisit") {
+			t.Errorf("a revisit refers to another file")
+		}
+	}
+}
+
+func TestWriterRotation(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewWriter(dir, "test", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, page := range []string{"a", "b", "c"} {
+		if err := w.WriteExchange(testExchange("http://example.com/"+page, page)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = w.Close()
+
+	contents := readAllWarcs(t, dir)
+	if len(contents) != 3 {
+		t.Fatalf("got %d files, want 3", len(contents))
+	}
+	for _, warc := range contents {
+		if !strings.HasPrefix(warc, "WARC/1.1\r\nWARC-Type: warcinfo\r\n") {
+			t.Errorf("file does not start with warcinfo")
+		}
+	}
+}
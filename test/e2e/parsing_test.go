@@ -4,12 +4,15 @@ import (
 	"context"
 	"crawler/internal/cfg"
 	"crawler/internal/fetcher"
+	"crawler/internal/frontier"
 	"crawler/internal/parser"
+	"crawler/internal/seed"
 	"crawler/internal/storage"
 	"fmt"
 	"github.com/stretchr/testify/suite"
 	bolt "go.etcd.io/bbolt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"testing"
@@ -23,7 +26,7 @@ type ParsingTestSuite struct {
 
 	db        *bolt.DB
 	linkRepo  *storage.LinkRepository
-	queueRepo *storage.QueueRepository
+	queueRepo *storage.FrontierRepository
 	crawler   *fetcher.Crawler
 }
 
@@ -37,18 +40,22 @@ func (pts *ParsingTestSuite) Test_Crawl_Parsed_Ok() {
 			Handler: fs,
 		}
 
-		doneChan := make(chan bool)
+		// the crawl returns once the queue is drained, the timeout only guards against a hang
+		doneChan := make(chan bool, 1)
 		go func() {
 			time.Sleep(5 * time.Second)
-			cancelCtx, _ := context.WithTimeout(context.Background(), 5*time.Second)
-			pts.testServer.Shutdown(cancelCtx)
 			doneChan <- true
 		}()
+		ln, err := net.Listen("tcp", pts.testServer.Addr)
+		pts.Require().Nil(err)
 		go func() {
-			_ = pts.testServer.ListenAndServe()
+			_ = pts.testServer.Serve(ln)
 		}()
 
-		pts.crawler.Crawl("http://localhost:8888/good_index.html", doneChan)
+		pts.crawler.Crawl([]seed.Seed{{URL: "http://localhost:8888/good_index.html"}}, doneChan)
+		cancelCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		pts.testServer.Shutdown(cancelCtx)
 
 		cnt := 0
 		pts.db.View(func(tx *bolt.Tx) error {
@@ -63,6 +70,10 @@ func (pts *ParsingTestSuite) Test_Crawl_Parsed_Ok() {
 		pts.Assert().Nil(err)
 		pts.Assert().NotNil(d)
 		pts.Assert().Equal(4, cnt)
+
+		links, err := pts.linkRepo.Links("http://localhost:8888/second_page.html", 0)
+		pts.Assert().Nil(err)
+		pts.Assert().Contains(links.Incoming, "http://localhost:8888/good_index.html")
 	})
 }
 
@@ -76,18 +87,22 @@ func (pts *ParsingTestSuite) Test_Crawl_Parsed_With_Errors() {
 			Handler: fs,
 		}
 
-		doneChan := make(chan bool)
+		// the crawl returns once the queue is drained, the timeout only guards against a hang
+		doneChan := make(chan bool, 1)
 		go func() {
 			time.Sleep(5 * time.Second)
-			cancelCtx, _ := context.WithTimeout(context.Background(), 5*time.Second)
-			pts.testServer.Shutdown(cancelCtx)
 			doneChan <- true
 		}()
+		ln, err := net.Listen("tcp", pts.testServer.Addr)
+		pts.Require().Nil(err)
 		go func() {
-			_ = pts.testServer.ListenAndServe()
+			_ = pts.testServer.Serve(ln)
 		}()
 
-		pts.crawler.Crawl("http://localhost:8888/bad_index.html", doneChan)
+		pts.crawler.Crawl([]seed.Seed{{URL: "http://localhost:8888/bad_index.html"}}, doneChan)
+		cancelCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		pts.testServer.Shutdown(cancelCtx)
 
 		cnt := 0
 		pts.db.View(func(tx *bolt.Tx) error {
@@ -156,15 +171,20 @@ func (pts *ParsingTestSuite) SetupTest() {
 	if err != nil {
 		panic(err)
 	}
-	pts.queueRepo, err = storage.NewQueueRepository(pts.db)
+	scorer, err := frontier.NewScorer(frontier.Config{})
+	if err != nil {
+		panic(err)
+	}
+	pts.queueRepo, err = storage.NewFrontierRepository(pts.db, scorer)
 	if err != nil {
 		panic(err)
 	}
 	blacklist := storage.NewHashList()
 	p := parser.NewParser()
-	f := fetcher.WebFetcher{}
+	f := fetcher.NewWebFetcher(appCfg.AcceptableMimeTypes)
 	l := log.New(os.Stdout, "crawler: ", log.LstdFlags|log.Lshortfile)
-	pts.crawler = fetcher.NewCrawler(l, appCfg.Parallelism, p, &f, pts.linkRepo, pts.queueRepo, blacklist, "./downloadsTest")
+	pts.crawler = fetcher.NewCrawler(l, appCfg.Parallelism, p, f, pts.linkRepo, pts.queueRepo, blacklist, "./downloadsTest")
+	pts.crawler.SetLinkGraph(pts.linkRepo)
 }
 
 func (pts *ParsingTestSuite) TearDownTest() {